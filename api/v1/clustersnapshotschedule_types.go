@@ -0,0 +1,155 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This file may be used, at your option, according to either the GNU AGPL 3.0 or
+the Apache V2 license.
+
+---
+This program is free software: you can redistribute it and/or modify it under
+the terms of the GNU Affero General Public License as published by the Free
+Software Foundation, either version 3 of the License, or (at your option) any
+later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY
+WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+PARTICULAR PURPOSE.  See the GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+---
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// nolint: lll
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterSnapshotScheduleKind is the Kind of the ClusterSnapshotSchedule
+// resource.
+const ClusterSnapshotScheduleKind = "ClusterSnapshotSchedule"
+
+// ClusterSnapshotScheduleSpec defines the desired state of
+// ClusterSnapshotSchedule
+type ClusterSnapshotScheduleSpec struct {
+	// A filter to select the namespaces to which this schedule applies. An
+	// empty selector matches all namespaces.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Namespace selector",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:selector:core:v1:Namespace"}
+	//+optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// The schedule to apply within each selected namespace. The claimSelector
+	// is evaluated separately in each namespace.
+	SnapshotScheduleSpec `json:",inline"`
+}
+
+// NamespaceScheduleStatus summarizes the observed state of a
+// ClusterSnapshotSchedule within a single namespace. Only a summary is kept so
+// that the status of a schedule that spans many namespaces remains small.
+type NamespaceScheduleStatus struct {
+	// The namespace this status refers to
+	Namespace string `json:"namespace"`
+	// Conditions is a list of conditions related to operator reconciliation.
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// The time of the most recent snapshot taken by this schedule
+	//+optional
+	LastSnapshotTime *metav1.Time `json:"lastSnapshotTime,omitempty"`
+	// The time of the next scheduled snapshot
+	//+optional
+	NextSnapshotTime *metav1.Time `json:"nextSnapshotTime,omitempty"`
+	// The total number of scheduled times for which no snapshots were taken
+	// because they were missed
+	//+optional
+	MissedRuns int64 `json:"missedRuns,omitempty"`
+	// The most recent scheduled time that was missed
+	//+optional
+	LastMissedTime *metav1.Time `json:"lastMissedTime,omitempty"`
+	// The most recent manual trigger of the schedule
+	//+optional
+	LastTrigger *TriggerStatus `json:"lastTrigger,omitempty"`
+	// The most recent scheduled and manual runs, from which a run that is
+	// underway carries on
+	//+optional
+	//+kubebuilder:validation:MaxItems=2
+	RecentRuns []SnapshotRunStatus `json:"recentRuns,omitempty"`
+	// The selected PVCs that have received their baseline snapshot
+	//+optional
+	BaselineClaims []string `json:"baselineClaims,omitempty"`
+	// The number of the schedule's snapshots that have failed or are stuck
+	//+optional
+	FailedSnapshots int32 `json:"failedSnapshots,omitempty"`
+	// The number of selected PVCs that weren't snapshotted by the most recent
+	// run
+	//+optional
+	SkippedClaims int32 `json:"skippedClaims,omitempty"`
+	// The number of PVCs that no longer exist or are no longer selected by
+	// the schedule, but still have snapshots from it that are neither pinned
+	// nor final snapshots
+	//+optional
+	OrphanedClaims int32 `json:"orphanedClaims,omitempty"`
+	// The number of the schedule's snapshots of orphaned PVCs, not counting
+	// pinned and final snapshots
+	//+optional
+	OrphanedSnapshots int32 `json:"orphanedSnapshots,omitempty"`
+}
+
+// ClusterSnapshotScheduleStatus defines the observed state of
+// ClusterSnapshotSchedule
+type ClusterSnapshotScheduleStatus struct {
+	// Conditions is a list of conditions related to operator reconciliation.
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Conditions",xDescriptors={"urn:alm:descriptor:io.kubernetes.conditions"}
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// The status of the schedule in each of the selected namespaces
+	//+optional
+	//+listType=map
+	//+listMapKey=namespace
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Namespaces"
+	Namespaces []NamespaceScheduleStatus `json:"namespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=".spec.schedule"
+//+kubebuilder:printcolumn:name="Max age",type=string,JSONPath=".spec.retention.expires"
+//+kubebuilder:printcolumn:name="Max num",type=integer,JSONPath=".spec.retention.maxCount"
+//+kubebuilder:printcolumn:name="Disabled",type=boolean,JSONPath=".spec.disabled"
+//+kubebuilder:printcolumn:name="Dry run",type=boolean,JSONPath=".spec.dryRun",priority=1
+//+kubebuilder:resource:path=clustersnapshotschedules,scope=Cluster
+//+operator-sdk:csv:customresourcedefinitions:displayName="Cluster Snapshot Schedule",resources={{Namespace,v1,""}}
+
+// ClusterSnapshotSchedule defines a schedule for taking automated snapshots of
+// PVC(s) across a set of namespaces
+type ClusterSnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSnapshotScheduleSpec   `json:"spec,omitempty"`
+	Status ClusterSnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterSnapshotScheduleList contains a list of ClusterSnapshotSchedule
+type ClusterSnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSnapshotSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterSnapshotSchedule{}, &ClusterSnapshotScheduleList{})
+}
@@ -35,9 +35,14 @@ limitations under the License.
 package v1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// SnapshotScheduleKind is the Kind of the SnapshotSchedule resource.
+const SnapshotScheduleKind = "SnapshotSchedule"
+
 // SnapshotRetentionSpec defines how long snapshots should be kept.
 type SnapshotRetentionSpec struct {
 	// The length of time (time.Duration) after which a given Snapshot will be
@@ -46,11 +51,95 @@ type SnapshotRetentionSpec struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Expiration period",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
 	//+optional
 	Expires string `json:"expires,omitempty"`
-	// The maximum number of snapshots to retain per PVC
+	// The maximum number of snapshots to retain per PVC. Only snapshots that
+	// are ready to use are counted, so failed or pending snapshots never cause
+	// ready ones to be deleted.
 	//+kubebuilder:validation:Minimum=1
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Maximum snapshots",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
 	//+optional
 	MaxCount *int32 `json:"maxCount,omitempty"`
+	// Tiered (grandfather-father-son) retention. When specified, snapshots are
+	// only retained if they are kept by at least one of the tiers.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Tiered retention"
+	//+optional
+	Tiers *TieredRetentionSpec `json:"tiers,omitempty"`
+	// The length of time (time.Duration) after which a snapshot that has yet
+	// to become ready to use is deleted. Snapshots that are not ready are
+	// excluded from maxCount and tiered retention, so if this is not
+	// specified, it defaults to 24h for schedules that use either of them.
+	// Otherwise, such snapshots are only removed by the expiration period.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Not ready timeout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	//+optional
+	NotReadyTimeout string `json:"notReadyTimeout,omitempty"`
+	// Determines how the snapshots of PVCs that no longer exist or are no
+	// longer selected by the schedule are retained. If not specified, they
+	// are subject to the same rules as the schedule's other snapshots.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Orphaned snapshots"
+	//+optional
+	Orphans *OrphanRetentionSpec `json:"orphans,omitempty"`
+}
+
+// OrphanPolicy determines how orphaned snapshots are retained
+// +kubebuilder:validation:Enum=Retain;Expire;KeepNewest
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyRetain keeps orphaned snapshots until they are deleted
+	// manually
+	OrphanPolicyRetain OrphanPolicy = "Retain"
+	// OrphanPolicyExpire deletes orphaned snapshots once they are older than
+	// the orphan expiration period
+	OrphanPolicyExpire OrphanPolicy = "Expire"
+	// OrphanPolicyKeepNewest keeps only the newest orphaned snapshots of each
+	// PVC
+	OrphanPolicyKeepNewest OrphanPolicy = "KeepNewest"
+)
+
+// OrphanRetentionSpec defines the retention of orphaned snapshots: those whose
+// PVC no longer exists or is no longer selected by the schedule. Orphaned
+// snapshots are only subject to this policy, not to the schedule's other
+// retention rules.
+type OrphanRetentionSpec struct {
+	// How orphaned snapshots are retained
+	Policy OrphanPolicy `json:"policy"`
+	// The length of time (time.Duration) after which an orphaned snapshot is
+	// deleted. Required by the Expire policy.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+optional
+	Expires string `json:"expires,omitempty"`
+	// The number of ready orphaned snapshots to keep per PVC. Required by the
+	// KeepNewest policy.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxCount *int32 `json:"maxCount,omitempty"`
+}
+
+// TieredRetentionSpec defines a grandfather-father-son retention policy. Each
+// tier retains the newest snapshot from each of the given number of most
+// recent periods (e.g., the last snapshot of each of the last 7 days). The
+// periods are determined by the scheduled time of each snapshot.
+type TieredRetentionSpec struct {
+	// The number of hourly snapshots to retain
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Hourly *int32 `json:"hourly,omitempty"`
+	// The number of daily snapshots to retain
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Daily *int32 `json:"daily,omitempty"`
+	// The number of weekly snapshots to retain
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Weekly *int32 `json:"weekly,omitempty"`
+	// The number of monthly snapshots to retain
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Monthly *int32 `json:"monthly,omitempty"`
+	// The number of yearly snapshots to retain
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Yearly *int32 `json:"yearly,omitempty"`
 }
 
 // SnapshotTemplateSpec defines the template for Snapshot objects
@@ -66,12 +155,237 @@ type SnapshotTemplateSpec struct {
 	SnapshotClassName *string `json:"snapshotClassName,omitempty"`
 }
 
+// GroupSnapshotSpec configures a schedule to snapshot all of its PVCs together
+// as a single VolumeGroupSnapshot
+type GroupSnapshotSpec struct {
+	// The name of the VolumeGroupSnapshotClass to be used when creating
+	// VolumeGroupSnapshots. If not specified, the default class for the PVCs'
+	// CSI driver is used.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="VolumeGroupSnapshotClass name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	//+optional
+	VolumeGroupSnapshotClassName *string `json:"volumeGroupSnapshotClassName,omitempty"`
+}
+
+// FailedSnapshotSpec determines how snapshots that fail or become stuck are
+// handled
+type FailedSnapshotSpec struct {
+	// The length of time (time.Duration) after which a snapshot that has yet
+	// to become ready to use, but hasn't reported an error, is considered
+	// stuck. Defaults to 1h.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+optional
+	StuckAfter string `json:"stuckAfter,omitempty"`
+	// The maximum number of times a failed or stuck snapshot of the most
+	// recent run is replaced with a new one. Defaults to 0 (no retries).
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// The length of time (time.Duration) for which a snapshot that has failed
+	// is kept (e.g., for troubleshooting) after the error occurred. It is then
+	// deleted. If not specified, failed snapshots are left for the retention
+	// rules to remove.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+optional
+	GracePeriod string `json:"gracePeriod,omitempty"`
+}
+
+// FinalSnapshotSpec configures the snapshot taken of a schedule's PVCs as
+// they are deleted
+type FinalSnapshotSpec struct {
+	// The length of time (time.Duration) to wait for a PVC's final snapshot to
+	// become ready to use before allowing the PVC's deletion to proceed
+	// anyway. Defaults to 10m.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+optional
+	Timeout string `json:"timeout,omitempty"`
+	// The length of time (time.Duration) for which final snapshots are kept.
+	// Final snapshots aren't subject to the schedule's other retention rules,
+	// so if not specified, they are kept until they are deleted manually.
+	//+kubebuilder:validation:Pattern=^\d+(h|m|s)$
+	//+optional
+	Expires string `json:"expires,omitempty"`
+}
+
+// DeletionPolicy determines what happens to a schedule's snapshots when the
+// schedule is deleted
+// +kubebuilder:validation:Enum=Retain;Delete;RetainLatest
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps all of the schedule's snapshots
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes all of the schedule's snapshots
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetainLatest keeps only the newest snapshot of each PVC
+	// (or, in group snapshot mode, the newest group snapshot)
+	DeletionPolicyRetainLatest DeletionPolicy = "RetainLatest"
+)
+
+// HookFailurePolicy determines what happens when a pre-snapshot hook fails
+// +kubebuilder:validation:Enum=Skip;Proceed
+type HookFailurePolicy string
+
+const (
+	// HookFailureSkip skips the snapshots for the current scheduled time
+	HookFailureSkip HookFailurePolicy = "Skip"
+	// HookFailureProceed takes the snapshots anyway
+	HookFailureProceed HookFailurePolicy = "Proceed"
+)
+
+// MissedRunPolicy determines how a schedule catches up on scheduled times that
+// were missed
+// +kubebuilder:validation:Enum=TakeOne;Skip;RecordOnly
+type MissedRunPolicy string
+
+const (
+	// MissedRunTakeOne takes a single set of snapshots for the most recent of
+	// the missed times
+	MissedRunTakeOne MissedRunPolicy = "TakeOne"
+	// MissedRunSkip takes no snapshots for the missed times and waits for the
+	// next scheduled time
+	MissedRunSkip MissedRunPolicy = "Skip"
+	// MissedRunRecordOnly takes no snapshots for the missed times, but adds
+	// each of them to the schedule's run history
+	MissedRunRecordOnly MissedRunPolicy = "RecordOnly"
+)
+
+// ClaimFailurePolicy determines what a schedule does when the snapshots of
+// some of its PVCs can not be created
+// +kubebuilder:validation:Enum=Retry;Advance
+type ClaimFailurePolicy string
+
+const (
+	// ClaimFailureRetry retries the failed snapshots, up to maxRetries times,
+	// before moving on to the next scheduled time
+	ClaimFailureRetry ClaimFailurePolicy = "Retry"
+	// ClaimFailureAdvance moves on to the next scheduled time right away
+	ClaimFailureAdvance ClaimFailurePolicy = "Advance"
+)
+
+// ClaimFailureSpec determines what a schedule does when the snapshots of some
+// of its PVCs can not be created. The snapshots of the other PVCs are created
+// regardless.
+type ClaimFailureSpec struct {
+	// Whether to retry the failed snapshots (Retry) or move on to the next
+	// scheduled time (Advance). Defaults to Retry.
+	//+optional
+	Policy ClaimFailurePolicy `json:"policy,omitempty"`
+	// The number of times the failed snapshots are retried before moving on.
+	// Defaults to 3.
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// The number of seconds to wait between retries. Defaults to 60.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	RetryIntervalSeconds *int32 `json:"retryIntervalSeconds,omitempty"`
+}
+
+// SnapshotHooksSpec defines the hooks that are run around snapshot creation
+type SnapshotHooksSpec struct {
+	// A hook that is run before the snapshots are taken (e.g., to quiesce an
+	// application)
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pre-snapshot hook"
+	//+optional
+	Pre *SnapshotHook `json:"pre,omitempty"`
+	// A hook that is run once the snapshots have been taken (e.g., to resume
+	// an application). If a pre-snapshot hook succeeded, the post-snapshot
+	// hook is run even if the snapshots could not be created.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Post-snapshot hook"
+	//+optional
+	Post *SnapshotHook `json:"post,omitempty"`
+}
+
+// SnapshotHook is an action to perform around snapshot creation. Exactly one of
+// exec or job must be specified.
+type SnapshotHook struct {
+	// Run a command in the containers of selected pods
+	//+optional
+	Exec *ExecHook `json:"exec,omitempty"`
+	// Run a Job to completion
+	//+optional
+	Job *JobHook `json:"job,omitempty"`
+	// The maximum time to allow the hook to run. Defaults to 30 seconds.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// Determines whether the snapshots are taken if a pre-snapshot hook fails
+	// or times out. Defaults to Skip. The snapshots are always kept if a
+	// post-snapshot hook fails.
+	//+optional
+	OnFailure HookFailurePolicy `json:"onFailure,omitempty"`
+}
+
+// ExecHook runs a command in a container of each running pod matched by the
+// selector
+type ExecHook struct {
+	// Selects the pods, within the schedule's namespace, in which to run the
+	// command. At least one matching pod must be running.
+	PodSelector metav1.LabelSelector `json:"podSelector"`
+	// The name of the container in which to run the command. Defaults to the
+	// pod's first container.
+	//+optional
+	Container string `json:"container,omitempty"`
+	// The command to run. It is not run in a shell.
+	//+kubebuilder:validation:MinItems=1
+	Command []string `json:"command"`
+}
+
+// JobHook runs a Job, created in the schedule's namespace, to completion
+type JobHook struct {
+	// The specification of the Job to run
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:validation:Type=object
+	//+kubebuilder:pruning:PreserveUnknownFields
+	Template batchv1.JobSpec `json:"template"`
+}
+
+// StorageClassSelector selects PVCs based on the StorageClass they use. A
+// StorageClass is selected if it is listed by name or if it matches the label
+// selector.
+type StorageClassSelector struct {
+	// The names of the StorageClasses whose PVCs should be selected.
+	//+optional
+	Names []string `json:"names,omitempty"`
+	// A label selector applied to StorageClass objects.
+	//+optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ClaimFilterSpec limits a schedule's PVCs to those with particular volume
+// properties. Each list that is given must include a value of the PVC for it to
+// be snapshotted.
+type ClaimFilterSpec struct {
+	// Only PVCs with at least one of these access modes are snapshotted.
+	//+optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// Only PVCs with one of these volume modes are snapshotted.
+	//+optional
+	//+kubebuilder:validation:items:Enum=Block;Filesystem
+	VolumeModes []corev1.PersistentVolumeMode `json:"volumeModes,omitempty"`
+	// Only PVCs whose volumes are provisioned by one of these CSI drivers are
+	// snapshotted.
+	//+optional
+	CSIDrivers []string `json:"csiDrivers,omitempty"`
+}
+
 // SnapshotScheduleSpec defines the desired state of SnapshotSchedule
 type SnapshotScheduleSpec struct {
 	// A filter to select which PVCs to snapshot via this schedule
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="PVC selector",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:selector:core:v1:PersistentVolumeClaim"}
 	//+optional
 	ClaimSelector metav1.LabelSelector `json:"claimSelector,omitempty"`
+	// A filter to further limit the selected PVCs to those using particular
+	// StorageClasses. If omitted, PVCs of any StorageClass are selected.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="StorageClass selector"
+	//+optional
+	StorageClassSelector *StorageClassSelector `json:"storageClassSelector,omitempty"`
+	// A filter to further limit the selected PVCs to those with particular
+	// access modes, volume modes, or CSI drivers. PVCs that aren't bound, or
+	// that are being deleted, are never snapshotted.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="PVC filter"
+	//+optional
+	ClaimFilter *ClaimFilterSpec `json:"claimFilter,omitempty"`
 	// Retention determines how long this schedule's snapshots will be kept.
 	//+operator-sdk:csv:customresourcedefinitions:type=spec
 	//+optional
@@ -81,13 +395,194 @@ type SnapshotScheduleSpec struct {
 	//+kubebuilder:validation:Pattern=`^(@(annually|yearly|monthly|weekly|daily|hourly))|((((\d+,)*\d+|(\d+(\/|-)\d+)|\*(\/\d+)?)\s?){5})$`
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Schedule",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
 	Schedule string `json:"schedule,omitempty"`
+	// The IANA name of the time zone (e.g., "America/New_York") in which the
+	// schedule is evaluated. If not specified, the time zone of the operator
+	// is used. Times that are skipped or repeated due to daylight saving time
+	// transitions are taken only once.
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Time zone",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	//+optional
+	TimeZone *string `json:"timeZone,omitempty"`
+	// The number of seconds after a scheduled time within which its snapshots
+	// may still be taken (e.g., if the operator was not running at the
+	// scheduled time). Scheduled times that are later than this are counted
+	// as missed. If not specified, there is no deadline.
+	//+kubebuilder:validation:Minimum=0
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Starting deadline seconds",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	//+optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// Determines what happens when several scheduled times have passed
+	// without the snapshots being taken (e.g., because the operator was not
+	// running). Defaults to TakeOne.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Missed run policy"
+	//+optional
+	MissedRunPolicy MissedRunPolicy `json:"missedRunPolicy,omitempty"`
+	// Delays each of the schedule's snapshot times by a fixed amount of up to
+	// this many seconds so that schedules with the same cronspec don't all
+	// take their snapshots at once. The delay is derived from the schedule's
+	// namespace and name, so it is the same for every run.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=3600
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Jitter seconds"
+	//+optional
+	JitterSeconds *int64 `json:"jitterSeconds,omitempty"`
 	// Indicates that this schedule should be temporarily disabled
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disabled",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
 	//+optional
 	Disabled bool `json:"disabled,omitempty"`
+	// Indicates that the schedule should only report (in its status and via
+	// Events) the snapshots it would create and delete, without creating or
+	// deleting any
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Dry run",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	//+optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// Indicates that a PVC should be snapshotted as soon as the schedule
+	// selects it, rather than waiting for the next scheduled time. Each PVC
+	// receives a single such baseline snapshot.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Snapshot on match",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	//+optional
+	SnapshotOnMatch bool `json:"snapshotOnMatch,omitempty"`
 	// A template to customize the Snapshots.
 	//+operator-sdk:csv:customresourcedefinitions:type=spec
 	SnapshotTemplate *SnapshotTemplateSpec `json:"snapshotTemplate,omitempty"`
+	// If set, all PVCs matched by the claimSelector are snapshotted together
+	// as a single, crash-consistent VolumeGroupSnapshot instead of as
+	// individual VolumeSnapshots. Retention is then applied to the group
+	// snapshots as a whole. The template's labels are applied to the group
+	// snapshot, but its snapshotClassName is not used. This may not be
+	// combined with a storageClassSelector.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Group snapshot"
+	//+optional
+	GroupSnapshot *GroupSnapshotSpec `json:"groupSnapshot,omitempty"`
+	// Hooks to run before and after the snapshots are taken
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Hooks"
+	//+optional
+	Hooks *SnapshotHooksSpec `json:"hooks,omitempty"`
+	// Determines what happens when the snapshots of some PVCs can not be
+	// created
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Claim failures"
+	//+optional
+	ClaimFailure *ClaimFailureSpec `json:"claimFailure,omitempty"`
+	// Determines how snapshots that fail or become stuck are handled. Such
+	// snapshots are always reported in the schedule's status.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Failed snapshots"
+	//+optional
+	FailedSnapshots *FailedSnapshotSpec `json:"failedSnapshots,omitempty"`
+	// If set, the schedule places a finalizer on each of its PVCs so that,
+	// when a PVC is deleted, a final snapshot of it is taken before the PVC is
+	// allowed to go away
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Final snapshot"
+	//+optional
+	FinalSnapshot *FinalSnapshotSpec `json:"finalSnapshot,omitempty"`
+	// Determines what happens to the schedule's snapshots when the schedule
+	// is deleted. Pinned and final snapshots are always kept. If not
+	// specified, the controller's default is used, which is Retain unless it
+	// was started with --enable-owner-references.
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Deletion policy"
+	//+optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// SnapshotRunStatus records the outcome of a single scheduled run
+type SnapshotRunStatus struct {
+	// The time at which the run was scheduled
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+	// The VolumeGroupSnapshot taken by the run, if the schedule is in group
+	// snapshot mode
+	//+optional
+	VolumeGroupSnapshotName string `json:"volumeGroupSnapshotName,omitempty"`
+	// The snapshot taken of each PVC
+	//+optional
+	//+listType=map
+	//+listMapKey=pvcName
+	//+kubebuilder:validation:MaxItems=100
+	Snapshots []PVCSnapshotStatus `json:"snapshots,omitempty"`
+	// The number of PVCs snapshotted by the run. Only the first 100 are
+	// listed in snapshots, starting with those whose snapshots couldn't be
+	// created.
+	//+optional
+	SnapshotCount int32 `json:"snapshotCount,omitempty"`
+	// The error that prevented the run from completing, if any
+	//+optional
+	Error string `json:"error,omitempty"`
+	// The manual trigger that requested the run, if it was not scheduled
+	//+optional
+	Trigger string `json:"trigger,omitempty"`
+	// The number of attempts at creating the run's snapshots in which some of
+	// them could not be created
+	//+optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// The time of the most recent failed attempt
+	//+optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+}
+
+// FailedSnapshotStatus describes a snapshot that has failed or is stuck
+type FailedSnapshotStatus struct {
+	// The name of the VolumeSnapshot
+	Name string `json:"name"`
+	// The name of the PVC
+	PVCName string `json:"pvcName"`
+	// Why the snapshot is considered to have failed (Error or Stuck)
+	Reason string `json:"reason"`
+	// A description of the failure
+	//+optional
+	Message string `json:"message,omitempty"`
+}
+
+// SkippedClaimStatus describes a PVC that is selected by a schedule but isn't
+// snapshotted
+type SkippedClaimStatus struct {
+	// The name of the PVC
+	PVCName string `json:"pvcName"`
+	// Why the PVC is skipped
+	Reason string `json:"reason"`
+	// A description of why the PVC is skipped
+	//+optional
+	Message string `json:"message,omitempty"`
+}
+
+// DryRunStatus reports what a schedule in dry-run mode would have done
+type DryRunStatus struct {
+	// The scheduled time of the most recent run
+	//+optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// The snapshots that the most recent run would have created
+	//+optional
+	WouldCreate []string `json:"wouldCreate,omitempty"`
+	// The snapshots that the retention rules would currently delete
+	//+optional
+	//+kubebuilder:validation:MaxItems=100
+	WouldExpire []string `json:"wouldExpire,omitempty"`
+	// The total number of snapshots that the retention rules would currently
+	// delete. Only the first 100 are listed in wouldExpire.
+	//+optional
+	WouldExpireCount int32 `json:"wouldExpireCount,omitempty"`
+}
+
+// TriggerStatus records the most recent manual trigger of a schedule
+type TriggerStatus struct {
+	// The value of the trigger annotation
+	Token string `json:"token"`
+	// The time of the run, which is used to name its snapshots
+	Time metav1.Time `json:"time"`
+	// Whether the run's snapshots have been taken
+	//+optional
+	Completed bool `json:"completed,omitempty"`
+}
+
+// PVCSnapshotStatus records the snapshot taken of a PVC during a run
+type PVCSnapshotStatus struct {
+	// The name of the PVC
+	PVCName string `json:"pvcName"`
+	// The name of the VolumeSnapshot
+	//+optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// Whether the snapshot is ready to be used to restore a volume
+	ReadyToUse bool `json:"readyToUse"`
+	// The error encountered while creating the snapshot, if any
+	//+optional
+	Error string `json:"error,omitempty"`
 }
 
 // SnapshotScheduleStatus defines the observed state of SnapshotSchedule
@@ -104,6 +599,58 @@ type SnapshotScheduleStatus struct {
 	//+optional
 	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Next snapshot",xDescriptors={"urn:alm:descriptor:text"}
 	NextSnapshotTime *metav1.Time `json:"nextSnapshotTime,omitempty"`
+	// The most recent runs of the schedule, newest first
+	//+optional
+	//+kubebuilder:validation:MaxItems=10
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Recent runs"
+	RecentRuns []SnapshotRunStatus `json:"recentRuns,omitempty"`
+	// The total number of scheduled times for which no snapshots were taken
+	// because they were missed
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Missed runs",xDescriptors={"urn:alm:descriptor:text"}
+	MissedRuns int64 `json:"missedRuns,omitempty"`
+	// The most recent scheduled time that was missed
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Last missed time",xDescriptors={"urn:alm:descriptor:text"}
+	LastMissedTime *metav1.Time `json:"lastMissedTime,omitempty"`
+	// The most recent manual trigger of the schedule
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Last trigger"
+	LastTrigger *TriggerStatus `json:"lastTrigger,omitempty"`
+	// The schedule's snapshots that have failed or are stuck, newest first
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	//+kubebuilder:validation:MaxItems=20
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Failed snapshots"
+	FailedSnapshots []FailedSnapshotStatus `json:"failedSnapshots,omitempty"`
+	// The selected PVCs that weren't snapshotted by the most recent run
+	//+optional
+	//+listType=map
+	//+listMapKey=pvcName
+	//+kubebuilder:validation:MaxItems=50
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Skipped PVCs"
+	SkippedClaims []SkippedClaimStatus `json:"skippedClaims,omitempty"`
+	// The selected PVCs that no longer need a baseline snapshot, either because
+	// one has been taken or because they had already been snapshotted, when
+	// snapshotOnMatch is enabled
+	//+optional
+	//+listType=set
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Baseline PVCs"
+	BaselineClaims []string `json:"baselineClaims,omitempty"`
+	// The number of PVCs that no longer exist or are no longer selected by
+	// the schedule, but still have snapshots from it that are neither pinned
+	// nor final snapshots
+	//+optional
+	OrphanedClaims int32 `json:"orphanedClaims,omitempty"`
+	// The number of the schedule's snapshots of orphaned PVCs, not counting
+	// pinned and final snapshots
+	//+optional
+	OrphanedSnapshots int32 `json:"orphanedSnapshots,omitempty"`
+	// What the schedule would have done, while it is in dry-run mode
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Dry run"
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
 }
 
 const (
@@ -114,6 +661,70 @@ const (
 	ReconciledReasonError = "ReconcileError"
 	// ReconciledReasonComplete indicates reconcile was successful
 	ReconciledReasonComplete = "ReconcileComplete"
+	// ConditionPreSnapshotHook is a Condition indicating the outcome of the
+	// most recent pre-snapshot hook
+	ConditionPreSnapshotHook = "PreSnapshotHookSucceeded"
+	// ConditionPostSnapshotHook is a Condition indicating the outcome of the
+	// most recent post-snapshot hook
+	ConditionPostSnapshotHook = "PostSnapshotHookSucceeded"
+	// HookReasonRunning indicates the hook has not yet finished
+	HookReasonRunning = "Running"
+	// HookReasonSucceeded indicates the hook completed successfully
+	HookReasonSucceeded = "Succeeded"
+	// HookReasonFailed indicates the hook failed
+	HookReasonFailed = "Failed"
+	// HookReasonTimedOut indicates the hook did not finish in time
+	HookReasonTimedOut = "TimedOut"
+	// ConditionFinalSnapshot is a Condition indicating whether the schedule's
+	// PVCs are held for final snapshots
+	ConditionFinalSnapshot = "FinalSnapshotEnabled"
+	// FinalSnapshotReasonEnabled indicates the PVCs are held for final
+	// snapshots
+	FinalSnapshotReasonEnabled = "Enabled"
+	// FinalSnapshotReasonWebhooksDisabled indicates the PVCs aren't held since
+	// the final snapshots are taken by the webhooks, which are disabled
+	FinalSnapshotReasonWebhooksDisabled = "WebhooksDisabled"
+	// ConditionLastRunSucceeded is a Condition indicating whether all of the
+	// snapshots of the most recent run were created
+	ConditionLastRunSucceeded = "LastRunSucceeded"
+	// LastRunReasonSucceeded indicates all snapshots were created
+	LastRunReasonSucceeded = "Succeeded"
+	// LastRunReasonFailed indicates some snapshots could not be created
+	LastRunReasonFailed = "Failed"
+	// LastRunReasonSkipped indicates the run's snapshots were not attempted
+	LastRunReasonSkipped = "Skipped"
+	// ConditionSnapshotsReady is a Condition indicating whether all of the
+	// snapshots of the most recent run are ready to use
+	ConditionSnapshotsReady = "SnapshotsReady"
+	// SnapshotsReadyReasonReady indicates all snapshots are ready to use
+	SnapshotsReadyReasonReady = "Ready"
+	// SnapshotsReadyReasonPending indicates some snapshots are not yet ready
+	SnapshotsReadyReasonPending = "Pending"
+	// SnapshotsReadyReasonError indicates some snapshots have failed
+	SnapshotsReadyReasonError = "SnapshotError"
+	// FailedSnapshotReasonError indicates the snapshot reported an error
+	FailedSnapshotReasonError = "Error"
+	// FailedSnapshotReasonStuck indicates the snapshot has taken too long to
+	// become ready to use
+	FailedSnapshotReasonStuck = "Stuck"
+	// SkippedClaimReasonExcluded indicates the PVC is annotated to be excluded
+	// from snapshots
+	SkippedClaimReasonExcluded = "Excluded"
+	// SkippedClaimReasonNotBound indicates the PVC isn't bound to a volume yet
+	SkippedClaimReasonNotBound = "NotBound"
+	// SkippedClaimReasonLost indicates the PVC's volume has been lost
+	SkippedClaimReasonLost = "Lost"
+	// SkippedClaimReasonTerminating indicates the PVC is being deleted
+	SkippedClaimReasonTerminating = "Terminating"
+	// SkippedClaimReasonAccessMode indicates the claimFilter excludes the
+	// PVC's access modes
+	SkippedClaimReasonAccessMode = "AccessMode"
+	// SkippedClaimReasonVolumeMode indicates the claimFilter excludes the
+	// PVC's volume mode
+	SkippedClaimReasonVolumeMode = "VolumeMode"
+	// SkippedClaimReasonCSIDriver indicates the claimFilter excludes the CSI
+	// driver of the PVC's volume
+	SkippedClaimReasonCSIDriver = "CSIDriver"
 )
 
 //+kubebuilder:object:root=true
@@ -122,6 +733,7 @@ const (
 //+kubebuilder:printcolumn:name="Max age",type=string,JSONPath=".spec.retention.expires"
 //+kubebuilder:printcolumn:name="Max num",type=integer,JSONPath=".spec.retention.maxCount"
 //+kubebuilder:printcolumn:name="Disabled",type=boolean,JSONPath=".spec.disabled"
+//+kubebuilder:printcolumn:name="Dry run",type=boolean,JSONPath=".spec.dryRun",priority=1
 //+kubebuilder:printcolumn:name="Next snapshot",type=string,JSONPath=".status.nextSnapshotTime"
 //+kubebuilder:resource:path=snapshotschedules,scope=Namespaced
 //+operator-sdk:csv:customresourcedefinitions:displayName="Snapshot Schedule",resources={}
@@ -5,10 +5,463 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimFailureSpec) DeepCopyInto(out *ClaimFailureSpec) {
+	*out = *in
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetryIntervalSeconds != nil {
+		in, out := &in.RetryIntervalSeconds, &out.RetryIntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimFailureSpec.
+func (in *ClaimFailureSpec) DeepCopy() *ClaimFailureSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimFailureSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimFilterSpec) DeepCopyInto(out *ClaimFilterSpec) {
+	*out = *in
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]corev1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.VolumeModes != nil {
+		in, out := &in.VolumeModes, &out.VolumeModes
+		*out = make([]corev1.PersistentVolumeMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.CSIDrivers != nil {
+		in, out := &in.CSIDrivers, &out.CSIDrivers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimFilterSpec.
+func (in *ClaimFilterSpec) DeepCopy() *ClaimFilterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimFilterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSnapshotSchedule) DeepCopyInto(out *ClusterSnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSnapshotSchedule.
+func (in *ClusterSnapshotSchedule) DeepCopy() *ClusterSnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterSnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSnapshotScheduleList) DeepCopyInto(out *ClusterSnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterSnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSnapshotScheduleList.
+func (in *ClusterSnapshotScheduleList) DeepCopy() *ClusterSnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterSnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSnapshotScheduleSpec) DeepCopyInto(out *ClusterSnapshotScheduleSpec) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	in.SnapshotScheduleSpec.DeepCopyInto(&out.SnapshotScheduleSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSnapshotScheduleSpec.
+func (in *ClusterSnapshotScheduleSpec) DeepCopy() *ClusterSnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterSnapshotScheduleStatus) DeepCopyInto(out *ClusterSnapshotScheduleStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]NamespaceScheduleStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSnapshotScheduleStatus.
+func (in *ClusterSnapshotScheduleStatus) DeepCopy() *ClusterSnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSnapshotScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunStatus) DeepCopyInto(out *DryRunStatus) {
+	*out = *in
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.WouldCreate != nil {
+		in, out := &in.WouldCreate, &out.WouldCreate
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WouldExpire != nil {
+		in, out := &in.WouldExpire, &out.WouldExpire
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunStatus.
+func (in *DryRunStatus) DeepCopy() *DryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecHook) DeepCopyInto(out *ExecHook) {
+	*out = *in
+	in.PodSelector.DeepCopyInto(&out.PodSelector)
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecHook.
+func (in *ExecHook) DeepCopy() *ExecHook {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedSnapshotSpec) DeepCopyInto(out *FailedSnapshotSpec) {
+	*out = *in
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedSnapshotSpec.
+func (in *FailedSnapshotSpec) DeepCopy() *FailedSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedSnapshotStatus) DeepCopyInto(out *FailedSnapshotStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedSnapshotStatus.
+func (in *FailedSnapshotStatus) DeepCopy() *FailedSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FinalSnapshotSpec) DeepCopyInto(out *FinalSnapshotSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FinalSnapshotSpec.
+func (in *FinalSnapshotSpec) DeepCopy() *FinalSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FinalSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GroupSnapshotSpec) DeepCopyInto(out *GroupSnapshotSpec) {
+	*out = *in
+	if in.VolumeGroupSnapshotClassName != nil {
+		in, out := &in.VolumeGroupSnapshotClassName, &out.VolumeGroupSnapshotClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSnapshotSpec.
+func (in *GroupSnapshotSpec) DeepCopy() *GroupSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GroupSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobHook) DeepCopyInto(out *JobHook) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobHook.
+func (in *JobHook) DeepCopy() *JobHook {
+	if in == nil {
+		return nil
+	}
+	out := new(JobHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceScheduleStatus) DeepCopyInto(out *NamespaceScheduleStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextSnapshotTime != nil {
+		in, out := &in.NextSnapshotTime, &out.NextSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastMissedTime != nil {
+		in, out := &in.LastMissedTime, &out.LastMissedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastTrigger != nil {
+		in, out := &in.LastTrigger, &out.LastTrigger
+		*out = new(TriggerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RecentRuns != nil {
+		in, out := &in.RecentRuns, &out.RecentRuns
+		*out = make([]SnapshotRunStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BaselineClaims != nil {
+		in, out := &in.BaselineClaims, &out.BaselineClaims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceScheduleStatus.
+func (in *NamespaceScheduleStatus) DeepCopy() *NamespaceScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanRetentionSpec) DeepCopyInto(out *OrphanRetentionSpec) {
+	*out = *in
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanRetentionSpec.
+func (in *OrphanRetentionSpec) DeepCopy() *OrphanRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCSnapshotStatus) DeepCopyInto(out *PVCSnapshotStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCSnapshotStatus.
+func (in *PVCSnapshotStatus) DeepCopy() *PVCSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedClaimStatus) DeepCopyInto(out *SkippedClaimStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedClaimStatus.
+func (in *SkippedClaimStatus) DeepCopy() *SkippedClaimStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedClaimStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotHook) DeepCopyInto(out *SnapshotHook) {
+	*out = *in
+	if in.Exec != nil {
+		in, out := &in.Exec, &out.Exec
+		*out = new(ExecHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Job != nil {
+		in, out := &in.Job, &out.Job
+		*out = new(JobHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotHook.
+func (in *SnapshotHook) DeepCopy() *SnapshotHook {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotHooksSpec) DeepCopyInto(out *SnapshotHooksSpec) {
+	*out = *in
+	if in.Pre != nil {
+		in, out := &in.Pre, &out.Pre
+		*out = new(SnapshotHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Post != nil {
+		in, out := &in.Post, &out.Post
+		*out = new(SnapshotHook)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotHooksSpec.
+func (in *SnapshotHooksSpec) DeepCopy() *SnapshotHooksSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotHooksSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SnapshotRetentionSpec) DeepCopyInto(out *SnapshotRetentionSpec) {
 	*out = *in
@@ -17,6 +470,16 @@ func (in *SnapshotRetentionSpec) DeepCopyInto(out *SnapshotRetentionSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Tiers != nil {
+		in, out := &in.Tiers, &out.Tiers
+		*out = new(TieredRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Orphans != nil {
+		in, out := &in.Orphans, &out.Orphans
+		*out = new(OrphanRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotRetentionSpec.
@@ -29,6 +492,31 @@ func (in *SnapshotRetentionSpec) DeepCopy() *SnapshotRetentionSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotRunStatus) DeepCopyInto(out *SnapshotRunStatus) {
+	*out = *in
+	in.ScheduledTime.DeepCopyInto(&out.ScheduledTime)
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]PVCSnapshotStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotRunStatus.
+func (in *SnapshotRunStatus) DeepCopy() *SnapshotRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SnapshotSchedule) DeepCopyInto(out *SnapshotSchedule) {
 	*out = *in
@@ -92,12 +580,62 @@ func (in *SnapshotScheduleList) DeepCopyObject() runtime.Object {
 func (in *SnapshotScheduleSpec) DeepCopyInto(out *SnapshotScheduleSpec) {
 	*out = *in
 	in.ClaimSelector.DeepCopyInto(&out.ClaimSelector)
+	if in.StorageClassSelector != nil {
+		in, out := &in.StorageClassSelector, &out.StorageClassSelector
+		*out = new(StorageClassSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClaimFilter != nil {
+		in, out := &in.ClaimFilter, &out.ClaimFilter
+		*out = new(ClaimFilterSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Retention.DeepCopyInto(&out.Retention)
+	if in.TimeZone != nil {
+		in, out := &in.TimeZone, &out.TimeZone
+		*out = new(string)
+		**out = **in
+	}
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.JitterSeconds != nil {
+		in, out := &in.JitterSeconds, &out.JitterSeconds
+		*out = new(int64)
+		**out = **in
+	}
 	if in.SnapshotTemplate != nil {
 		in, out := &in.SnapshotTemplate, &out.SnapshotTemplate
 		*out = new(SnapshotTemplateSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.GroupSnapshot != nil {
+		in, out := &in.GroupSnapshot, &out.GroupSnapshot
+		*out = new(GroupSnapshotSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(SnapshotHooksSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClaimFailure != nil {
+		in, out := &in.ClaimFailure, &out.ClaimFailure
+		*out = new(ClaimFailureSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailedSnapshots != nil {
+		in, out := &in.FailedSnapshots, &out.FailedSnapshots
+		*out = new(FailedSnapshotSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FinalSnapshot != nil {
+		in, out := &in.FinalSnapshot, &out.FinalSnapshot
+		*out = new(FinalSnapshotSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleSpec.
@@ -128,6 +666,42 @@ func (in *SnapshotScheduleStatus) DeepCopyInto(out *SnapshotScheduleStatus) {
 		in, out := &in.NextSnapshotTime, &out.NextSnapshotTime
 		*out = (*in).DeepCopy()
 	}
+	if in.RecentRuns != nil {
+		in, out := &in.RecentRuns, &out.RecentRuns
+		*out = make([]SnapshotRunStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastMissedTime != nil {
+		in, out := &in.LastMissedTime, &out.LastMissedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastTrigger != nil {
+		in, out := &in.LastTrigger, &out.LastTrigger
+		*out = new(TriggerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailedSnapshots != nil {
+		in, out := &in.FailedSnapshots, &out.FailedSnapshots
+		*out = make([]FailedSnapshotStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkippedClaims != nil {
+		in, out := &in.SkippedClaims, &out.SkippedClaims
+		*out = make([]SkippedClaimStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.BaselineClaims != nil {
+		in, out := &in.BaselineClaims, &out.BaselineClaims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleStatus.
@@ -166,3 +740,84 @@ func (in *SnapshotTemplateSpec) DeepCopy() *SnapshotTemplateSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassSelector) DeepCopyInto(out *StorageClassSelector) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassSelector.
+func (in *StorageClassSelector) DeepCopy() *StorageClassSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TieredRetentionSpec) DeepCopyInto(out *TieredRetentionSpec) {
+	*out = *in
+	if in.Hourly != nil {
+		in, out := &in.Hourly, &out.Hourly
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Daily != nil {
+		in, out := &in.Daily, &out.Daily
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Weekly != nil {
+		in, out := &in.Weekly, &out.Weekly
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Monthly != nil {
+		in, out := &in.Monthly, &out.Monthly
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Yearly != nil {
+		in, out := &in.Yearly, &out.Yearly
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TieredRetentionSpec.
+func (in *TieredRetentionSpec) DeepCopy() *TieredRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TieredRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TriggerStatus) DeepCopyInto(out *TriggerStatus) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TriggerStatus.
+func (in *TriggerStatus) DeepCopy() *TriggerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TriggerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
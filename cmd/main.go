@@ -18,11 +18,16 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
+	// Embed the time zone database so schedules' time zones can be loaded
+	// regardless of the base image.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,11 +35,14 @@ import (
 	"go.uber.org/zap/zapcore"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
 	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	kruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	ctrlMetrics "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -42,6 +50,7 @@ import (
 
 	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
 	"github.com/backube/snapscheduler/internal/controller"
+	webhooksnapschedulerv1 "github.com/backube/snapscheduler/internal/webhook/v1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -54,6 +63,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(snapv1.AddToScheme(scheme))
+	utilruntime.Must(groupsnapv1beta2.AddToScheme(scheme))
 
 	utilruntime.Must(snapschedulerv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
@@ -67,6 +77,14 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var enableOwnerReferences bool
+	var enableWebhooks bool
+	var enableHooks bool
+	var removeFinalizers bool
+	var scheduleDefaults webhooksnapschedulerv1.ScheduleDefaults
+	var defaultMaxCount int
+	var snapshotCreateQPS float64
+	var snapshotCreateBurst int
+	var maxConcurrentSnapshotCreates int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -76,7 +94,29 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
-	flag.BoolVar(&enableOwnerReferences, "enable-owner-references", false, "Enable owner references for VolumeSnapshots.")
+	flag.BoolVar(&enableOwnerReferences, "enable-owner-references", false,
+		"Deprecated: set the schedules' deletionPolicy instead. Makes Delete the default deletion policy.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks. A serving certificate must be provided.")
+	flag.BoolVar(&enableHooks, "enable-hooks", false,
+		"Run the schedules' pre- and post-snapshot hooks. Hooks run commands in pods and create Jobs using the "+
+			"controller's permissions. Requires webhooks.")
+	flag.StringVar(&scheduleDefaults.Retention.Expires, "default-retention-expires", "",
+		"Retention period applied to new schedules that don't specify a retention (e.g., 168h). Requires webhooks.")
+	flag.IntVar(&defaultMaxCount, "default-retention-max-count", 0,
+		"Maximum snapshot count applied to new schedules that don't specify a retention. Requires webhooks.")
+	flag.BoolVar(&scheduleDefaults.SelectSnapshotClass, "default-snapshot-class", true,
+		"Set the snapshotClassName of new schedules to the default VolumeSnapshotClass for their PVCs' CSI driver. "+
+			"Requires webhooks.")
+	flag.Float64Var(&snapshotCreateQPS, "snapshot-create-qps", 0,
+		"Maximum rate (per second) at which snapshots are created across all schedules. 0 means no limit.")
+	flag.IntVar(&snapshotCreateBurst, "snapshot-create-burst", 1,
+		"Number of snapshots that may be created in a burst above --snapshot-create-qps.")
+	flag.IntVar(&maxConcurrentSnapshotCreates, "max-concurrent-snapshot-creates", 0,
+		"Maximum number of snapshots being created across all schedules, counting those that aren't ready to use "+
+			"yet. 0 means no limit.")
+	flag.BoolVar(&removeFinalizers, "remove-finalizers", false,
+		"Remove snapscheduler's finalizer from all PVCs and exit. Run when uninstalling so the PVCs can be deleted.")
 	opts := zap.Options{
 		Development: true,
 		TimeEncoder: zapcore.RFC3339NanoTimeEncoder,
@@ -90,6 +130,26 @@ func main() {
 	setupLog.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	setupLog.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
 
+	if removeFinalizers {
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		if err = controller.ReleaseProtectedClaims(context.Background(), setupLog, c); err != nil {
+			setupLog.Error(err, "unable to remove finalizers")
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Only the webhook checks that the users setting hooks could carry them
+	// out themselves
+	if enableHooks && !enableWebhooks {
+		setupLog.Error(errors.New("--enable-hooks requires --enable-webhooks"), "invalid flags")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -128,14 +188,66 @@ func main() {
 		os.Exit(1)
 	}
 
+	defaultDeletionPolicy := snapschedulerv1.DeletionPolicyRetain
+	if enableOwnerReferences {
+		defaultDeletionPolicy = snapschedulerv1.DeletionPolicyDelete
+	}
+	// Shared by both controllers so that the limits apply across all schedules
+	creationLimiter := controller.NewSnapshotCreationLimiter(snapshotCreateQPS, snapshotCreateBurst,
+		maxConcurrentSnapshotCreates)
 	if err = (&controller.SnapshotScheduleReconciler{
 		Client:                mgr.GetClient(),
 		Scheme:                mgr.GetScheme(),
-		EnableOwnerReferences: enableOwnerReferences,
+		Recorder:              mgr.GetEventRecorder("snapscheduler"),
+		DefaultDeletionPolicy: defaultDeletionPolicy,
+		CreationLimiter:       creationLimiter,
+		EnableHooks:           enableHooks,
+		EnableFinalSnapshots:  enableWebhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SnapshotSchedule")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterSnapshotScheduleReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		Recorder:              mgr.GetEventRecorder("snapscheduler"),
+		DefaultDeletionPolicy: defaultDeletionPolicy,
+		CreationLimiter:       creationLimiter,
+		EnableHooks:           enableHooks,
+		EnableFinalSnapshots:  enableWebhooks,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterSnapshotSchedule")
+		os.Exit(1)
+	}
+	if err = (&controller.FinalSnapshotReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		Recorder:              mgr.GetEventRecorder("snapscheduler"),
+		DefaultDeletionPolicy: defaultDeletionPolicy,
+		CreationLimiter:       creationLimiter,
+		EnableFinalSnapshots:  enableWebhooks,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FinalSnapshot")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if defaultMaxCount != 0 {
+			scheduleDefaults.Retention.MaxCount = ptr.To(int32(defaultMaxCount))
+		}
+		if err = scheduleDefaults.Validate(); err != nil {
+			setupLog.Error(err, "invalid schedule defaults")
+			os.Exit(1)
+		}
+		if err = webhooksnapschedulerv1.SetupSnapshotScheduleWebhookWithManager(mgr, scheduleDefaults); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SnapshotSchedule")
+			os.Exit(1)
+		}
+		if err = webhooksnapschedulerv1.SetupFinalSnapshotWebhookWithManager(mgr, defaultDeletionPolicy,
+			creationLimiter); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "FinalSnapshot")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,117 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// takeBaselineSnapshots snapshots each of the schedule's PVCs that has yet to
+// be snapshotted by it, so that newly selected PVCs are protected before the
+// next scheduled time. PVCs are recorded in the schedule's status once they no
+// longer need a baseline, so each receives at most one. It reports whether any
+// snapshots were created.
+func takeBaselineSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	snapList []snapv1.VolumeSnapshot, logger logr.Logger, c client.Client, recorder events.EventRecorder,
+	enableOwnerReferences bool) (bool, error) {
+	if !schedule.Spec.SnapshotOnMatch || schedule.Spec.Disabled || schedule.Spec.DryRun ||
+		schedule.Spec.GroupSnapshot != nil {
+		schedule.Status.BaselineClaims = nil
+		return false, nil
+	}
+
+	pvcList, err := listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace,
+		&schedule.Spec.ClaimSelector, schedule.Spec.StorageClassSelector)
+	if err != nil {
+		logger.Error(err, "unable to get matching PVCs")
+		return false, err
+	}
+	snapshotted := groupSnapsByPVC(snapList)
+
+	// PVCs that are no longer selected are forgotten so that they receive a
+	// new baseline if they are selected again
+	created := false
+	baseline := make([]string, 0, len(pvcList.Items))
+	for _, pvc := range pvcList.Items {
+		if slices.Contains(schedule.Status.BaselineClaims, pvc.Name) || len(snapshotted[pvc.Name]) > 0 {
+			baseline = append(baseline, pvc.Name)
+			continue
+		}
+		reason, _, err := skipReason(ctx, schedule.Spec.ClaimFilter, &pvc, c)
+		if err != nil {
+			logger.Error(err, "unable to check PVC", "PVC", pvc.Name)
+			return created, err
+		}
+		if reason != "" {
+			// Wait until the PVC can be snapshotted
+			continue
+		}
+		if err := takeBaselineSnapshot(ctx, schedule, pvc, logger, c, recorder, enableOwnerReferences); err != nil {
+			return created, err
+		}
+		created = true
+		baseline = append(baseline, pvc.Name)
+		// Record the baseline right away in case a later PVC fails
+		schedule.Status.BaselineClaims = append(schedule.Status.BaselineClaims, pvc.Name)
+		slices.Sort(schedule.Status.BaselineClaims)
+	}
+	// The PVCs are listed in no particular order, so they're sorted to keep
+	// the status from changing on every reconcile
+	slices.Sort(baseline)
+	schedule.Status.BaselineClaims = baseline
+	return created, nil
+}
+
+// takeBaselineSnapshot creates the baseline snapshot of the PVC
+func takeBaselineSnapshot(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client, recorder events.EventRecorder,
+	enableOwnerReferences bool) error {
+	snapTime := time.Now().UTC().Truncate(time.Minute)
+	snapName := scheduleSnapshotName(pvc.Name, schedule, TypeBaseline+"-"+snapTime.Format(timeYYYYMMDDHHMMSS))
+	labels, snapshotClassName := snapshotSettings(schedule, &pvc, TypeBaseline)
+	snap := newSnapForClaim(snapName, pvc, schedule, snapTime, labels, snapshotClassName, enableOwnerReferences)
+	logger.Info("creating a baseline snapshot", "PVC", pvc.Name, "Snapshot", snapName)
+	if err := c.Create(ctx, snap); err != nil {
+		if kerrors.IsAlreadyExists(err) {
+			return nil
+		}
+		if _, throttled := creationThrottled(err); throttled {
+			return err
+		}
+		logger.Error(err, "while creating baseline snapshot", "name", snapName)
+		snapshotCreateErrorTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+		recordClaimEvent(recorder, schedule, &pvc, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed,
+			eventActionCreate, "Failed to create baseline snapshot %s: %v", snapName, err)
+		return err
+	}
+	snapshotCreateTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+	recordClaimEvent(recorder, schedule, &pvc, snap, corev1.EventTypeNormal, eventReasonSnapshotCreated,
+		eventActionCreate, "Created baseline snapshot %s", snapName)
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Taking baseline snapshots", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "weekly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule:        "@weekly",
+				SnapshotOnMatch: true,
+			},
+		}
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	createPVC := func(name string, bound bool) {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns.Name,
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		if bound {
+			bindPVC(pvc)
+		}
+	}
+	snapshots := func() []snapv1.VolumeSnapshot {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		return snapList
+	}
+	takeBaselines := func() bool {
+		took, err := takeBaselineSnapshots(context.TODO(), schedule, snapshots(), logger, k8sClient, capture, false)
+		Expect(err).NotTo(HaveOccurred())
+		return took
+	}
+
+	It("snapshots each newly selected PVC once", func() {
+		createPVC("data", true)
+		Expect(takeBaselines()).To(BeTrue())
+		snaps := snapshots()
+		Expect(snaps).To(HaveLen(1))
+		Expect(snaps[0].Name).To(HavePrefix("data-weekly-baseline-"))
+		Expect(snaps[0].Labels).To(HaveKeyWithValue(TypeKey, TypeBaseline))
+		Expect(schedule.Status.BaselineClaims).To(ConsistOf("data"))
+		Expect(capture.regarding(eventReasonSnapshotCreated)).To(ConsistOf(
+			"SnapshotSchedule/weekly", "PersistentVolumeClaim/data"))
+
+		// Even once the baseline is gone, it isn't taken again
+		Expect(k8sClient.Delete(context.TODO(), &snaps[0])).To(Succeed())
+		Expect(takeBaselines()).To(BeFalse())
+
+		createPVC("logs", true)
+		Expect(takeBaselines()).To(BeTrue())
+		Expect(snapshots()).To(HaveLen(1))
+		Expect(schedule.Status.BaselineClaims).To(ConsistOf("data", "logs"))
+	})
+	It("records the PVCs in a stable order", func() {
+		for _, name := range []string{"logs", "data", "app"} {
+			createPVC(name, true)
+		}
+		Expect(takeBaselines()).To(BeTrue())
+		Expect(schedule.Status.BaselineClaims).To(Equal([]string{"app", "data", "logs"}))
+		Expect(takeBaselines()).To(BeFalse())
+		Expect(schedule.Status.BaselineClaims).To(Equal([]string{"app", "data", "logs"}))
+	})
+	It("waits for PVCs to be bound", func() {
+		createPVC("data", false)
+		Expect(takeBaselines()).To(BeFalse())
+		Expect(schedule.Status.BaselineClaims).To(BeEmpty())
+	})
+	It("doesn't snapshot PVCs the schedule has already snapshotted", func() {
+		createPVC("data", true)
+		snapTime := time.Now().Truncate(time.Minute)
+		snap := newSnapForClaim(snapshotName("data", schedule.Name, snapTime),
+			corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+			schedule, snapTime, nil, nil, false)
+		Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+		Expect(takeBaselines()).To(BeFalse())
+		Expect(snapshots()).To(HaveLen(1))
+		Expect(schedule.Status.BaselineClaims).To(ConsistOf("data"))
+	})
+	It("forgets PVCs that are no longer selected", func() {
+		schedule.Status.BaselineClaims = []string{"deleted"}
+		Expect(takeBaselines()).To(BeFalse())
+		Expect(schedule.Status.BaselineClaims).To(BeEmpty())
+	})
+	It("doesn't take baselines unless enabled", func() {
+		schedule.Spec.SnapshotOnMatch = false
+		createPVC("data", true)
+		Expect(takeBaselines()).To(BeFalse())
+		Expect(snapshots()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/events"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+const (
+	// Number of times failed snapshots are retried if the schedule doesn't say
+	defaultClaimMaxRetries = 3
+	// Time between retries if the schedule doesn't say
+	defaultClaimRetryInterval = time.Minute
+)
+
+// claimFailurePolicy returns the schedule's effective policy and retry limits
+// for PVCs whose snapshots can't be created
+func claimFailurePolicy(schedule *snapschedulerv1.SnapshotSchedule) (snapschedulerv1.ClaimFailurePolicy,
+	int32, time.Duration) {
+	policy := snapschedulerv1.ClaimFailureRetry
+	maxRetries := int32(defaultClaimMaxRetries)
+	interval := defaultClaimRetryInterval
+	if spec := schedule.Spec.ClaimFailure; spec != nil {
+		if spec.Policy != "" {
+			policy = spec.Policy
+		}
+		if spec.MaxRetries != nil {
+			maxRetries = *spec.MaxRetries
+		}
+		if spec.RetryIntervalSeconds != nil {
+			interval = time.Duration(*spec.RetryIntervalSeconds) * time.Second
+		}
+	}
+	return policy, maxRetries, interval
+}
+
+// claimRetryWait returns how much longer to wait before retrying the PVCs
+// whose snapshots couldn't be created in the most recent attempt at the run
+func claimRetryWait(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time, runType string,
+	now time.Time) time.Duration {
+	run := findRun(schedule, snapTime, runType)
+	if run == nil || run.LastFailureTime == nil {
+		return 0
+	}
+	failing := false
+	for _, entry := range run.Snapshots {
+		if entry.Error != "" {
+			failing = true
+		}
+	}
+	if !failing {
+		return 0
+	}
+	_, _, interval := claimFailurePolicy(schedule)
+	return max(interval-now.Sub(run.LastFailureTime.Time), 0)
+}
+
+// abandonFailedClaims reports whether the schedule should move on to its next
+// snapshot time even though the snapshots of some PVCs couldn't be created.
+// Errors other than those of particular PVCs are always retried.
+func abandonFailedClaims(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time, runType string,
+	err error, logger logr.Logger, recorder events.EventRecorder) bool {
+	failed := failedClaims(err)
+	if len(failed) == 0 {
+		return false
+	}
+	attempts := int32(0)
+	if run := findRun(schedule, snapTime, runType); run != nil {
+		attempts = run.FailedAttempts
+	}
+	policy, maxRetries, _ := claimFailurePolicy(schedule)
+	if policy == snapschedulerv1.ClaimFailureRetry && attempts <= maxRetries {
+		logger.Info("will retry failed snapshots", "failed", len(failed), "attempts", attempts)
+		return false
+	}
+
+	logger.Info("giving up on failed snapshots", "failed", len(failed), "attempts", attempts, "policy", policy)
+	recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed, eventActionSchedule,
+		"Giving up on the snapshots of %d PVC(s) for %s after %d attempt(s)", len(failed),
+		snapTime.UTC().Format(time.RFC3339), attempts)
+	return true
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// claimFailingClient refuses to create snapshots of the named PVCs
+type claimFailingClient struct {
+	client.Client
+	failing map[string]bool
+}
+
+func (c *claimFailingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if snap, ok := obj.(*snapv1.VolumeSnapshot); ok && c.failing[*snap.Spec.Source.PersistentVolumeClaimName] {
+		return errors.New("quota exceeded")
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+var _ = Describe("Handling PVCs that can't be snapshotted", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	var c *claimFailingClient
+	var snapTime time.Time
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		for _, pvcName := range []string{"data", "logs"} {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pvcName,
+					Namespace: ns.Name,
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+			bindPVC(pvc)
+		}
+		snapTime = time.Now().Add(-time.Minute).Truncate(time.Minute).UTC()
+		next := metav1.NewTime(snapTime)
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+			Status: snapschedulerv1.SnapshotScheduleStatus{
+				NextSnapshotTime: &next,
+			},
+		}
+		capture = &capturingRecorder{}
+		c = &claimFailingClient{Client: k8sClient, failing: map[string]bool{"data": true}}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	snapshot := func() (ctrl.Result, error) {
+		return handleSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, logger, c, capture, false, nil)
+	}
+	snapNames := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+	// Pretend that the most recent failure happened long enough ago to retry
+	allowRetry := func() {
+		lastFailure := metav1.NewTime(time.Now().Add(-time.Hour))
+		schedule.Status.RecentRuns[0].LastFailureTime = &lastFailure
+	}
+
+	It("snapshots the PVCs that don't fail", func() {
+		_, err := snapshot()
+		Expect(err).To(HaveOccurred())
+		Expect(failedClaims(err)).To(HaveKey("data"))
+		Expect(failedClaims(err)).NotTo(HaveKey("logs"))
+		Expect(snapNames()).To(ConsistOf(snapshotName("logs", schedule.Name, snapTime)))
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.Snapshots).To(ConsistOf(
+			snapschedulerv1.PVCSnapshotStatus{PVCName: "data", Error: "quota exceeded"},
+			snapschedulerv1.PVCSnapshotStatus{PVCName: "logs", SnapshotName: snapshotName("logs", schedule.Name,
+				snapTime)},
+		))
+		// The schedule stays on the run so that it can be retried
+		Expect(schedule.Status.NextSnapshotTime.UTC()).To(Equal(snapTime))
+	})
+	It("waits between retries", func() {
+		_, err := snapshot()
+		Expect(err).To(HaveOccurred())
+		result, err := snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", defaultClaimRetryInterval))
+		Expect(schedule.Status.RecentRuns[0].FailedAttempts).To(Equal(int32(1)))
+	})
+	It("advances once a retry succeeds", func() {
+		_, err := snapshot()
+		Expect(err).To(HaveOccurred())
+		allowRetry()
+		delete(c.failing, "data")
+		_, err = snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapNames()).To(HaveLen(2))
+		Expect(schedule.Status.NextSnapshotTime.After(time.Now())).To(BeTrue())
+	})
+	It("gives up after the maximum number of retries", func() {
+		schedule.Spec.ClaimFailure = &snapschedulerv1.ClaimFailureSpec{MaxRetries: ptr.To[int32](1)}
+		_, err := snapshot()
+		Expect(err).To(HaveOccurred())
+		allowRetry()
+		_, err = snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.RecentRuns[0].FailedAttempts).To(Equal(int32(2)))
+		Expect(schedule.Status.RecentRuns[0].Error).To(ContainSubstring("quota exceeded"))
+		Expect(schedule.Status.NextSnapshotTime.After(time.Now())).To(BeTrue())
+		Expect(capture.events[len(capture.events)-1].note).To(
+			HavePrefix("Giving up on the snapshots of 1 PVC(s)"))
+	})
+	It("moves on right away with the Advance policy", func() {
+		schedule.Spec.ClaimFailure = &snapschedulerv1.ClaimFailureSpec{
+			Policy: snapschedulerv1.ClaimFailureAdvance,
+		}
+		_, err := snapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.NextSnapshotTime.After(time.Now())).To(BeTrue())
+		Expect(schedule.Status.RecentRuns[0].Snapshots[0].Error).To(Equal("quota exceeded"))
+	})
+	It("doesn't wait to retry runs that didn't fail", func() {
+		recordRun(schedule, snapTime, typeScheduled, nil, nil)
+		Expect(claimRetryWait(schedule, snapTime, typeScheduled, time.Now())).To(BeZero())
+	})
+})
@@ -0,0 +1,142 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// Maximum number of skipped PVCs listed in a schedule's status
+const maxSkippedClaims = 50
+
+//+kubebuilder:rbac:groups=core,resources=persistentvolumes,verbs=get;list;watch
+
+// filterClaims separates the PVCs that can be snapshotted from those that
+// can't, either because of their state or because the schedule's claimFilter
+// excludes them. The skipped PVCs are listed in the schedule's status.
+func filterClaims(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client) ([]corev1.PersistentVolumeClaim, error) {
+	eligible := make([]corev1.PersistentVolumeClaim, 0, len(pvcs))
+	var skipped []snapschedulerv1.SkippedClaimStatus
+	for _, pvc := range pvcs {
+		reason, message, err := skipReason(ctx, schedule.Spec.ClaimFilter, &pvc, c)
+		if err != nil {
+			logger.Error(err, "unable to check PVC", "PVC", pvc.Name)
+			return nil, err
+		}
+		if reason == "" {
+			eligible = append(eligible, pvc)
+			continue
+		}
+		logger.V(4).Info("skipping PVC", "PVC", pvc.Name, "reason", reason)
+		if len(skipped) < maxSkippedClaims {
+			skipped = append(skipped, snapschedulerv1.SkippedClaimStatus{
+				PVCName: pvc.Name,
+				Reason:  reason,
+				Message: message,
+			})
+		}
+	}
+	if len(skipped) > 0 {
+		logger.Info("skipping PVCs that can't be snapshotted", "count", len(pvcs)-len(eligible))
+	}
+	schedule.Status.SkippedClaims = skipped
+	return eligible, nil
+}
+
+// skipReason returns why the PVC shouldn't be snapshotted, or an empty reason
+// if it should be
+func skipReason(ctx context.Context, filter *snapschedulerv1.ClaimFilterSpec, pvc *corev1.PersistentVolumeClaim,
+	c client.Client) (string, string, error) {
+	if isClaimExcluded(pvc) {
+		return snapschedulerv1.SkippedClaimReasonExcluded, "the PVC is annotated with " + ExcludeKey, nil
+	}
+	if pvc.DeletionTimestamp != nil {
+		return snapschedulerv1.SkippedClaimReasonTerminating, "the PVC is being deleted", nil
+	}
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+	case corev1.ClaimLost:
+		return snapschedulerv1.SkippedClaimReasonLost, "the PVC's volume has been lost", nil
+	default:
+		return snapschedulerv1.SkippedClaimReasonNotBound, "the PVC isn't bound to a volume", nil
+	}
+	return filterReason(ctx, filter, pvc, c)
+}
+
+// isClaimExcluded reports whether the PVC is annotated to keep it from being
+// snapshotted
+func isClaimExcluded(pvc *corev1.PersistentVolumeClaim) bool {
+	excluded, err := strconv.ParseBool(pvc.Annotations[ExcludeKey])
+	return err == nil && excluded
+}
+
+// filterReason returns why the claimFilter excludes the PVC, or an empty
+// reason if it doesn't
+func filterReason(ctx context.Context, filter *snapschedulerv1.ClaimFilterSpec, pvc *corev1.PersistentVolumeClaim,
+	c client.Client) (string, string, error) {
+	if filter == nil {
+		return "", "", nil
+	}
+
+	if len(filter.AccessModes) > 0 && !slices.ContainsFunc(pvc.Spec.AccessModes,
+		func(mode corev1.PersistentVolumeAccessMode) bool { return slices.Contains(filter.AccessModes, mode) }) {
+		return snapschedulerv1.SkippedClaimReasonAccessMode,
+			fmt.Sprintf("none of the access modes %v is allowed", pvc.Spec.AccessModes), nil
+	}
+	if len(filter.VolumeModes) > 0 {
+		mode := corev1.PersistentVolumeFilesystem
+		if pvc.Spec.VolumeMode != nil {
+			mode = *pvc.Spec.VolumeMode
+		}
+		if !slices.Contains(filter.VolumeModes, mode) {
+			return snapschedulerv1.SkippedClaimReasonVolumeMode,
+				fmt.Sprintf("volume mode %s isn't allowed", mode), nil
+		}
+	}
+	if len(filter.CSIDrivers) > 0 {
+		pv := &corev1.PersistentVolume{}
+		err := c.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, pv)
+		if kerrors.IsNotFound(err) {
+			return snapschedulerv1.SkippedClaimReasonCSIDriver,
+				fmt.Sprintf("volume %s wasn't found", pvc.Spec.VolumeName), nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if pv.Spec.CSI == nil {
+			return snapschedulerv1.SkippedClaimReasonCSIDriver, "the volume isn't provisioned by a CSI driver", nil
+		}
+		if !slices.Contains(filter.CSIDrivers, pv.Spec.CSI.Driver) {
+			return snapschedulerv1.SkippedClaimReasonCSIDriver,
+				fmt.Sprintf("CSI driver %s isn't allowed", pv.Spec.CSI.Driver), nil
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// bindPVC marks the PVC as bound so that schedules will snapshot it
+func bindPVC(pvc *corev1.PersistentVolumeClaim) {
+	pvc.Status.Phase = corev1.ClaimBound
+	Expect(k8sClient.Status().Update(context.TODO(), pvc)).To(Succeed())
+}
+
+// createPVC creates a PVC in the namespace
+func createPVC(name string, namespace string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+	return pvc
+}
+
+var _ = Describe("Filtering a schedule's PVCs", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	newPVC := func(name string, phase corev1.PersistentVolumeClaimPhase) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns.Name,
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				VolumeName:  "pv-" + name,
+			},
+			Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+	}
+	names := func(pvcs []corev1.PersistentVolumeClaim) []string {
+		names := []string{}
+		for _, pvc := range pvcs {
+			names = append(names, pvc.Name)
+		}
+		return names
+	}
+
+	It("skips PVCs that aren't bound or are being deleted", func() {
+		terminating := newPVC("terminating", corev1.ClaimBound)
+		terminating.DeletionTimestamp = ptr.To(metav1.Now())
+		pvcs, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("bound", corev1.ClaimBound),
+			newPVC("pending", corev1.ClaimPending),
+			newPVC("lost", corev1.ClaimLost),
+			terminating,
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(pvcs)).To(ConsistOf("bound"))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(
+			HaveField("Reason", snapschedulerv1.SkippedClaimReasonNotBound),
+			HaveField("Reason", snapschedulerv1.SkippedClaimReasonLost),
+			HaveField("Reason", snapschedulerv1.SkippedClaimReasonTerminating),
+		))
+	})
+	It("skips PVCs that are annotated to be excluded", func() {
+		excluded := newPVC("excluded", corev1.ClaimBound)
+		excluded.Annotations = map[string]string{ExcludeKey: "true"}
+		included := newPVC("included", corev1.ClaimBound)
+		included.Annotations = map[string]string{ExcludeKey: "false"}
+		pvcs, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{excluded, included},
+			logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(pvcs)).To(ConsistOf("included"))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(snapschedulerv1.SkippedClaimStatus{
+			PVCName: "excluded",
+			Reason:  snapschedulerv1.SkippedClaimReasonExcluded,
+			Message: "the PVC is annotated with " + ExcludeKey,
+		}))
+	})
+	It("clears the skipped PVCs once they can be snapshotted", func() {
+		_, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("data", corev1.ClaimPending),
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.SkippedClaims).To(HaveLen(1))
+		_, err = filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("data", corev1.ClaimBound),
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.SkippedClaims).To(BeEmpty())
+	})
+	It("filters PVCs by access mode", func() {
+		shared := newPVC("shared", corev1.ClaimBound)
+		shared.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+		schedule.Spec.ClaimFilter = &snapschedulerv1.ClaimFilterSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteOncePod},
+		}
+		pvcs, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("data", corev1.ClaimBound), shared,
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(pvcs)).To(ConsistOf("data"))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(snapschedulerv1.SkippedClaimStatus{
+			PVCName: "shared",
+			Reason:  snapschedulerv1.SkippedClaimReasonAccessMode,
+			Message: "none of the access modes [ReadWriteMany] is allowed",
+		}))
+	})
+	It("filters PVCs by volume mode", func() {
+		block := newPVC("block", corev1.ClaimBound)
+		block.Spec.VolumeMode = ptr.To(corev1.PersistentVolumeBlock)
+		schedule.Spec.ClaimFilter = &snapschedulerv1.ClaimFilterSpec{
+			VolumeModes: []corev1.PersistentVolumeMode{corev1.PersistentVolumeFilesystem},
+		}
+		pvcs, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("data", corev1.ClaimBound), block,
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		// PVCs without a volume mode use Filesystem
+		Expect(names(pvcs)).To(ConsistOf("data"))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(
+			HaveField("Reason", snapschedulerv1.SkippedClaimReasonVolumeMode)))
+	})
+	It("filters PVCs by the CSI driver of their volume", func() {
+		for pvName, driver := range map[string]string{"pv-data": "fast.csi.example.com",
+			"pv-logs": "slow.csi.example.com", "pv-legacy": ""} {
+			pv := &corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: pvName},
+				Spec: corev1.PersistentVolumeSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Capacity: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+					PersistentVolumeSource: corev1.PersistentVolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/tmp"},
+					},
+				},
+			}
+			if driver != "" {
+				pv.Spec.PersistentVolumeSource = corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: pvName},
+				}
+			}
+			Expect(k8sClient.Create(context.TODO(), pv)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(context.TODO(), pv)).To(Succeed())
+			})
+		}
+		schedule.Spec.ClaimFilter = &snapschedulerv1.ClaimFilterSpec{
+			CSIDrivers: []string{"fast.csi.example.com"},
+		}
+		pvcs, err := filterClaims(context.TODO(), schedule, []corev1.PersistentVolumeClaim{
+			newPVC("data", corev1.ClaimBound),
+			newPVC("logs", corev1.ClaimBound),
+			newPVC("legacy", corev1.ClaimBound),
+			newPVC("missing", corev1.ClaimBound),
+		}, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(pvcs)).To(ConsistOf("data"))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(
+			snapschedulerv1.SkippedClaimStatus{PVCName: "logs", Reason: snapschedulerv1.SkippedClaimReasonCSIDriver,
+				Message: "CSI driver slow.csi.example.com isn't allowed"},
+			snapschedulerv1.SkippedClaimStatus{PVCName: "legacy", Reason: snapschedulerv1.SkippedClaimReasonCSIDriver,
+				Message: "the volume isn't provisioned by a CSI driver"},
+			snapschedulerv1.SkippedClaimStatus{PVCName: "missing", Reason: snapschedulerv1.SkippedClaimReasonCSIDriver,
+				Message: "volume pv-missing wasn't found"},
+		))
+	})
+	It("doesn't snapshot skipped PVCs", func() {
+		for _, pvcName := range []string{"data", "pending"} {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pvcName,
+					Namespace: ns.Name,
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+			if pvcName == "data" {
+				bindPVC(pvc)
+			}
+		}
+		next := metav1.Now()
+		schedule.Status.NextSnapshotTime = &next
+		_, err := handleSnapshotting(context.TODO(), schedule, next.UTC(), typeScheduled, logger, k8sClient,
+			&capturingRecorder{}, false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapList).To(HaveLen(1))
+		Expect(*snapList[0].Spec.Source.PersistentVolumeClaimName).To(Equal("data"))
+		Expect(schedule.Status.RecentRuns[0].Snapshots).To(HaveLen(1))
+		Expect(schedule.Status.SkippedClaims).To(ConsistOf(
+			HaveField("PVCName", "pending")))
+	})
+})
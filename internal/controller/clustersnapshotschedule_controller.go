@@ -0,0 +1,397 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// ClusterSnapshotScheduleReconciler reconciles a ClusterSnapshotSchedule object
+type ClusterSnapshotScheduleReconciler struct {
+	client.Client
+	Scheme                *runtime.Scheme
+	Recorder              events.EventRecorder
+	DefaultDeletionPolicy snapschedulerv1.DeletionPolicy
+	CreationLimiter       *SnapshotCreationLimiter
+	// EnableHooks allows the schedules' hooks to run. Hooks run commands in,
+	// and create Jobs in, the schedules' namespaces with the controller's
+	// permissions.
+	EnableHooks bool
+	// EnableFinalSnapshots allows the schedules to hold their PVCs for final
+	// snapshots, which are taken by the PVC webhook
+	EnableFinalSnapshots bool
+	trackers             map[scheduleID]*scheduleTracker
+	hooks                *hookRunner
+}
+
+//nolint:lll
+//+kubebuilder:rbac:groups=snapscheduler.backube,resources=clustersnapshotschedules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=snapscheduler.backube,resources=clustersnapshotschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=snapscheduler.backube,resources=clustersnapshotschedules/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+func (r *ClusterSnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Changes to PVCs and snapshots are reconciled in their namespace alone,
+	// which is carried in the request
+	reqLogger := log.FromContext(ctx).WithValues("clustersnapshotschedule", req.Name)
+	if req.Namespace != "" {
+		reqLogger = reqLogger.WithValues("namespace", req.Namespace)
+	}
+	reqLogger.Info("Reconciling ClusterSnapshotSchedule")
+
+	// Fetch the ClusterSnapshotSchedule instance
+	instance := &snapschedulerv1.ClusterSnapshotSchedule{}
+	err := r.Get(ctx, types.NamespacedName{Name: req.Name}, instance)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Clean up any lingering gauge metrics and tracker state.
+			for key := range r.trackers {
+				if key.name == req.Name {
+					r.forgetNamespace(key)
+				}
+			}
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, err
+	}
+
+	policy := scheduleDeletionPolicy(&instance.Spec.SnapshotScheduleSpec, r.DefaultDeletionPolicy)
+	deleting, err := handleDeletionPolicy(ctx, instance, policy, instance.Spec.DryRun, ClusterScheduleKey, "",
+		reqLogger, r.Client, r.Recorder)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if deleting {
+		for key := range r.trackers {
+			if key.name == req.Name {
+				r.forgetNamespace(key)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	prevStatus := instance.Status.DeepCopy()
+	result, err := r.reconcileNamespaces(ctx, instance, req.Namespace,
+		ownsSnapshots(&instance.Spec.SnapshotScheduleSpec, r.DefaultDeletionPolicy), reqLogger)
+	updateFinalSnapshotCondition(instance, &instance.Status.Conditions, &instance.Spec.SnapshotScheduleSpec,
+		r.EnableFinalSnapshots, r.Recorder)
+
+	// Update result in CR. Reconciling a single namespace doesn't clear the
+	// errors of the others.
+	if err != nil {
+		apimeta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    snapschedulerv1.ConditionReconciled,
+			Status:  metav1.ConditionFalse,
+			Reason:  snapschedulerv1.ReconciledReasonError,
+			Message: err.Error(),
+		})
+	} else if req.Namespace == "" || !anyNamespaceFailing(instance.Status.Namespaces) {
+		apimeta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:    snapschedulerv1.ConditionReconciled,
+			Status:  metav1.ConditionTrue,
+			Reason:  snapschedulerv1.ReconciledReasonComplete,
+			Message: "Reconcile complete",
+		})
+	}
+
+	// Update instance.Status, unless nothing changed. Writing an unchanged
+	// status would only trigger another reconcile.
+	if equality.Semantic.DeepEqual(prevStatus, &instance.Status) {
+		return result, err
+	}
+	err2 := r.Client.Status().Update(ctx, instance)
+	if err == nil { // Don't mask previous error
+		err = err2
+	}
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterSnapshotScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.trackers = make(map[scheduleID]*scheduleTracker)
+	if r.EnableHooks {
+		executor, err := newPodExecutor(mgr.GetConfig(), mgr.GetHTTPClient())
+		if err != nil {
+			return err
+		}
+		r.hooks = newHookRunner(executor)
+	}
+	// Changes to the schedule's PVCs and snapshots are picked up right away
+	// rather than at the next periodic reconcile
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&snapschedulerv1.ClusterSnapshotSchedule{}).
+		Watches(&corev1.PersistentVolumeClaim{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return clusterSchedulesForClaim(ctx, r.Client, obj)
+			})).
+		Watches(&snapv1.VolumeSnapshot{}, handler.EnqueueRequestsFromMapFunc(clusterScheduleForSnapshot)).
+		Complete(r)
+}
+
+// reconcileNamespaces applies the schedule in each of the selected namespaces,
+// recording the per-namespace results in the schedule's status. An error in
+// one namespace does not prevent the others from being processed. If a
+// namespace is given, the schedule is only applied there, and the status of
+// the other namespaces is left as it was.
+func (r *ClusterSnapshotScheduleReconciler) reconcileNamespaces(ctx context.Context,
+	cs *snapschedulerv1.ClusterSnapshotSchedule, namespace string, enableOwnerReferences bool,
+	logger logr.Logger) (ctrl.Result, error) {
+	nsList, err := selectedNamespaces(ctx, logger, r.Client, cs, namespace)
+	if err != nil {
+		logger.Error(err, "unable to get matching namespaces")
+		return ctrl.Result{}, err
+	}
+
+	previous := make(map[string]snapschedulerv1.NamespaceScheduleStatus, len(cs.Status.Namespaces))
+	for _, nsStatus := range cs.Status.Namespaces {
+		previous[nsStatus.Namespace] = nsStatus
+	}
+
+	var errs []error
+	result := ctrl.Result{}
+	statuses := make([]snapschedulerv1.NamespaceScheduleStatus, 0, len(nsList.Items))
+	for _, ns := range nsList.Items {
+		key := scheduleID{kind: snapschedulerv1.ClusterSnapshotScheduleKind, namespace: ns.Name, name: cs.Name}
+		tracker := r.trackerFor(key)
+		if tracker.status == nil {
+			// Pick up where the persisted summary left off
+			tracker.status = expandNamespaceStatus(previous[ns.Name])
+		}
+		schedule := scheduleForNamespace(cs, ns.Name, *tracker.status)
+		delete(previous, ns.Name)
+		nsLogger := logger.WithValues("namespace", ns.Name)
+		nsResult, err := doReconcile(ctx, schedule, nsLogger, r.CreationLimiter.Client(r.Client),
+			clusterScheduleRecorder{r.Recorder},
+			enableOwnerReferences, r.EnableFinalSnapshots, r.hooks, tracker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", ns.Name, err))
+			apimeta.SetStatusCondition(&schedule.Status.Conditions, metav1.Condition{
+				Type:    snapschedulerv1.ConditionReconciled,
+				Status:  metav1.ConditionFalse,
+				Reason:  snapschedulerv1.ReconciledReasonError,
+				Message: err.Error(),
+			})
+		} else {
+			apimeta.SetStatusCondition(&schedule.Status.Conditions, metav1.Condition{
+				Type:    snapschedulerv1.ConditionReconciled,
+				Status:  metav1.ConditionTrue,
+				Reason:  snapschedulerv1.ReconciledReasonComplete,
+				Message: "Reconcile complete",
+			})
+		}
+		tracker.status = &schedule.Status
+		statuses = append(statuses, summarizeNamespaceStatus(ns.Name, &schedule.Status))
+		if nsResult.RequeueAfter > 0 &&
+			(result.RequeueAfter == 0 || nsResult.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = nsResult.RequeueAfter
+		}
+	}
+	if namespace != "" {
+		for name, nsStatus := range previous {
+			if name != namespace {
+				statuses = append(statuses, nsStatus)
+				delete(previous, name)
+			}
+		}
+	}
+	// The namespaces are listed in no particular order, so they're sorted to
+	// keep the status from changing on every reconcile
+	slices.SortFunc(statuses, func(a, b snapschedulerv1.NamespaceScheduleStatus) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+	cs.Status.Namespaces = statuses
+
+	// Namespaces that are no longer selected keep their snapshots, but we stop
+	// tracking them.
+	for ns := range previous {
+		r.forgetNamespace(scheduleID{kind: snapschedulerv1.ClusterSnapshotScheduleKind, namespace: ns, name: cs.Name})
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// selectedNamespaces returns the namespaces that the cluster schedule selects.
+// If a namespace is given, only it is returned, provided it is selected.
+func selectedNamespaces(ctx context.Context, logger logr.Logger, c client.Client,
+	cs *snapschedulerv1.ClusterSnapshotSchedule, namespace string) (*corev1.NamespaceList, error) {
+	if namespace == "" {
+		return listNamespacesMatchingSelector(ctx, logger, c, &cs.Spec.NamespaceSelector)
+	}
+	nsList := &corev1.NamespaceList{}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nsList, client.IgnoreNotFound(err)
+	}
+	if ns.DeletionTimestamp.IsZero() && selectorMatches(&cs.Spec.NamespaceSelector, ns) {
+		nsList.Items = append(nsList.Items, *ns)
+	}
+	return nsList, nil
+}
+
+// anyNamespaceFailing reports whether the schedule failed to reconcile in any
+// of its namespaces
+func anyNamespaceFailing(statuses []snapschedulerv1.NamespaceScheduleStatus) bool {
+	for _, nsStatus := range statuses {
+		if apimeta.IsStatusConditionFalse(nsStatus.Conditions, snapschedulerv1.ConditionReconciled) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ClusterSnapshotScheduleReconciler) trackerFor(key scheduleID) *scheduleTracker {
+	t, exists := r.trackers[key]
+	if !exists {
+		t = &scheduleTracker{
+			readyUIDs: make(map[types.UID]struct{}),
+			prevPVCs:  make(map[string]struct{}),
+		}
+		r.trackers[key] = t
+	}
+	return t
+}
+
+// forgetNamespace removes the metrics and tracker state for the schedule
+// within a single namespace.
+func (r *ClusterSnapshotScheduleReconciler) forgetNamespace(key scheduleID) {
+	cleanupScheduleGauges(key)
+	delete(r.trackers, key)
+	r.hooks.forget(key)
+}
+
+// scheduleForNamespace returns a SnapshotSchedule that carries out the cluster
+// schedule within the given namespace. It is never persisted; it only allows
+// the namespaced snapshotting and expiration logic to be reused. Its Events
+// are redirected to the cluster schedule by clusterScheduleRecorder.
+func scheduleForNamespace(cs *snapschedulerv1.ClusterSnapshotSchedule, namespace string,
+	status snapschedulerv1.SnapshotScheduleStatus) *snapschedulerv1.SnapshotSchedule {
+	return &snapschedulerv1.SnapshotSchedule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: snapschedulerv1.GroupVersion.String(),
+			Kind:       snapschedulerv1.ClusterSnapshotScheduleKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cs.Name,
+			Namespace: namespace,
+			UID:       cs.UID,
+			// Carries the manual trigger annotation
+			Annotations: cs.Annotations,
+		},
+		Spec:   *cs.Spec.SnapshotScheduleSpec.DeepCopy(),
+		Status: *status.DeepCopy(),
+	}
+}
+
+// summarizeNamespaceStatus returns the summary of a cluster schedule's status
+// within a namespace that is recorded in the cluster schedule's status
+func summarizeNamespaceStatus(namespace string,
+	status *snapschedulerv1.SnapshotScheduleStatus) snapschedulerv1.NamespaceScheduleStatus {
+	summary := snapschedulerv1.NamespaceScheduleStatus{
+		Namespace:         namespace,
+		Conditions:        status.Conditions,
+		LastSnapshotTime:  status.LastSnapshotTime,
+		NextSnapshotTime:  status.NextSnapshotTime,
+		MissedRuns:        status.MissedRuns,
+		LastMissedTime:    status.LastMissedTime,
+		LastTrigger:       status.LastTrigger,
+		FailedSnapshots:   int32(len(status.FailedSnapshots)),
+		SkippedClaims:     int32(len(status.SkippedClaims)),
+		OrphanedClaims:    status.OrphanedClaims,
+		OrphanedSnapshots: status.OrphanedSnapshots,
+		RecentRuns:        latestRuns(status.RecentRuns),
+		BaselineClaims:    status.BaselineClaims,
+	}
+	return *summary.DeepCopy()
+}
+
+// latestRuns returns the most recent run of each type, newest first. Only
+// these are needed to carry on with a run that is underway.
+func latestRuns(runs []snapschedulerv1.SnapshotRunStatus) []snapschedulerv1.SnapshotRunStatus {
+	var latest []snapschedulerv1.SnapshotRunStatus
+	seen := make(map[string]bool, 2)
+	for i := range runs {
+		if runType := runTypeOf(&runs[i]); !seen[runType] {
+			seen[runType] = true
+			latest = append(latest, runs[i])
+		}
+	}
+	return latest
+}
+
+// expandNamespaceStatus returns the status of a cluster schedule within a
+// namespace from its persisted summary. The details that aren't summarized,
+// such as the older runs, start out empty.
+func expandNamespaceStatus(summary snapschedulerv1.NamespaceScheduleStatus) *snapschedulerv1.SnapshotScheduleStatus {
+	summary = *summary.DeepCopy()
+	return &snapschedulerv1.SnapshotScheduleStatus{
+		Conditions:       summary.Conditions,
+		LastSnapshotTime: summary.LastSnapshotTime,
+		NextSnapshotTime: summary.NextSnapshotTime,
+		MissedRuns:       summary.MissedRuns,
+		LastMissedTime:   summary.LastMissedTime,
+		LastTrigger:      summary.LastTrigger,
+		RecentRuns:       summary.RecentRuns,
+		BaselineClaims:   summary.BaselineClaims,
+	}
+}
+
+// listNamespacesMatchingSelector retrieves the namespaces that match the given
+// selector, skipping any that are being deleted
+func listNamespacesMatchingSelector(ctx context.Context, logger logr.Logger, c client.Client,
+	ls *metav1.LabelSelector) (*corev1.NamespaceList, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return nil, err
+	}
+	allNamespaces := &corev1.NamespaceList{}
+	if err = c.List(ctx, allNamespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	nsList := &corev1.NamespaceList{}
+	for _, ns := range allNamespaces.Items {
+		if ns.DeletionTimestamp.IsZero() {
+			nsList.Items = append(nsList.Items, ns)
+		}
+	}
+	logger.Info("Created list of matching namespaces", "count", len(nsList.Items))
+	return nsList, nil
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Applying a cluster schedule to a namespace", func() {
+	var cs *snapschedulerv1.ClusterSnapshotSchedule
+	BeforeEach(func() {
+		cs = &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-hourly",
+				UID:  "bb327b3e-2e87-4e0a-9b42-12d61c08bf97",
+			},
+			Spec: snapschedulerv1.ClusterSnapshotScheduleSpec{
+				SnapshotScheduleSpec: snapschedulerv1.SnapshotScheduleSpec{
+					Schedule: "0 * * * *",
+					Retention: snapschedulerv1.SnapshotRetentionSpec{
+						MaxCount: ptr.To[int32](3),
+					},
+				},
+			},
+		}
+	})
+	It("carries over the spec and per-namespace status", func() {
+		next := metav1.NewTime(time.Now())
+		status := snapschedulerv1.SnapshotScheduleStatus{NextSnapshotTime: &next}
+		schedule := scheduleForNamespace(cs, "myns", status)
+		Expect(schedule.Name).To(Equal(cs.Name))
+		Expect(schedule.Namespace).To(Equal("myns"))
+		Expect(schedule.UID).To(Equal(cs.UID))
+		Expect(schedule.Kind).To(Equal(snapschedulerv1.ClusterSnapshotScheduleKind))
+		Expect(schedule.Spec).To(Equal(cs.Spec.SnapshotScheduleSpec))
+		Expect(schedule.Status.NextSnapshotTime.Time).To(Equal(next.Time))
+
+		// Modifying the result must not alter the cluster schedule
+		*schedule.Spec.Retention.MaxCount = 10
+		Expect(*cs.Spec.Retention.MaxCount).To(Equal(int32(3)))
+	})
+	It("persists only a summary of the per-namespace status", func() {
+		next := metav1.NewTime(time.Now())
+		manual := snapschedulerv1.SnapshotRunStatus{
+			ScheduledTime:  metav1.NewTime(next.Add(-time.Minute)),
+			Trigger:        "backup-1",
+			SnapshotCount:  1,
+			FailedAttempts: 1,
+		}
+		scheduled := snapschedulerv1.SnapshotRunStatus{
+			ScheduledTime: metav1.NewTime(next.Add(-time.Hour)),
+			Snapshots:     []snapschedulerv1.PVCSnapshotStatus{{PVCName: "data", Error: "quota exceeded"}},
+			SnapshotCount: 1,
+		}
+		older := snapschedulerv1.SnapshotRunStatus{ScheduledTime: metav1.NewTime(next.Add(-2 * time.Hour))}
+		status := snapschedulerv1.SnapshotScheduleStatus{
+			NextSnapshotTime: &next,
+			MissedRuns:       2,
+			RecentRuns:       []snapschedulerv1.SnapshotRunStatus{manual, scheduled, older},
+			FailedSnapshots:  []snapschedulerv1.FailedSnapshotStatus{{Name: "data-1", PVCName: "data"}},
+			BaselineClaims:   []string{"data"},
+		}
+		summary := summarizeNamespaceStatus("myns", &status)
+		Expect(summary).To(Equal(snapschedulerv1.NamespaceScheduleStatus{
+			Namespace:        "myns",
+			NextSnapshotTime: &next,
+			MissedRuns:       2,
+			FailedSnapshots:  1,
+			RecentRuns:       []snapschedulerv1.SnapshotRunStatus{manual, scheduled},
+			BaselineClaims:   []string{"data"},
+		}))
+		// What the runs underway and the baselines need survives a restart,
+		// while the older runs and the details of failures are lost
+		Expect(expandNamespaceStatus(summary)).To(Equal(&snapschedulerv1.SnapshotScheduleStatus{
+			NextSnapshotTime: &next,
+			MissedRuns:       2,
+			RecentRuns:       []snapschedulerv1.SnapshotRunStatus{manual, scheduled},
+			BaselineClaims:   []string{"data"},
+		}))
+	})
+	It("labels snapshots with the cluster schedule key", func() {
+		schedule := scheduleForNamespace(cs, "myns", snapschedulerv1.SnapshotScheduleStatus{})
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mypvc",
+				Namespace: "myns",
+			},
+		}
+		snap := newSnapForClaim("mysnap", pvc, schedule, time.Now(), nil, nil, true)
+		Expect(snap.Labels).To(HaveKeyWithValue(ClusterScheduleKey, cs.Name))
+		Expect(snap.Labels).NotTo(HaveKey(ScheduleKey))
+		Expect(snap.OwnerReferences).To(HaveLen(1))
+		Expect(snap.OwnerReferences[0].Kind).To(Equal(snapschedulerv1.ClusterSnapshotScheduleKind))
+		Expect(snap.OwnerReferences[0].UID).To(Equal(cs.UID))
+	})
+})
+
+var _ = Describe("Finding snapshots created by a cluster schedule", func() {
+	var ns *corev1.Namespace
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		for name, key := range map[string]string{"namespaced": ScheduleKey, "cluster": ClusterScheduleKey} {
+			snap := &snapv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+					Labels: map[string]string{
+						key: "hourly",
+					},
+				},
+				Spec: snapv1.VolumeSnapshotSpec{
+					Source: snapv1.VolumeSnapshotSource{
+						PersistentVolumeClaimName: ptr.To("dummy"),
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	It("doesn't confuse them with those of a same-named SnapshotSchedule", func() {
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "hourly"},
+		}
+		schedule := scheduleForNamespace(cs, ns.Name, snapschedulerv1.SnapshotScheduleStatus{})
+		Eventually(func() []string {
+			snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			names := []string{}
+			for _, snap := range snapList {
+				names = append(names, snap.Name)
+			}
+			return names
+		}, timeout, interval).Should(ConsistOf("cluster"))
+	})
+	It("names them apart from those of a same-named SnapshotSchedule", func() {
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "hourly"},
+		}
+		pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}}
+		snapTime := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+		for _, schedule := range []*snapschedulerv1.SnapshotSchedule{
+			{ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: ns.Name}},
+			scheduleForNamespace(cs, ns.Name, snapschedulerv1.SnapshotScheduleStatus{}),
+		} {
+			Expect(snapshotClaims(context.TODO(), schedule, snapTime, typeScheduled,
+				[]corev1.PersistentVolumeClaim{pvc}, logger, k8sClient, &capturingRecorder{}, false)).To(Succeed())
+		}
+		snapList := &snapv1.VolumeSnapshotList{}
+		Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns.Name),
+			client.HasLabels{WhenKey})).To(Succeed())
+		names := []string{}
+		for _, snap := range snapList.Items {
+			names = append(names, snap.Name)
+		}
+		Expect(names).To(ConsistOf("data-hourly-202403010200", "data-hourly-cluster-202403010200"))
+	})
+})
+
+var _ = Describe("Listing namespaces by selector", func() {
+	var namespaces []*corev1.Namespace
+	var tier string
+	BeforeEach(func() {
+		// A unique value keeps namespaces from other tests out of the results
+		tier = "tier-" + time.Now().Format("150405.000000")
+		namespaces = []*corev1.Namespace{}
+		for _, protect := range []string{"yes", "yes", "no"} {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "test-",
+					Labels: map[string]string{
+						"tier":    tier,
+						"protect": protect,
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+			namespaces = append(namespaces, ns)
+		}
+	})
+	AfterEach(func() {
+		for _, ns := range namespaces {
+			Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+		}
+	})
+	It("finds only the matching namespaces", func() {
+		sel := &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"tier":    tier,
+				"protect": "yes",
+			},
+		}
+		Eventually(func() []string {
+			nsList, err := listNamespacesMatchingSelector(context.TODO(), logger, k8sClient, sel)
+			Expect(err).NotTo(HaveOccurred())
+			names := []string{}
+			for _, ns := range nsList.Items {
+				names = append(names, ns.Name)
+			}
+			return names
+		}, timeout, interval).Should(ConsistOf(namespaces[0].Name, namespaces[1].Name))
+	})
+	It("records the namespaces' statuses in a stable order", func() {
+		r := &ClusterSnapshotScheduleReconciler{
+			Client:   k8sClient,
+			Recorder: &capturingRecorder{},
+			trackers: make(map[scheduleID]*scheduleTracker),
+		}
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily"},
+			Spec: snapschedulerv1.ClusterSnapshotScheduleSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": tier}},
+				SnapshotScheduleSpec: snapschedulerv1.SnapshotScheduleSpec{
+					Schedule: "@daily",
+				},
+			},
+		}
+		_, err := r.reconcileNamespaces(context.TODO(), cs, "", false, logger)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, nsStatus := range cs.Status.Namespaces {
+			names = append(names, nsStatus.Namespace)
+		}
+		Expect(names).To(HaveLen(3))
+		Expect(slices.IsSorted(names)).To(BeTrue())
+		// The full status of each namespace is kept in memory
+		key := scheduleID{kind: snapschedulerv1.ClusterSnapshotScheduleKind, namespace: names[0], name: cs.Name}
+		Expect(r.trackers[key].status.NextSnapshotTime).To(Equal(cs.Status.Namespaces[0].NextSnapshotTime))
+	})
+	It("reconciles a single namespace, leaving the others as they were", func() {
+		r := &ClusterSnapshotScheduleReconciler{
+			Client:   k8sClient,
+			Recorder: &capturingRecorder{},
+			trackers: make(map[scheduleID]*scheduleTracker),
+		}
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily"},
+			Spec: snapschedulerv1.ClusterSnapshotScheduleSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": tier}},
+				SnapshotScheduleSpec: snapschedulerv1.SnapshotScheduleSpec{
+					Schedule: "@daily",
+				},
+			},
+		}
+		for _, ns := range namespaces {
+			cs.Status.Namespaces = append(cs.Status.Namespaces, snapschedulerv1.NamespaceScheduleStatus{
+				Namespace: ns.Name,
+			})
+		}
+		slices.SortFunc(cs.Status.Namespaces, func(a, b snapschedulerv1.NamespaceScheduleStatus) int {
+			return strings.Compare(a.Namespace, b.Namespace)
+		})
+		_, err := r.reconcileNamespaces(context.TODO(), cs, namespaces[1].Name, false, logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs.Status.Namespaces).To(HaveLen(3))
+		for _, nsStatus := range cs.Status.Namespaces {
+			if nsStatus.Namespace == namespaces[1].Name {
+				Expect(nsStatus.NextSnapshotTime).NotTo(BeNil())
+			} else {
+				Expect(nsStatus.NextSnapshotTime).To(BeNil())
+			}
+		}
+		Expect(r.trackers).To(HaveLen(1))
+
+		// Namespaces that aren't selected aren't reconciled
+		_, err = r.reconcileNamespaces(context.TODO(), cs, "default", false, logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs.Status.Namespaces).To(HaveLen(3))
+		Expect(r.trackers).To(HaveLen(1))
+	})
+	It("skips namespaces that are being deleted", func() {
+		Expect(k8sClient.Delete(context.TODO(), namespaces[0])).To(Succeed())
+		namespaces = namespaces[1:]
+		sel := &metav1.LabelSelector{
+			MatchLabels: map[string]string{"tier": tier},
+		}
+		Eventually(func() []string {
+			nsList, err := listNamespacesMatchingSelector(context.TODO(), logger, k8sClient, sel)
+			Expect(err).NotTo(HaveOccurred())
+			names := []string{}
+			for _, ns := range nsList.Items {
+				names = append(names, ns.Name)
+			}
+			return names
+		}, timeout, interval).Should(ConsistOf(namespaces[0].Name, namespaces[1].Name))
+	})
+})
@@ -0,0 +1,220 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// scheduleDeletionPolicy returns the deletion policy in effect for the
+// schedule, falling back to the controller's default
+func scheduleDeletionPolicy(spec *snapschedulerv1.SnapshotScheduleSpec,
+	defaultPolicy snapschedulerv1.DeletionPolicy) snapschedulerv1.DeletionPolicy {
+	if spec.DeletionPolicy != "" {
+		return spec.DeletionPolicy
+	}
+	if defaultPolicy != "" {
+		return defaultPolicy
+	}
+	return snapschedulerv1.DeletionPolicyRetain
+}
+
+// ownsSnapshots reports whether the schedule's snapshots are given owner
+// references to it, so that they are garbage collected along with it. That's
+// only the case for schedules that rely on the deprecated
+// --enable-owner-references flag (which makes Delete the default policy)
+// rather than setting a deletionPolicy. Otherwise the ScheduleFinalizer alone
+// applies the policy, since garbage collection would also delete the snapshots
+// that the policy keeps.
+func ownsSnapshots(spec *snapschedulerv1.SnapshotScheduleSpec, defaultPolicy snapschedulerv1.DeletionPolicy) bool {
+	return spec.DeletionPolicy == "" && defaultPolicy == snapschedulerv1.DeletionPolicyDelete
+}
+
+// handleDeletionPolicy keeps the ScheduleFinalizer in step with the schedule's
+// deletion policy and, once the schedule is being deleted, applies the policy
+// to its snapshots before releasing it. The finalizer is only needed by
+// policies that delete snapshots, so schedules that retain them can always be
+// deleted. The schedule's snapshots are those labeled with its name under
+// labelKey, within the namespace (or all namespaces, if it is empty). In
+// dry-run mode, the snapshots that the policy would delete are only reported.
+// It reports whether the schedule is being deleted, in which case there is
+// nothing more to reconcile.
+func handleDeletionPolicy(ctx context.Context, schedule client.Object, policy snapschedulerv1.DeletionPolicy,
+	dryRun bool, labelKey string, namespace string, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder) (bool, error) {
+	deleting := !schedule.GetDeletionTimestamp().IsZero()
+	hasFinalizer := controllerutil.ContainsFinalizer(schedule, ScheduleFinalizer)
+	switch {
+	case deleting && hasFinalizer:
+		logger.Info("applying deletion policy", "policy", policy)
+	case !deleting && policy == snapschedulerv1.DeletionPolicyRetain && hasFinalizer:
+		// Snapshots taken under a previous policy may be owned by the
+		// schedule, so they need to be released from it
+	case !deleting && policy != snapschedulerv1.DeletionPolicyRetain && !hasFinalizer:
+		controllerutil.AddFinalizer(schedule, ScheduleFinalizer)
+		if err := c.Update(ctx, schedule); err != nil {
+			logger.Error(err, "unable to add finalizer to schedule")
+			return false, err
+		}
+		return false, nil
+	default:
+		return deleting, nil
+	}
+
+	if err := applyDeletionPolicy(ctx, schedule, policy, dryRun, labelKey, namespace, logger, c,
+		recorder); err != nil {
+		return deleting, err
+	}
+	controllerutil.RemoveFinalizer(schedule, ScheduleFinalizer)
+	if err := c.Update(ctx, schedule); err != nil && !kerrors.IsNotFound(err) {
+		logger.Error(err, "unable to remove finalizer from schedule")
+		return deleting, err
+	}
+	return deleting, nil
+}
+
+// applyDeletionPolicy deletes the schedule's snapshots that the policy doesn't
+// keep, releasing the rest from the schedule's ownership so that they outlive
+// it
+func applyDeletionPolicy(ctx context.Context, schedule client.Object, policy snapschedulerv1.DeletionPolicy,
+	dryRun bool, labelKey string, namespace string, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder) error {
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels{labelKey: schedule.GetName()},
+	}
+	snapList := &snapv1.VolumeSnapshotList{}
+	if err := c.List(ctx, snapList, listOpts...); err != nil {
+		logger.Error(err, "unable to retrieve list of snapshots")
+		return err
+	}
+	if err := disposeSnapshots(ctx, schedule, policy, dryRun, snapList.Items, func(snap *snapv1.VolumeSnapshot) string {
+		pvcName := ""
+		if snap.Spec.Source.PersistentVolumeClaimName != nil {
+			pvcName = *snap.Spec.Source.PersistentVolumeClaimName
+		}
+		return snap.Namespace + "/" + pvcName
+	}, logger, c, recorder); err != nil {
+		return err
+	}
+
+	groupSnapList := &groupsnapv1beta2.VolumeGroupSnapshotList{}
+	if err := c.List(ctx, groupSnapList, listOpts...); err != nil {
+		if apimeta.IsNoMatchError(err) {
+			return nil
+		}
+		logger.Error(err, "unable to retrieve list of group snapshots")
+		return err
+	}
+	return disposeSnapshots(ctx, schedule, policy, dryRun, groupSnapList.Items,
+		func(groupSnap *groupsnapv1beta2.VolumeGroupSnapshot) string {
+			return groupSnap.Namespace
+		}, logger, c, recorder)
+}
+
+// disposeSnapshots applies the deletion policy to the snapshots. For
+// RetainLatest, the newest snapshot of each group (as determined by groupKey)
+// is kept, preferring those that are ready to use. Pinned and final snapshots
+// are always kept. In dry-run mode, all of the snapshots are kept, and those
+// that would have been deleted are reported via Events instead.
+func disposeSnapshots[S any, P snapshotObject[S]](ctx context.Context, schedule client.Object,
+	policy snapschedulerv1.DeletionPolicy, dryRun bool, snaps []S, groupKey func(P) string, logger logr.Logger,
+	c client.Client, recorder events.EventRecorder) error {
+	latest := make(map[string]struct{})
+	if policy == snapschedulerv1.DeletionPolicyRetainLatest {
+		grouped := make(map[string][]S)
+		for i := range snaps {
+			key := groupKey(P(&snaps[i]))
+			grouped[key] = append(grouped[key], snaps[i])
+		}
+		for _, list := range grouped {
+			candidates := filterReadySnaps[S, P](list)
+			if len(candidates) == 0 {
+				candidates = list
+			}
+			candidates = sortSnapsByScheduledTime[S, P](candidates)
+			newest := P(&candidates[len(candidates)-1])
+			latest[newest.GetNamespace()+"/"+newest.GetName()] = struct{}{}
+		}
+	}
+
+	now := time.Now()
+	deleted := 0
+	for i := range snaps {
+		snap := P(&snaps[i])
+		_, isLatest := latest[snap.GetNamespace()+"/"+snap.GetName()]
+		keep := policy == snapschedulerv1.DeletionPolicyRetain || isLatest || isSnapshotPinned(snap, now) ||
+			isFinalSnapshot(snap)
+		if keep || dryRun {
+			if err := disownSnapshot(ctx, schedule, snap, c); err != nil {
+				logger.Error(err, "unable to release snapshot from schedule", "name", snap.GetName())
+				return err
+			}
+			if !keep {
+				recorder.Eventf(schedule, nil, corev1.EventTypeNormal, eventReasonDryRun, eventActionExpire,
+					"Would delete snapshot %s per the %s deletion policy", snap.GetName(), policy)
+			}
+			continue
+		}
+		err := c.Delete(ctx, snap, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "error deleting snapshot", "name", snap.GetName())
+			return err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		logger.Info("deleted snapshots per deletion policy", "policy", policy, "count", deleted)
+		recorder.Eventf(schedule, nil, corev1.EventTypeNormal, eventReasonSnapshotExpired, eventActionExpire,
+			"Deleted %d snapshot(s) per the %s deletion policy", deleted, policy)
+	}
+	return nil
+}
+
+// disownSnapshot removes the schedule from the snapshot's owner references so
+// that the snapshot isn't garbage collected along with the schedule
+func disownSnapshot(ctx context.Context, schedule client.Object, snap client.Object, c client.Client) error {
+	refs := snap.GetOwnerReferences()
+	kept := slices.DeleteFunc(slices.Clone(refs), func(ref metav1.OwnerReference) bool {
+		return ref.UID == schedule.GetUID()
+	})
+	if len(kept) == len(refs) {
+		return nil
+	}
+	patch := client.MergeFrom(snap.DeepCopyObject().(client.Object))
+	snap.SetOwnerReferences(kept)
+	return client.IgnoreNotFound(c.Patch(ctx, snap, patch))
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Applying a schedule's deletion policy", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: snapschedulerv1.GroupVersion.String(),
+				Kind:       "SnapshotSchedule",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), schedule)).To(Succeed())
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	handle := func(policy snapschedulerv1.DeletionPolicy) bool {
+		deleting, err := handleDeletionPolicy(context.TODO(), schedule, policy, schedule.Spec.DryRun, ScheduleKey,
+			ns.Name, logger, k8sClient, capture)
+		Expect(err).NotTo(HaveOccurred())
+		return deleting
+	}
+	// createSnapshots creates two snapshots of each PVC, owned by the
+	// schedule, with the newest one of "logs" pinned
+	createSnapshots := func() {
+		for i := range 2 {
+			snapTime := time.Date(2024, 3, 1, i, 0, 0, 0, time.UTC)
+			for _, pvcName := range []string{"data", "logs"} {
+				snap := newSnapForClaim(snapshotName(pvcName, schedule.Name, snapTime),
+					corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: ns.Name}},
+					schedule, snapTime, nil, nil, true)
+				if pvcName == "logs" && i == 1 {
+					snap.Annotations = map[string]string{RetainKey: "true"}
+				}
+				Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+				snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+			}
+		}
+	}
+	snapshots := func() map[string][]metav1.OwnerReference {
+		snapList := &snapv1.VolumeSnapshotList{}
+		Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns.Name))).To(Succeed())
+		owners := make(map[string][]metav1.OwnerReference, len(snapList.Items))
+		for _, snap := range snapList.Items {
+			owners[snap.Name] = snap.OwnerReferences
+		}
+		return owners
+	}
+	deleteSchedule := func() {
+		Expect(k8sClient.Delete(context.TODO(), schedule)).To(Succeed())
+		Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(schedule), schedule)).To(Succeed())
+		Expect(schedule.DeletionTimestamp).NotTo(BeNil())
+	}
+	scheduleGone := func() bool {
+		err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(schedule), &snapschedulerv1.SnapshotSchedule{})
+		return kerrors.IsNotFound(err)
+	}
+
+	It("falls back to the controller's default policy", func() {
+		Expect(scheduleDeletionPolicy(&schedule.Spec, "")).To(Equal(snapschedulerv1.DeletionPolicyRetain))
+		Expect(scheduleDeletionPolicy(&schedule.Spec, snapschedulerv1.DeletionPolicyDelete)).To(
+			Equal(snapschedulerv1.DeletionPolicyDelete))
+		schedule.Spec.DeletionPolicy = snapschedulerv1.DeletionPolicyRetainLatest
+		Expect(scheduleDeletionPolicy(&schedule.Spec, snapschedulerv1.DeletionPolicyDelete)).To(
+			Equal(snapschedulerv1.DeletionPolicyRetainLatest))
+	})
+	It("only owns the snapshots of schedules that rely on the deprecated flag", func() {
+		Expect(ownsSnapshots(&schedule.Spec, "")).To(BeFalse())
+		Expect(ownsSnapshots(&schedule.Spec, snapschedulerv1.DeletionPolicyDelete)).To(BeTrue())
+		// The finalizer applies an explicit policy, so that the snapshots it
+		// keeps aren't garbage collected
+		schedule.Spec.DeletionPolicy = snapschedulerv1.DeletionPolicyDelete
+		Expect(ownsSnapshots(&schedule.Spec, snapschedulerv1.DeletionPolicyDelete)).To(BeFalse())
+		Expect(ownsSnapshots(&schedule.Spec, "")).To(BeFalse())
+	})
+	It("only holds schedules whose policy deletes snapshots", func() {
+		Expect(handle(snapschedulerv1.DeletionPolicyRetain)).To(BeFalse())
+		Expect(schedule.Finalizers).To(BeEmpty())
+		Expect(handle(snapschedulerv1.DeletionPolicyDelete)).To(BeFalse())
+		Expect(schedule.Finalizers).To(ConsistOf(ScheduleFinalizer))
+	})
+	It("deletes the snapshots with the Delete policy", func() {
+		createSnapshots()
+		handle(snapschedulerv1.DeletionPolicyDelete)
+		deleteSchedule()
+		Expect(handle(snapschedulerv1.DeletionPolicyDelete)).To(BeTrue())
+		// Pinned snapshots are kept, but no longer owned by the schedule
+		Expect(snapshots()).To(Equal(map[string][]metav1.OwnerReference{"logs-hourly-202403010100": nil}))
+		Expect(scheduleGone()).To(BeTrue())
+		Expect(capture.regarding(eventReasonSnapshotExpired)).To(ConsistOf("SnapshotSchedule/hourly"))
+	})
+	It("keeps the newest snapshot of each PVC with the RetainLatest policy", func() {
+		createSnapshots()
+		handle(snapschedulerv1.DeletionPolicyRetainLatest)
+		deleteSchedule()
+		Expect(handle(snapschedulerv1.DeletionPolicyRetainLatest)).To(BeTrue())
+		Expect(snapshots()).To(Equal(map[string][]metav1.OwnerReference{
+			"data-hourly-202403010100": nil,
+			"logs-hourly-202403010100": nil,
+		}))
+		Expect(scheduleGone()).To(BeTrue())
+	})
+	It("keeps final snapshots", func() {
+		createSnapshots()
+		snapTime := time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC)
+		snap := newSnapForClaim("data-hourly-final",
+			corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+			schedule, snapTime, nil, nil, true)
+		snap.Labels[TypeKey] = TypeFinal
+		Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+		handle(snapschedulerv1.DeletionPolicyDelete)
+		deleteSchedule()
+		Expect(handle(snapschedulerv1.DeletionPolicyDelete)).To(BeTrue())
+		Expect(snapshots()).To(Equal(map[string][]metav1.OwnerReference{
+			"data-hourly-final":        nil,
+			"logs-hourly-202403010100": nil,
+		}))
+	})
+	It("only reports the snapshots it would delete in dry-run mode", func() {
+		createSnapshots()
+		schedule.Spec.DryRun = true
+		handle(snapschedulerv1.DeletionPolicyDelete)
+		deleteSchedule()
+		Expect(handle(snapschedulerv1.DeletionPolicyDelete)).To(BeTrue())
+		owners := snapshots()
+		Expect(owners).To(HaveLen(4))
+		for _, refs := range owners {
+			Expect(refs).To(BeEmpty())
+		}
+		Expect(scheduleGone()).To(BeTrue())
+		Expect(capture.regarding(eventReasonDryRun)).To(HaveLen(3))
+		Expect(capture.regarding(eventReasonSnapshotExpired)).To(BeEmpty())
+	})
+	It("releases the snapshots when the policy changes to Retain", func() {
+		createSnapshots()
+		handle(snapschedulerv1.DeletionPolicyDelete)
+		Expect(handle(snapschedulerv1.DeletionPolicyRetain)).To(BeFalse())
+		Expect(schedule.Finalizers).To(BeEmpty())
+		owners := snapshots()
+		Expect(owners).To(HaveLen(4))
+		for _, refs := range owners {
+			Expect(refs).To(BeEmpty())
+		}
+	})
+})
@@ -0,0 +1,139 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// Upper bound on the number of snapshots listed in the dry-run status
+const maxDryRunSnapshots = 100
+
+// dryRunStatus returns the schedule's dry-run status, creating it if necessary
+func dryRunStatus(schedule *snapschedulerv1.SnapshotSchedule) *snapschedulerv1.DryRunStatus {
+	if schedule.Status.DryRun == nil {
+		schedule.Status.DryRun = &snapschedulerv1.DryRunStatus{}
+	}
+	return schedule.Status.DryRun
+}
+
+// previewSnapshots reports the snapshots that the run at snapTime would create
+// in place of creating them. Hooks are not run. The schedule then moves on as
+// if the run had completed.
+func previewSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder) (ctrl.Result, error) {
+	status := dryRunStatus(schedule)
+	lastRunTime := metav1.NewTime(snapTime)
+	status.LastRunTime = &lastRunTime
+	status.WouldCreate = nil
+
+	if schedule.Spec.GroupSnapshot != nil {
+		if len(pvcs) > 0 {
+			name := groupSnapshotName(schedule, snapTime, runType)
+			key := types.NamespacedName{Name: name, Namespace: schedule.Namespace}
+			exists, err := objectExists(ctx, c, key, &groupsnapv1beta2.VolumeGroupSnapshot{})
+			if err != nil {
+				logger.Error(err, "looking for group snapshot", "name", name)
+				return ctrl.Result{}, err
+			}
+			if !exists {
+				status.WouldCreate = append(status.WouldCreate, name)
+				recorder.Eventf(schedule, nil, corev1.EventTypeNormal, eventReasonDryRun, eventActionCreate,
+					"Would create group snapshot %s of %d PVC(s)", name, len(pvcs))
+			}
+		}
+	} else {
+		for i := range pvcs {
+			pvc := &pvcs[i]
+			name := scheduleSnapshotName(pvc.Name, schedule, runNameSuffix(snapTime, runType))
+			key := types.NamespacedName{Name: name, Namespace: pvc.Namespace}
+			exists, err := objectExists(ctx, c, key, &snapv1.VolumeSnapshot{})
+			if err != nil {
+				logger.Error(err, "looking for snapshot", "name", name)
+				return ctrl.Result{}, err
+			}
+			if !exists {
+				status.WouldCreate = append(status.WouldCreate, name)
+				recordClaimEvent(recorder, schedule, pvc, nil, corev1.EventTypeNormal, eventReasonDryRun,
+					eventActionCreate, "Would create snapshot %s", name)
+			}
+		}
+	}
+	logger.Info("dry run: not creating snapshots", "wouldCreate", status.WouldCreate)
+	return advanceSchedule(schedule, runType, logger)
+}
+
+// objectExists reports whether the object with the given key exists
+func objectExists(ctx context.Context, c client.Client, key types.NamespacedName, obj client.Object) (bool, error) {
+	err := c.Get(ctx, key, obj)
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// beginDryRunExpiration clears the list of snapshots that retention would
+// delete so that it can be recomputed. The previous list is returned.
+func beginDryRunExpiration(schedule *snapschedulerv1.SnapshotSchedule) []string {
+	status := dryRunStatus(schedule)
+	previous := status.WouldExpire
+	status.WouldExpire = nil
+	status.WouldExpireCount = 0
+	return previous
+}
+
+// recordWouldExpire notes that retention would delete the snapshot. A snapshot
+// may be selected by more than one retention rule, but it is only counted once.
+func recordWouldExpire(schedule *snapschedulerv1.SnapshotSchedule, name string) {
+	status := dryRunStatus(schedule)
+	if slices.Contains(status.WouldExpire, name) {
+		return
+	}
+	status.WouldExpireCount++
+	if len(status.WouldExpire) < maxDryRunSnapshots {
+		status.WouldExpire = append(status.WouldExpire, name)
+	}
+}
+
+// reportWouldExpire emits an Event for each snapshot that retention would
+// delete that wasn't already in the previous list
+func reportWouldExpire(schedule *snapschedulerv1.SnapshotSchedule, previous []string,
+	recorder events.EventRecorder) {
+	for _, name := range dryRunStatus(schedule).WouldExpire {
+		if !slices.Contains(previous, name) {
+			recorder.Eventf(schedule, nil, corev1.EventTypeNormal, eventReasonDryRun, eventActionExpire,
+				"Would delete snapshot %s per retention policy", name)
+		}
+	}
+}
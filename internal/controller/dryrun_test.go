@@ -0,0 +1,174 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Running a schedule in dry-run mode", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		for _, pvcName := range []string{"data", "logs"} {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pvcName,
+					Namespace: ns.Name,
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+			bindPVC(pvc)
+		}
+		next := metav1.NewTime(time.Now().Add(time.Hour))
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+				DryRun:   true,
+			},
+			Status: snapschedulerv1.SnapshotScheduleStatus{
+				NextSnapshotTime: &next,
+			},
+		}
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	reconcile := func() {
+		_, err := doReconcile(context.TODO(), schedule, logger, k8sClient, capture, false, true, nil, &scheduleTracker{
+			readyUIDs: make(map[types.UID]struct{}),
+			prevPVCs:  make(map[string]struct{}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+	snapNames := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+
+	It("reports the snapshots it would create", func() {
+		snapTime, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		_, err := handleSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, logger, k8sClient, capture,
+			false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapNames()).To(BeEmpty())
+		Expect(schedule.Status.DryRun.WouldCreate).To(ConsistOf("data-hourly-202403010200",
+			"logs-hourly-202403010200"))
+		Expect(schedule.Status.DryRun.LastRunTime.UTC()).To(Equal(snapTime))
+		Expect(capture.regarding(eventReasonDryRun)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data",
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/logs",
+		))
+		// The schedule moves on without recording a run
+		Expect(schedule.Status.NextSnapshotTime.After(time.Now())).To(BeTrue())
+		Expect(schedule.Status.RecentRuns).To(BeEmpty())
+	})
+	It("names the snapshots of a manual run as the run would", func() {
+		snapTime, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		schedule.Status.LastTrigger = &snapschedulerv1.TriggerStatus{Token: "1", Time: metav1.NewTime(snapTime)}
+		_, err := handleSnapshotting(context.TODO(), schedule, snapTime, TypeManual, logger, k8sClient, capture,
+			false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.DryRun.WouldCreate).To(ConsistOf("data-hourly-202403010200-manual",
+			"logs-hourly-202403010200-manual"))
+		Expect(schedule.Status.LastTrigger.Completed).To(BeTrue())
+	})
+	It("doesn't run hooks", func() {
+		schedule.Spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{
+				Exec: &snapschedulerv1.ExecHook{Command: []string{"false"}},
+			},
+		}
+		snapTime, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		_, err := handleSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, logger, k8sClient, capture,
+			false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.DryRun.WouldCreate).To(HaveLen(2))
+	})
+
+	Context("with existing snapshots", func() {
+		BeforeEach(func() {
+			for i := range 3 {
+				snapTime := time.Date(2024, 3, 1, i, 0, 0, 0, time.UTC)
+				snap := newSnapForClaim(snapshotName("data", schedule.Name, snapTime),
+					corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+					schedule, snapTime, nil, nil, false)
+				Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+				snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+			}
+			schedule.Spec.Retention.MaxCount = ptr.To[int32](1)
+		})
+
+		It("reports the snapshots retention would delete", func() {
+			reconcile()
+			Expect(snapNames()).To(HaveLen(3))
+			Expect(schedule.Status.DryRun.WouldExpire).To(ConsistOf("data-hourly-202403010000",
+				"data-hourly-202403010100"))
+			Expect(schedule.Status.DryRun.WouldExpireCount).To(Equal(int32(2)))
+			Expect(capture.regarding(eventReasonDryRun)).To(HaveLen(2))
+			Expect(capture.regarding(eventReasonSnapshotExpired)).To(BeEmpty())
+
+			// Snapshots are only reported via Events the first time
+			reconcile()
+			Expect(capture.regarding(eventReasonDryRun)).To(HaveLen(2))
+		})
+		It("counts snapshots selected by several rules once", func() {
+			schedule.Spec.Retention.Tiers = &snapschedulerv1.TieredRetentionSpec{Hourly: ptr.To[int32](1)}
+			reconcile()
+			Expect(schedule.Status.DryRun.WouldExpire).To(HaveLen(2))
+			Expect(schedule.Status.DryRun.WouldExpireCount).To(Equal(int32(2)))
+		})
+		It("deletes snapshots once dry-run mode is turned off", func() {
+			reconcile()
+			schedule.Spec.DryRun = false
+			reconcile()
+			Expect(snapNames()).To(ConsistOf("data-hourly-202403010200"))
+			Expect(schedule.Status.DryRun).To(BeNil())
+		})
+	})
+})
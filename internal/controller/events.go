@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// Reasons and actions of the Events emitted by the controller
+const (
+	eventReasonSnapshotCreated = "SnapshotCreated"
+	eventReasonSnapshotFailed  = "SnapshotFailed"
+	eventReasonSnapshotExpired = "SnapshotExpired"
+	eventReasonInvalidSchedule = "InvalidSchedule"
+	eventReasonMissedSchedule  = "MissedSchedule"
+	eventReasonSnapshotError   = "SnapshotError"
+	eventReasonSnapshotStuck   = "SnapshotStuck"
+	eventReasonSnapshotRetried = "SnapshotRetried"
+	eventReasonDryRun          = "DryRun"
+	eventReasonFinalSnapshot   = "FinalSnapshotDisabled"
+	eventActionCreate          = "CreateSnapshot"
+	eventActionExpire          = "DeleteSnapshot"
+	eventActionSchedule        = "ScheduleSnapshot"
+	eventActionMonitor         = "MonitorSnapshot"
+)
+
+// sourceClaim fetches the named PVC so that Events can be attached to it.
+// Events only show up with the PVC if they refer to it by its UID, so nil is
+// returned if the PVC can't be fetched (e.g., because it has been deleted).
+func sourceClaim(ctx context.Context, c client.Client, namespace string, name string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, pvc); err != nil {
+		if !kerrors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "unable to get PVC for Event", "PVC", name)
+		}
+		return nil
+	}
+	return pvc
+}
+
+// recordClaimEvent emits an Event on the schedule and, if a PVC is supplied,
+// a matching Event on the PVC. The related object (e.g., the snapshot) may be
+// nil.
+func recordClaimEvent(recorder events.EventRecorder, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc *corev1.PersistentVolumeClaim, related runtime.Object, eventtype, reason, action, note string,
+	args ...any) {
+	recorder.Eventf(schedule, related, eventtype, reason, action, note, args...)
+	if pvc != nil {
+		recorder.Eventf(pvc, related, eventtype, reason, action, note, args...)
+	}
+}
+
+// clusterScheduleRecorder records the Events regarding the SnapshotSchedules
+// that carry out a ClusterSnapshotSchedule in each of its namespaces (see
+// scheduleForNamespace) against the ClusterSnapshotSchedule itself, since
+// they don't exist in the cluster. The namespace is noted in the message.
+type clusterScheduleRecorder struct {
+	events.EventRecorder
+}
+
+func (r clusterScheduleRecorder) Eventf(regarding runtime.Object, related runtime.Object, eventtype, reason,
+	action, note string, args ...any) {
+	if schedule, ok := regarding.(*snapschedulerv1.SnapshotSchedule); ok &&
+		schedule.Kind == snapschedulerv1.ClusterSnapshotScheduleKind {
+		regarding = &snapschedulerv1.ClusterSnapshotSchedule{
+			TypeMeta:   schedule.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{Name: schedule.Name, UID: schedule.UID},
+		}
+		note = "Namespace %s: " + note
+		args = append([]any{schedule.Namespace}, args...)
+	}
+	r.EventRecorder.Eventf(regarding, related, eventtype, reason, action, note, args...)
+}
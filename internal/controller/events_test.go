@@ -0,0 +1,233 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// capturedEvent is an Event, along with the kind and name of the object it
+// regards
+type capturedEvent struct {
+	regarding string
+	eventtype string
+	reason    string
+	note      string
+}
+
+// capturingRecorder keeps the Events it is asked to emit, along with the
+// objects they regard
+type capturingRecorder struct {
+	mu      sync.Mutex
+	events  []capturedEvent
+	objects []runtime.Object
+}
+
+func (r *capturingRecorder) Eventf(regarding runtime.Object, _ runtime.Object, eventtype, reason, _, note string,
+	args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := ""
+	if accessor, err := meta.Accessor(regarding); err == nil {
+		name = accessor.GetName()
+	}
+	kind := fmt.Sprintf("%T", regarding)
+	switch regarding.(type) {
+	case *snapschedulerv1.SnapshotSchedule:
+		kind = "SnapshotSchedule"
+	case *snapschedulerv1.ClusterSnapshotSchedule:
+		kind = "ClusterSnapshotSchedule"
+	case *corev1.PersistentVolumeClaim:
+		kind = "PersistentVolumeClaim"
+	}
+	r.events = append(r.events, capturedEvent{
+		regarding: kind + "/" + name,
+		eventtype: eventtype,
+		reason:    reason,
+		note:      fmt.Sprintf(note, args...),
+	})
+	r.objects = append(r.objects, regarding)
+}
+
+// regarding returns the objects for which Events with the reason were emitted
+func (r *capturingRecorder) regarding(reason string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	objects := []string{}
+	for _, event := range r.events {
+		if event.reason == reason {
+			objects = append(objects, event.regarding)
+		}
+	}
+	return objects
+}
+
+var _ = Describe("Recording events", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+
+	It("reports on both the schedule and the PVC", func() {
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}}
+		recordClaimEvent(capture, schedule, pvc, nil, corev1.EventTypeNormal,
+			eventReasonSnapshotCreated, eventActionCreate, "Created snapshot %s", "mysnap")
+		Expect(capture.events).To(ConsistOf(
+			capturedEvent{"SnapshotSchedule/hourly", corev1.EventTypeNormal, eventReasonSnapshotCreated,
+				"Created snapshot mysnap"},
+			capturedEvent{"PersistentVolumeClaim/data", corev1.EventTypeNormal, eventReasonSnapshotCreated,
+				"Created snapshot mysnap"},
+		))
+	})
+	It("reports on the cluster schedule rather than its namespaces", func() {
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-hourly", UID: "cs-uid"},
+		}
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}}
+		recorder := clusterScheduleRecorder{capture}
+		recordClaimEvent(recorder, scheduleForNamespace(cs, ns.Name, snapschedulerv1.SnapshotScheduleStatus{}), pvc,
+			nil, corev1.EventTypeNormal, eventReasonSnapshotCreated, eventActionCreate, "Created snapshot %s", "mysnap")
+		// Other schedules are left alone
+		recorder.Eventf(schedule, nil, corev1.EventTypeNormal, eventReasonSnapshotCreated, eventActionCreate,
+			"Created snapshot %s", "othersnap")
+		Expect(capture.events).To(ConsistOf(
+			capturedEvent{"ClusterSnapshotSchedule/cluster-hourly", corev1.EventTypeNormal,
+				eventReasonSnapshotCreated, "Namespace " + ns.Name + ": Created snapshot mysnap"},
+			capturedEvent{"PersistentVolumeClaim/data", corev1.EventTypeNormal, eventReasonSnapshotCreated,
+				"Created snapshot mysnap"},
+			capturedEvent{"SnapshotSchedule/hourly", corev1.EventTypeNormal, eventReasonSnapshotCreated,
+				"Created snapshot othersnap"},
+		))
+		// The Event must resolve to the cluster-scoped object
+		Expect(capture.objects[0]).To(HaveField("ObjectMeta", And(
+			HaveField("UID", cs.UID), HaveField("Namespace", ""))))
+	})
+	It("attaches Events to the PVC by its UID", func() {
+		pvc := createPVC("data", ns.Name)
+		Eventually(func() *corev1.PersistentVolumeClaim {
+			return sourceClaim(context.TODO(), k8sClient, ns.Name, "data")
+		}, timeout, interval).Should(HaveField("UID", pvc.UID))
+		// Deleted PVCs don't get Events
+		Expect(sourceClaim(context.TODO(), k8sClient, ns.Name, "gone")).To(BeNil())
+	})
+	It("reports snapshot creation", func() {
+		pvcs := []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: ns.Name}},
+		}
+		snapTime, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		Expect(snapshotClaims(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient, capture,
+			false)).To(Succeed())
+		Expect(capture.regarding(eventReasonSnapshotCreated)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data",
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/logs",
+		))
+	})
+	It("uses the snapshot class a PVC asks for", func() {
+		schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{SnapshotClassName: ptr.To("default")}
+		pvcs := []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name,
+				Annotations: map[string]string{SnapshotClassKey: "fast"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: ns.Name}},
+		}
+		snapTime, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		Expect(snapshotClaims(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient, capture,
+			false)).To(Succeed())
+		classes := map[string]string{}
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		for _, snap := range snapList {
+			classes[*snap.Spec.Source.PersistentVolumeClaimName] = *snap.Spec.VolumeSnapshotClassName
+		}
+		Expect(classes).To(Equal(map[string]string{"data": "fast", "logs": "default"}))
+	})
+	It("reports expired snapshots", func() {
+		pvc := createPVC("data", ns.Name)
+		snap := snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data-hourly-202403010200",
+				Namespace: ns.Name,
+			},
+			Spec: snapv1.VolumeSnapshotSpec{
+				Source: snapv1.VolumeSnapshotSource{
+					PersistentVolumeClaimName: ptr.To("data"),
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), &snap)).To(Succeed())
+		Eventually(func() *corev1.PersistentVolumeClaim {
+			return sourceClaim(context.TODO(), k8sClient, ns.Name, "data")
+		}, timeout, interval).Should(HaveField("UID", pvc.UID))
+		Expect(deleteSnapshots(context.TODO(), schedule, []snapv1.VolumeSnapshot{snap}, logger, k8sClient,
+			capture)).To(Succeed())
+		Expect(capture.regarding(eventReasonSnapshotExpired)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data",
+		))
+	})
+	It("reports an invalid schedule", func() {
+		schedule.Spec.Schedule = "not a cronspec"
+		_, err := doReconcile(context.TODO(), schedule, logger, k8sClient, capture, false, true, nil,
+			&scheduleTracker{})
+		Expect(err).To(HaveOccurred())
+		Expect(capture.regarding(eventReasonInvalidSchedule)).To(ConsistOf("SnapshotSchedule/hourly"))
+	})
+	It("reports missed snapshot times", func() {
+		next := metav1.NewTime(time.Now().Add(-3 * time.Hour).Truncate(time.Hour))
+		schedule.Status.NextSnapshotTime = &next
+		_, err := doReconcile(context.TODO(), schedule, logger, k8sClient, capture, false, true, nil,
+			&scheduleTracker{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capture.regarding(eventReasonMissedSchedule)).To(ConsistOf("SnapshotSchedule/hourly"))
+		Expect(capture.events[0].note).To(HavePrefix("Missed 3 scheduled snapshot time(s)"))
+	})
+})
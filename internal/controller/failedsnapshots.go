@@ -0,0 +1,274 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+const (
+	// How long a snapshot may take to become ready before it is considered
+	// stuck, if the schedule doesn't say
+	defaultStuckAfter = time.Hour
+	// Upper bound on the number of failed snapshots listed in the status
+	maxFailedSnapshots = 20
+)
+
+// failedSnapshot is a snapshot that has failed or is stuck, along with why
+type failedSnapshot struct {
+	snap   *snapv1.VolumeSnapshot
+	status snapschedulerv1.FailedSnapshotStatus
+}
+
+// handleFailedSnapshots reports the schedule's snapshots that have failed or
+// are stuck. If enabled, those belonging to the most recent run are replaced,
+// and failed snapshots are deleted once their grace period has passed. It
+// returns true if any snapshots were created or deleted.
+func handleFailedSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	snaps []snapv1.VolumeSnapshot, now time.Time, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder) (bool, error) {
+	spec := schedule.Spec.FailedSnapshots
+	if spec == nil {
+		spec = &snapschedulerv1.FailedSnapshotSpec{}
+	}
+	stuckAfter := defaultStuckAfter
+	if spec.StuckAfter != "" {
+		var err error
+		if stuckAfter, err = parseRetentionDuration(spec.StuckAfter); err != nil {
+			logger.Error(err, "unable to parse stuckAfter duration", "stuckAfter", spec.StuckAfter)
+			return false, err
+		}
+	}
+
+	failed := findFailedSnapshots(snaps, now, stuckAfter)
+	reportFailedSnapshots(ctx, schedule, failed, stuckAfter, c, recorder)
+
+	changed := false
+	// Replacements aren't previewed in dry-run mode
+	if spec.MaxRetries != nil && *spec.MaxRetries > 0 && !schedule.Spec.DryRun {
+		retried, err := retryFailedSnapshots(ctx, schedule, failed, int(*spec.MaxRetries), logger, c, recorder)
+		changed = retried
+		if err != nil {
+			return changed, err
+		}
+	}
+	if spec.GracePeriod != "" {
+		gracePeriod, err := parseRetentionDuration(spec.GracePeriod)
+		if err != nil {
+			logger.Error(err, "unable to parse gracePeriod duration", "gracePeriod", spec.GracePeriod)
+			return changed, err
+		}
+		expired := filterFailedBefore(failed, now.Add(-gracePeriod), now)
+		if len(expired) > 0 {
+			logger.Info("deleting failed snapshots", "count", len(expired))
+			if err := deleteSnapshots(ctx, schedule, expired, logger, c, recorder); err != nil {
+				return changed, err
+			}
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// findFailedSnapshots returns the snapshots that have reported an error or
+// that have not become ready within stuckAfter of being created, newest first
+func findFailedSnapshots(snaps []snapv1.VolumeSnapshot, now time.Time,
+	stuckAfter time.Duration) []failedSnapshot {
+	failed := []failedSnapshot{}
+	for i := range snaps {
+		snap := &snaps[i]
+		if isSnapshotReady(snap) || snap.DeletionTimestamp != nil {
+			continue
+		}
+		status := snapschedulerv1.FailedSnapshotStatus{Name: snap.Name}
+		if snap.Spec.Source.PersistentVolumeClaimName != nil {
+			status.PVCName = *snap.Spec.Source.PersistentVolumeClaimName
+		}
+		switch {
+		case snap.Status != nil && snap.Status.Error != nil:
+			status.Reason = snapschedulerv1.FailedSnapshotReasonError
+			status.Message = snapshotErrorMessage(snap.Status.Error)
+		case snap.CreationTimestamp.Add(stuckAfter).Before(now):
+			status.Reason = snapschedulerv1.FailedSnapshotReasonStuck
+			status.Message = fmt.Sprintf("not ready to use after %s", stuckAfter)
+		default:
+			continue
+		}
+		failed = append(failed, failedSnapshot{snap: snap, status: status})
+	}
+	// Snapshots of the same run share a creation time (to the second), so the
+	// name keeps the order stable across reconciles
+	sort.Slice(failed, func(i, j int) bool {
+		ti, tj := failed[i].snap.CreationTimestamp, failed[j].snap.CreationTimestamp
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti)
+		}
+		return failed[i].snap.Name < failed[j].snap.Name
+	})
+	return failed
+}
+
+// reportFailedSnapshots lists the failed snapshots in the schedule's status,
+// emitting an Event for each one that wasn't listed before
+func reportFailedSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	failed []failedSnapshot, stuckAfter time.Duration, c client.Client, recorder events.EventRecorder) {
+	previous := make(map[string]string, len(schedule.Status.FailedSnapshots))
+	for _, status := range schedule.Status.FailedSnapshots {
+		previous[status.Name] = status.Reason
+	}
+
+	var statuses []snapschedulerv1.FailedSnapshotStatus
+	for _, f := range failed {
+		if len(statuses) == maxFailedSnapshots {
+			break
+		}
+		statuses = append(statuses, f.status)
+		if previous[f.status.Name] == f.status.Reason {
+			continue
+		}
+		pvc := sourceClaim(ctx, c, f.snap.Namespace, f.status.PVCName)
+		if f.status.Reason == snapschedulerv1.FailedSnapshotReasonError {
+			recordClaimEvent(recorder, schedule, pvc, f.snap, corev1.EventTypeWarning, eventReasonSnapshotError,
+				eventActionMonitor, "Snapshot %s failed: %s", f.snap.Name, f.status.Message)
+		} else {
+			recordClaimEvent(recorder, schedule, pvc, f.snap, corev1.EventTypeWarning, eventReasonSnapshotStuck,
+				eventActionMonitor, "Snapshot %s has not become ready to use after %s", f.snap.Name, stuckAfter)
+		}
+	}
+	schedule.Status.FailedSnapshots = statuses
+}
+
+// retryFailedSnapshots replaces the failed snapshots of the most recent run,
+// up to maxRetries times per PVC. The run history is updated to refer to the
+// replacements.
+func retryFailedSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	failed []failedSnapshot, maxRetries int, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder) (bool, error) {
+	if len(schedule.Status.RecentRuns) == 0 {
+		return false, nil
+	}
+	failedByName := make(map[string]*snapv1.VolumeSnapshot, len(failed))
+	for _, f := range failed {
+		failedByName[f.snap.Name] = f.snap
+	}
+
+	created := false
+	run := &schedule.Status.RecentRuns[0]
+	for i := range run.Snapshots {
+		entry := &run.Snapshots[i]
+		snap, found := failedByName[entry.SnapshotName]
+		if !found || entry.SnapshotName == "" {
+			continue
+		}
+		attempt := retryAttempt(snap) + 1
+		if attempt > maxRetries {
+			continue
+		}
+		name := scheduleSnapshotName(entry.PVCName, schedule,
+			runNameSuffix(run.ScheduledTime.UTC(), runTypeOf(run))+"-retry"+strconv.Itoa(attempt))
+		replacement := newRetrySnapshot(snap, name, attempt)
+		pvc := sourceClaim(ctx, c, snap.Namespace, entry.PVCName)
+		logger.Info("replacing a failed snapshot", "PVC", entry.PVCName, "Snapshot", name, "failed", snap.Name)
+		if err := c.Create(ctx, replacement); err != nil && !kerrors.IsAlreadyExists(err) {
+			if _, throttled := creationThrottled(err); throttled {
+				return created, err
+			}
+			logger.Error(err, "while replacing snapshot", "name", name)
+			snapshotCreateErrorTotal.With(scheduleLabels(scheduleIDFor(schedule), entry.PVCName)).Inc()
+			recordClaimEvent(recorder, schedule, pvc, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed,
+				eventActionCreate, "Failed to create snapshot %s: %v", name, err)
+			return created, &claimError{claim: entry.PVCName, err: err}
+		} else if err == nil {
+			snapshotCreateTotal.With(scheduleLabels(scheduleIDFor(schedule), entry.PVCName)).Inc()
+			recordClaimEvent(recorder, schedule, pvc, replacement, corev1.EventTypeNormal, eventReasonSnapshotRetried,
+				eventActionCreate, "Created snapshot %s to replace %s", name, snap.Name)
+		}
+		entry.SnapshotName = name
+		entry.ReadyToUse = false
+		entry.Error = ""
+		created = true
+	}
+	return created, nil
+}
+
+// retryAttempt returns the retry attempt that created the snapshot, or 0 if it
+// was the original snapshot
+func retryAttempt(snap metav1.Object) int {
+	attempt, err := strconv.Atoi(snap.GetLabels()[RetryKey])
+	if err != nil {
+		return 0
+	}
+	return attempt
+}
+
+// newRetrySnapshot returns a snapshot that replaces the failed one. It has the
+// same source, class, labels, and owner as the failed snapshot.
+func newRetrySnapshot(failed *snapv1.VolumeSnapshot, name string, attempt int) *snapv1.VolumeSnapshot {
+	labels := maps.Clone(failed.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[RetryKey] = strconv.Itoa(attempt)
+	return &snapv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       failed.Namespace,
+			Labels:          labels,
+			OwnerReferences: failed.OwnerReferences,
+		},
+		Spec: *failed.Spec.DeepCopy(),
+	}
+}
+
+// filterFailedBefore returns the snapshots that reported an error before the
+// cutoff. Snapshots that are only stuck are left for the retention rules, and
+// pinned, final, and baseline snapshots are never deleted by the grace period.
+func filterFailedBefore(failed []failedSnapshot, cutoff time.Time, now time.Time) []snapv1.VolumeSnapshot {
+	outList := []snapv1.VolumeSnapshot{}
+	for _, f := range failed {
+		if f.status.Reason != snapschedulerv1.FailedSnapshotReasonError {
+			continue
+		}
+		if isSnapshotPinned(f.snap, now) || isFinalSnapshot(f.snap) || f.snap.Labels[TypeKey] == TypeBaseline {
+			continue
+		}
+		errTime := f.snap.CreationTimestamp
+		if f.snap.Status.Error.Time != nil {
+			errTime = *f.snap.Status.Error.Time
+		}
+		if errTime.Time.Before(cutoff) {
+			outList = append(outList, *f.snap)
+		}
+	}
+	return outList
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Handling failed snapshots", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	var snapTime time.Time
+	var errTime time.Time
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+		capture = &capturingRecorder{}
+		snapTime, _ = time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		errTime = time.Now().Add(-10 * time.Minute)
+
+		// "data" has failed, "logs" is still pending, and "cache" is ready
+		pvcs := []corev1.PersistentVolumeClaim{}
+		for _, pvcName := range []string{"data", "logs", "cache"} {
+			pvcs = append(pvcs, *createPVC(pvcName, ns.Name))
+			snap := newSnapForClaim(snapshotName(pvcName, schedule.Name, snapTime), pvcs[len(pvcs)-1], schedule,
+				snapTime, map[string]string{"mylabel": "myval"}, ptr.To("myclass"), false)
+			Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+			switch pvcName {
+			case "data":
+				snap.Status = &snapv1.VolumeSnapshotStatus{
+					ReadyToUse: ptr.To(false),
+					Error: &snapv1.VolumeSnapshotError{
+						Time:    &metav1.Time{Time: errTime},
+						Message: ptr.To("driver exploded"),
+					},
+				}
+			case "cache":
+				snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+			default:
+				continue
+			}
+			Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+		}
+		recordRun(schedule, snapTime, typeScheduled, pvcs, nil)
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	handle := func(now time.Time) bool {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		changed, err := handleFailedSnapshots(context.TODO(), schedule, snapList, now, logger, k8sClient, capture)
+		Expect(err).NotTo(HaveOccurred())
+		return changed
+	}
+	snapNames := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+
+	It("reports snapshots that have failed", func() {
+		Expect(handle(time.Now())).To(BeFalse())
+		Expect(schedule.Status.FailedSnapshots).To(ConsistOf(snapschedulerv1.FailedSnapshotStatus{
+			Name:    "data-hourly-202403010200",
+			PVCName: "data",
+			Reason:  snapschedulerv1.FailedSnapshotReasonError,
+			Message: "driver exploded",
+		}))
+		Expect(capture.regarding(eventReasonSnapshotError)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data"))
+		// Only newly failed snapshots are reported via Events
+		handle(time.Now())
+		Expect(capture.events).To(HaveLen(2))
+	})
+	It("reports snapshots that are stuck", func() {
+		handle(time.Now().Add(2 * time.Hour))
+		Expect(schedule.Status.FailedSnapshots).To(HaveLen(2))
+		// Snapshots of the same run are listed by name
+		Expect(schedule.Status.FailedSnapshots[0].Name).To(Equal("data-hourly-202403010200"))
+		Expect(schedule.Status.FailedSnapshots[1]).To(Equal(snapschedulerv1.FailedSnapshotStatus{
+			Name:    "logs-hourly-202403010200",
+			PVCName: "logs",
+			Reason:  snapschedulerv1.FailedSnapshotReasonStuck,
+			Message: "not ready to use after 1h0m0s",
+		}))
+		Expect(capture.regarding(eventReasonSnapshotStuck)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/logs"))
+	})
+	It("uses the schedule's stuckAfter duration", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{StuckAfter: "5m"}
+		handle(time.Now().Add(10 * time.Minute))
+		Expect(schedule.Status.FailedSnapshots).To(HaveLen(2))
+	})
+	It("clears snapshots that recover", func() {
+		handle(time.Now())
+		snap := &snapv1.VolumeSnapshot{}
+		Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Name: "data-hourly-202403010200",
+			Namespace: ns.Name}, snap)).To(Succeed())
+		snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+		Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+		handle(time.Now())
+		Expect(schedule.Status.FailedSnapshots).To(BeEmpty())
+	})
+	It("doesn't replace failed snapshots by default", func() {
+		handle(time.Now().Add(2 * time.Hour))
+		Expect(snapNames()).To(HaveLen(3))
+	})
+	It("replaces the failed snapshots of the most recent run", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{MaxRetries: ptr.To[int32](1)}
+		Expect(handle(time.Now())).To(BeTrue())
+		Expect(snapNames()).To(ConsistOf("data-hourly-202403010200", "logs-hourly-202403010200",
+			"cache-hourly-202403010200", "data-hourly-202403010200-retry1"))
+		Expect(schedule.Status.RecentRuns[0].Snapshots[0].SnapshotName).To(Equal("data-hourly-202403010200-retry1"))
+		Expect(capture.regarding(eventReasonSnapshotRetried)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data"))
+
+		replacement := &snapv1.VolumeSnapshot{}
+		Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Name: "data-hourly-202403010200-retry1",
+			Namespace: ns.Name}, replacement)).To(Succeed())
+		Expect(replacement.Labels).To(HaveKeyWithValue(RetryKey, "1"))
+		Expect(replacement.Labels).To(HaveKeyWithValue(WhenKey, "202403010200"))
+		Expect(replacement.Labels).To(HaveKeyWithValue("mylabel", "myval"))
+		Expect(*replacement.Spec.Source.PersistentVolumeClaimName).To(Equal("data"))
+		Expect(*replacement.Spec.VolumeSnapshotClassName).To(Equal("myclass"))
+
+		// The replacement fails too, but the retries are exhausted
+		replacement.Status = &snapv1.VolumeSnapshotStatus{
+			Error: &snapv1.VolumeSnapshotError{Message: ptr.To("driver exploded again")},
+		}
+		Expect(k8sClient.Status().Update(context.TODO(), replacement)).To(Succeed())
+		Expect(handle(time.Now())).To(BeFalse())
+		Expect(snapNames()).To(HaveLen(4))
+	})
+	It("replaces stuck snapshots", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{MaxRetries: ptr.To[int32](1)}
+		handle(time.Now().Add(2 * time.Hour))
+		Expect(snapNames()).To(ContainElements("data-hourly-202403010200-retry1",
+			"logs-hourly-202403010200-retry1"))
+	})
+	It("doesn't replace snapshots of older runs", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{MaxRetries: ptr.To[int32](1)}
+		recordRun(schedule, snapTime.Add(time.Hour), typeScheduled, nil, nil)
+		Expect(handle(time.Now())).To(BeFalse())
+		Expect(snapNames()).To(HaveLen(3))
+	})
+	It("deletes failed snapshots after the grace period", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{GracePeriod: "30m"}
+		Expect(handle(time.Now())).To(BeFalse())
+		Expect(snapNames()).To(HaveLen(3))
+		// Stuck snapshots are left for the retention rules
+		Expect(handle(errTime.Add(31 * time.Minute).Add(2 * time.Hour))).To(BeTrue())
+		Expect(snapNames()).To(ConsistOf("logs-hourly-202403010200", "cache-hourly-202403010200"))
+		Expect(capture.regarding(eventReasonSnapshotExpired)).To(ConsistOf(
+			"SnapshotSchedule/hourly", "PersistentVolumeClaim/data"))
+	})
+	It("doesn't delete pinned failed snapshots", func() {
+		schedule.Spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{GracePeriod: "30m"}
+		snap := &snapv1.VolumeSnapshot{}
+		Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Name: "data-hourly-202403010200",
+			Namespace: ns.Name}, snap)).To(Succeed())
+		snap.Annotations = map[string]string{RetainKey: "true"}
+		Expect(k8sClient.Update(context.TODO(), snap)).To(Succeed())
+		Expect(handle(errTime.Add(time.Hour))).To(BeFalse())
+		Expect(snapNames()).To(HaveLen(3))
+		// It's still reported, though
+		Expect(schedule.Status.FailedSnapshots).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,531 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+const (
+	// How long to wait for a final snapshot to become ready if the schedule
+	// doesn't say
+	defaultFinalSnapshotTimeout = 10 * time.Minute
+	// The finalizer that the CSI snapshot controller places on a PVC while
+	// it is the source of a snapshot that isn't ready yet
+	snapshotSourceProtectionFinalizer = "snapshot.storage.kubernetes.io/pvc-as-source-protection"
+	// Admission webhooks time out within 30s, so the final snapshot taken as
+	// a PVC's deletion was admitted is created shortly before the PVC's
+	// deletionTimestamp. Older final snapshots were taken for deletions that
+	// were later rejected, and don't hold the PVC's latest data.
+	finalSnapshotAdmissionWindow = time.Minute
+	// Final snapshots are named to the second so that one left behind by a
+	// rejected deletion doesn't take the name of the next one
+	timeFinalSnapshot = "20060102150405"
+	// Final snapshots are taken by the PVC webhook
+	finalSnapshotsDisabledMessage = "final snapshots require the controller to be started with --enable-webhooks"
+)
+
+// FinalSnapshotReconciler holds the deletion of the PVCs that carry the
+// FinalSnapshotFinalizer until their final snapshots are ready. The finalizer
+// is placed by the schedules themselves, and the final snapshots are taken by
+// TakeFinalSnapshots as the deletion is admitted.
+type FinalSnapshotReconciler struct {
+	client.Client
+	Scheme                *runtime.Scheme
+	Recorder              events.EventRecorder
+	DefaultDeletionPolicy snapschedulerv1.DeletionPolicy
+	CreationLimiter       *SnapshotCreationLimiter
+	// EnableFinalSnapshots allows PVCs to be held for their final snapshots.
+	// Without it, the finalizer is removed from any PVC that still has it.
+	EnableFinalSnapshots bool
+}
+
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+func (r *FinalSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := log.FromContext(ctx).WithValues("persistentvolumeclaim", req.NamespacedName)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, req.NamespacedName, pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !controllerutil.ContainsFinalizer(pvc, FinalSnapshotFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	reqLogger.Info("Reconciling protected PVC")
+	if !r.EnableFinalSnapshots {
+		return ctrl.Result{}, releaseClaim(ctx, pvc, reqLogger, r.Client)
+	}
+
+	wait, err := handleFinalSnapshots(ctx, pvc, time.Now(), reqLogger, r.CreationLimiter.Client(r.Client),
+		r.Recorder, r.DefaultDeletionPolicy)
+	return ctrl.Result{RequeueAfter: wait}, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FinalSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	protected := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return controllerutil.ContainsFinalizer(obj, FinalSnapshotFinalizer)
+	})
+	// Changes to the schedules may release their PVCs, and the final
+	// snapshots becoming ready releases the PVCs they were taken of
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("finalsnapshot").
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(protected)).
+		Watches(&snapschedulerv1.SnapshotSchedule{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return protectedClaims(ctx, r.Client, obj.GetNamespace())
+			})).
+		Watches(&snapschedulerv1.ClusterSnapshotSchedule{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return protectedClaims(ctx, r.Client, "")
+			})).
+		Watches(&snapv1.VolumeSnapshot{}, handler.EnqueueRequestsFromMapFunc(claimForFinalSnapshot)).
+		Complete(r)
+}
+
+// protectedClaims maps a schedule to the PVCs in the namespace (or, if empty,
+// the whole cluster) that carry the FinalSnapshotFinalizer
+func protectedClaims(ctx context.Context, c client.Client, namespace string) []reconcile.Request {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, pvcList, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list protected PVCs")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, pvc := range pvcList.Items {
+		if controllerutil.ContainsFinalizer(&pvc, FinalSnapshotFinalizer) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// claimForFinalSnapshot maps a final snapshot to the PVC it was taken of
+func claimForFinalSnapshot(_ context.Context, obj client.Object) []reconcile.Request {
+	snap, ok := obj.(*snapv1.VolumeSnapshot)
+	if !ok || !isFinalSnapshot(snap) || snap.Spec.Source.PersistentVolumeClaimName == nil {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: *snap.Spec.Source.PersistentVolumeClaimName,
+			Namespace: snap.Namespace},
+	}}
+}
+
+// protectClaims places the FinalSnapshotFinalizer on each of the schedule's
+// PVCs that can be snapshotted, if the schedule has a finalSnapshot
+func protectClaims(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, logger logr.Logger,
+	c client.Client) error {
+	if !takesFinalSnapshots(schedule) {
+		return nil
+	}
+	pvcList, err := listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace,
+		&schedule.Spec.ClaimSelector, schedule.Spec.StorageClassSelector)
+	if err != nil {
+		logger.Error(err, "unable to get matching PVCs")
+		return err
+	}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if controllerutil.ContainsFinalizer(pvc, FinalSnapshotFinalizer) {
+			continue
+		}
+		reason, _, err := skipReason(ctx, schedule.Spec.ClaimFilter, pvc, c)
+		if err != nil {
+			logger.Error(err, "unable to check PVC", "PVC", pvc.Name)
+			return err
+		}
+		if reason != "" {
+			// PVCs are protected once they can be snapshotted
+			continue
+		}
+		patch := client.MergeFromWithOptions(pvc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		controllerutil.AddFinalizer(pvc, FinalSnapshotFinalizer)
+		logger.Info("protecting PVC with a final snapshot", "PVC", pvc.Name)
+		if err := c.Patch(ctx, pvc, patch); err != nil && !kerrors.IsNotFound(err) {
+			logger.Error(err, "unable to add finalizer to PVC", "PVC", pvc.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// updateFinalSnapshotCondition records whether the schedule's PVCs are held
+// for final snapshots. Since the final snapshots are taken by the PVC webhook,
+// a schedule asking for them while the webhooks are disabled doesn't hold its
+// PVCs, and is warned instead.
+func updateFinalSnapshotCondition(schedule runtime.Object, conditions *[]metav1.Condition,
+	spec *snapschedulerv1.SnapshotScheduleSpec, enabled bool, recorder events.EventRecorder) {
+	switch {
+	case spec.FinalSnapshot == nil:
+		apimeta.RemoveStatusCondition(conditions, snapschedulerv1.ConditionFinalSnapshot)
+	case enabled:
+		apimeta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    snapschedulerv1.ConditionFinalSnapshot,
+			Status:  metav1.ConditionTrue,
+			Reason:  snapschedulerv1.FinalSnapshotReasonEnabled,
+			Message: "PVCs are held for final snapshots",
+		})
+	case !apimeta.IsStatusConditionFalse(*conditions, snapschedulerv1.ConditionFinalSnapshot):
+		apimeta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    snapschedulerv1.ConditionFinalSnapshot,
+			Status:  metav1.ConditionFalse,
+			Reason:  snapschedulerv1.FinalSnapshotReasonWebhooksDisabled,
+			Message: finalSnapshotsDisabledMessage,
+		})
+		recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonFinalSnapshot, eventActionSchedule,
+			"PVCs aren't held for final snapshots: %s", finalSnapshotsDisabledMessage)
+	}
+}
+
+// takesFinalSnapshots reports whether the schedule currently protects its
+// PVCs with final snapshots
+func takesFinalSnapshots(schedule *snapschedulerv1.SnapshotSchedule) bool {
+	return schedule.Spec.FinalSnapshot != nil && !schedule.Spec.Disabled && !schedule.Spec.DryRun
+}
+
+// handleFinalSnapshots takes the final snapshot of a PVC that is being deleted
+// for each schedule protecting it, releasing the PVC once they are all ready or
+// have timed out. PVCs that are no longer protected by any schedule are
+// released right away. It returns how long to wait before checking again.
+func handleFinalSnapshots(ctx context.Context, pvc *corev1.PersistentVolumeClaim, now time.Time,
+	logger logr.Logger, c client.Client, recorder events.EventRecorder,
+	defaultPolicy snapschedulerv1.DeletionPolicy) (time.Duration, error) {
+	recorder = clusterScheduleRecorder{recorder}
+	schedules, err := schedulesProtectingClaim(ctx, c, pvc)
+	if err != nil {
+		logger.Error(err, "unable to find the schedules protecting PVC")
+		return 0, err
+	}
+	if pvc.DeletionTimestamp.IsZero() {
+		if len(schedules) == 0 {
+			return 0, releaseClaim(ctx, pvc, logger, c)
+		}
+		return 0, nil
+	}
+
+	var wait time.Duration
+	for _, schedule := range schedules {
+		remaining, err := takeFinalSnapshot(ctx, schedule, pvc, now, logger, c, recorder,
+			ownsSnapshots(&schedule.Spec, defaultPolicy))
+		if err != nil {
+			return 0, err
+		}
+		if remaining > 0 && (wait == 0 || remaining < wait) {
+			wait = remaining
+		}
+	}
+	if wait > 0 {
+		logger.Info("waiting for final snapshots", "wait", wait)
+		return wait, nil
+	}
+	return 0, releaseClaim(ctx, pvc, logger, c)
+}
+
+// schedulesProtectingClaim returns the schedules, both namespaced and cluster
+// scoped, that take a final snapshot of the PVC. PVCs in namespaces that are
+// being deleted aren't protected since their snapshots would be deleted too.
+func schedulesProtectingClaim(ctx context.Context, c client.Client,
+	pvc *corev1.PersistentVolumeClaim) ([]*snapschedulerv1.SnapshotSchedule, error) {
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: pvc.Namespace}, ns); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !ns.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	var candidates []*snapschedulerv1.SnapshotSchedule
+	scheduleList := &snapschedulerv1.SnapshotScheduleList{}
+	if err := c.List(ctx, scheduleList, client.InNamespace(pvc.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range scheduleList.Items {
+		candidates = append(candidates, &scheduleList.Items[i])
+	}
+	clusterList := &snapschedulerv1.ClusterSnapshotScheduleList{}
+	if err := c.List(ctx, clusterList); err != nil {
+		return nil, err
+	}
+	for i := range clusterList.Items {
+		cs := &clusterList.Items[i]
+		if selectorMatches(&cs.Spec.NamespaceSelector, ns) {
+			candidates = append(candidates,
+				scheduleForNamespace(cs, pvc.Namespace, snapschedulerv1.SnapshotScheduleStatus{}))
+		}
+	}
+
+	var schedules []*snapschedulerv1.SnapshotSchedule
+	for _, schedule := range candidates {
+		protects, err := protectsClaim(ctx, c, schedule, pvc)
+		if err != nil {
+			return nil, err
+		}
+		if protects {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+// protectsClaim reports whether the schedule takes a final snapshot of the PVC
+func protectsClaim(ctx context.Context, c client.Client, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if !takesFinalSnapshots(schedule) || !selectorMatches(&schedule.Spec.ClaimSelector, pvc) ||
+		isClaimExcluded(pvc) {
+		return false, nil
+	}
+	if schedule.Spec.StorageClassSelector != nil {
+		classes, err := storageClassesMatchingSelector(ctx, c, schedule.Spec.StorageClassSelector)
+		if err != nil {
+			return false, err
+		}
+		if _, found := classes[PVCStorageClassName(pvc)]; !found {
+			return false, nil
+		}
+	}
+	reason, _, err := filterReason(ctx, schedule.Spec.ClaimFilter, pvc, c)
+	return reason == "", err
+}
+
+// TakeFinalSnapshots takes the final snapshot of a PVC whose deletion is about
+// to be admitted for each schedule protecting it. The CSI snapshot controller
+// won't snapshot a PVC once its deletion has begun, so this can't wait until
+// the PVC is reconciled. The snapshots are tied to the admission request, so
+// that those taken for a deletion that is rejected aren't mistaken for those of
+// a later one. It reports whether the snapshots are underway: each is either
+// ready or its PVC is protected by the snapshot controller.
+func TakeFinalSnapshots(ctx context.Context, pvc *corev1.PersistentVolumeClaim, requestUID types.UID,
+	now time.Time, logger logr.Logger, c client.Client, recorder events.EventRecorder,
+	defaultPolicy snapschedulerv1.DeletionPolicy) (bool, error) {
+	if pvc.Status.Phase != corev1.ClaimBound || !pvc.DeletionTimestamp.IsZero() {
+		return true, nil
+	}
+	recorder = clusterScheduleRecorder{recorder}
+	schedules, err := schedulesProtectingClaim(ctx, c, pvc)
+	if err != nil {
+		logger.Error(err, "unable to find the schedules protecting PVC")
+		return false, err
+	}
+	underway := true
+	for _, schedule := range schedules {
+		snap, err := finalSnapshotOf(ctx, schedule, pvc, requestUID, c)
+		if err != nil {
+			return false, err
+		}
+		if snap == nil {
+			snap, err = createFinalSnapshot(ctx, schedule, pvc, now.UTC(), requestUID, logger, c, recorder,
+				ownsSnapshots(&schedule.Spec, defaultPolicy))
+			if _, throttled := creationThrottled(err); throttled {
+				// Left for the next attempt
+				underway = false
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		if !isSnapshotObjectReady(snap) && !controllerutil.ContainsFinalizer(pvc, snapshotSourceProtectionFinalizer) {
+			underway = false
+		}
+	}
+	return underway, nil
+}
+
+// takeFinalSnapshot checks on the schedule's final snapshot of a PVC that is
+// being deleted, returning how much longer to wait for it to become ready. No
+// wait is needed once it is ready or the schedule's timeout has passed.
+func takeFinalSnapshot(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc *corev1.PersistentVolumeClaim, now time.Time, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, enableOwnerReferences bool) (time.Duration, error) {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		// There's no data to protect
+		return 0, nil
+	}
+	timeout := defaultFinalSnapshotTimeout
+	if spec := schedule.Spec.FinalSnapshot; spec.Timeout != "" {
+		var err error
+		if timeout, err = parseRetentionDuration(spec.Timeout); err != nil {
+			logger.Error(err, "invalid value for spec.finalSnapshot.timeout", "schedule", schedule.Name)
+			return 0, err
+		}
+	}
+	deadline := pvc.DeletionTimestamp.Add(timeout)
+
+	snap, err := finalSnapshotOf(ctx, schedule, pvc, "", c)
+	if err != nil {
+		return 0, err
+	}
+	if snap != nil && isSnapshotObjectReady(snap) {
+		return 0, nil
+	}
+	if !now.Before(deadline) {
+		logger.Info("timed out waiting for final snapshot", "schedule", schedule.Name)
+		recordClaimEvent(recorder, schedule, pvc, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed,
+			eventActionCreate, "Final snapshot didn't become ready within %s; releasing the PVC anyway", timeout)
+		return 0, nil
+	}
+	if snap == nil {
+		// The deletion was admitted without the snapshot being taken (e.g.,
+		// the webhook is disabled). It's still attempted, but the snapshot
+		// controller may not take it.
+		logger.Info("PVC deleted before its final snapshot was taken", "schedule", schedule.Name)
+		_, err := createFinalSnapshot(ctx, schedule, pvc, pvc.DeletionTimestamp.UTC(), "", logger, c, recorder,
+			enableOwnerReferences)
+		if wait, throttled := creationThrottled(err); throttled {
+			return min(wait, deadline.Sub(now)), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return deadline.Sub(now), nil
+}
+
+// finalSnapshotOf returns the schedule's final snapshot of the PVC for its
+// deletion, or nil if it has yet to be taken. While the deletion is being
+// admitted, that's the snapshot taken for the admission request; once the PVC
+// is being deleted, it's one taken as its deletion was admitted or since.
+func finalSnapshotOf(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc *corev1.PersistentVolumeClaim, requestUID types.UID, c client.Client) (*snapv1.VolumeSnapshot, error) {
+	snapList := &snapv1.VolumeSnapshotList{}
+	if err := c.List(ctx, snapList, client.InNamespace(pvc.Namespace),
+		client.MatchingLabels{scheduleLabelKey(schedule): schedule.Name, TypeKey: TypeFinal}); err != nil {
+		return nil, err
+	}
+	for i := range snapList.Items {
+		if isFinalSnapshotFor(&snapList.Items[i], pvc, requestUID) {
+			return &snapList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// isFinalSnapshotFor reports whether the final snapshot was taken for the
+// PVC's deletion. Final snapshots of an earlier PVC of the same name, and those
+// taken for deletions that were rejected, are not.
+func isFinalSnapshotFor(snap *snapv1.VolumeSnapshot, pvc *corev1.PersistentVolumeClaim, requestUID types.UID) bool {
+	source := snap.Spec.Source.PersistentVolumeClaimName
+	if source == nil || *source != pvc.Name || snap.CreationTimestamp.Before(&pvc.CreationTimestamp) {
+		return false
+	}
+	if requestUID != "" {
+		return snap.Annotations[DeletionRequestKey] == string(requestUID)
+	}
+	if pvc.DeletionTimestamp.IsZero() {
+		return false
+	}
+	return !snap.CreationTimestamp.Time.Before(pvc.DeletionTimestamp.Add(-finalSnapshotAdmissionWindow))
+}
+
+// createFinalSnapshot creates the schedule's final snapshot of the PVC,
+// recording the admission request it was taken for, if any
+func createFinalSnapshot(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	pvc *corev1.PersistentVolumeClaim, snapTime time.Time, requestUID types.UID, logger logr.Logger,
+	c client.Client, recorder events.EventRecorder, enableOwnerReferences bool) (*snapv1.VolumeSnapshot, error) {
+	snapName := scheduleSnapshotName(pvc.Name, schedule, TypeFinal+"-"+snapTime.Format(timeFinalSnapshot))
+	labels, snapshotClassName := snapshotSettings(schedule, pvc, TypeFinal)
+	snap := newSnapForClaim(snapName, *pvc, schedule, snapTime, labels, snapshotClassName, enableOwnerReferences)
+	if requestUID != "" {
+		snap.Annotations = map[string]string{DeletionRequestKey: string(requestUID)}
+	}
+	logger.Info("creating a final snapshot", "schedule", schedule.Name, "Snapshot", snapName)
+	err := c.Create(ctx, snap)
+	if kerrors.IsAlreadyExists(err) {
+		if err = c.Get(ctx, client.ObjectKeyFromObject(snap), snap); err != nil {
+			return nil, err
+		}
+		if !isFinalSnapshotFor(snap, pvc, requestUID) {
+			return nil, fmt.Errorf("final snapshot %s was taken for an earlier deletion", snapName)
+		}
+		return snap, nil
+	}
+	if _, throttled := creationThrottled(err); throttled {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error(err, "while creating final snapshot", "name", snapName)
+		snapshotCreateErrorTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+		recordClaimEvent(recorder, schedule, pvc, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed,
+			eventActionCreate, "Failed to create final snapshot %s: %v", snapName, err)
+		return nil, err
+	}
+	snapshotCreateTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+	recordClaimEvent(recorder, schedule, pvc, snap, corev1.EventTypeNormal, eventReasonSnapshotCreated,
+		eventActionCreate, "Created final snapshot %s", snapName)
+	return snap, nil
+}
+
+// ReleaseProtectedClaims removes the FinalSnapshotFinalizer from every PVC in
+// the cluster. It is used when snapscheduler is uninstalled, since the PVCs
+// could not be deleted once nothing removes the finalizer.
+func ReleaseProtectedClaims(ctx context.Context, logger logr.Logger, c client.Client) error {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, pvcList); err != nil {
+		return err
+	}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if err := releaseClaim(ctx, pvc, logger.WithValues("persistentvolumeclaim",
+			client.ObjectKeyFromObject(pvc)), c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseClaim removes the FinalSnapshotFinalizer from the PVC
+func releaseClaim(ctx context.Context, pvc *corev1.PersistentVolumeClaim, logger logr.Logger,
+	c client.Client) error {
+	patch := client.MergeFromWithOptions(pvc.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if !controllerutil.RemoveFinalizer(pvc, FinalSnapshotFinalizer) {
+		return nil
+	}
+	logger.Info("releasing PVC")
+	if err := c.Patch(ctx, pvc, patch); err != nil && !kerrors.IsNotFound(err) {
+		logger.Error(err, "unable to remove finalizer from PVC")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Taking final snapshots", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+				Labels:       map[string]string{"final": "yes"},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "daily",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule:      "@daily",
+				FinalSnapshot: &snapschedulerv1.FinalSnapshotSpec{Timeout: "5m"},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), schedule)).To(Succeed())
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	createPVC := func(name string, bound bool) *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns.Name,
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		if bound {
+			bindPVC(pvc)
+		}
+		return pvc
+	}
+	getPVC := func(name string) *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns.Name}, pvc)
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return pvc
+	}
+	// deletePVC deletes the protected PVC, returning it as it is held by the
+	// finalizer
+	deletePVC := func(name string) *corev1.PersistentVolumeClaim {
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		Expect(k8sClient.Delete(context.TODO(), getPVC(name))).To(Succeed())
+		pvc := getPVC(name)
+		Expect(pvc).NotTo(BeNil())
+		Expect(pvc.DeletionTimestamp).NotTo(BeNil())
+		return pvc
+	}
+	handle := func(pvc *corev1.PersistentVolumeClaim, now time.Time) time.Duration {
+		wait, err := handleFinalSnapshots(context.TODO(), pvc, now, logger, k8sClient, capture, "")
+		Expect(err).NotTo(HaveOccurred())
+		return wait
+	}
+	finalSnapshots := func() []snapv1.VolumeSnapshot {
+		snapList := &snapv1.VolumeSnapshotList{}
+		Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns.Name),
+			client.MatchingLabels{TypeKey: TypeFinal})).To(Succeed())
+		return snapList.Items
+	}
+
+	It("protects the schedule's PVCs once they can be snapshotted", func() {
+		createPVC("data", true)
+		createPVC("pending", false)
+		excluded := createPVC("excluded", true)
+		excluded.Annotations = map[string]string{ExcludeKey: "true"}
+		Expect(k8sClient.Update(context.TODO(), excluded)).To(Succeed())
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		Expect(getPVC("data").Finalizers).To(ContainElement(FinalSnapshotFinalizer))
+		Expect(getPVC("pending").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+		Expect(getPVC("excluded").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+	})
+	It("releases PVCs that are no longer protected", func() {
+		createPVC("data", true)
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		schedule.Spec.FinalSnapshot = nil
+		Expect(k8sClient.Update(context.TODO(), schedule)).To(Succeed())
+		Expect(handle(getPVC("data"), time.Now())).To(BeZero())
+		Expect(getPVC("data").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+	})
+	It("doesn't hold PVCs while the webhooks are disabled", func() {
+		createPVC("data", true)
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		createPVC("logs", true)
+		_, err := doReconcile(context.TODO(), schedule, logger, k8sClient, capture, false, false, nil,
+			&scheduleTracker{
+				readyUIDs: make(map[types.UID]struct{}),
+				prevPVCs:  make(map[string]struct{}),
+			})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getPVC("logs").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+
+		// PVCs held from before are released
+		r := &FinalSnapshotReconciler{Client: k8sClient, Recorder: capture}
+		_, err = r.Reconcile(context.TODO(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: "data", Namespace: ns.Name},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getPVC("data").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+
+		// The schedule is warned once
+		updateFinalSnapshotCondition(schedule, &schedule.Status.Conditions, &schedule.Spec, false, capture)
+		updateFinalSnapshotCondition(schedule, &schedule.Status.Conditions, &schedule.Spec, false, capture)
+		Expect(apimeta.IsStatusConditionFalse(schedule.Status.Conditions,
+			snapschedulerv1.ConditionFinalSnapshot)).To(BeTrue())
+		Expect(capture.regarding(eventReasonFinalSnapshot)).To(ConsistOf("SnapshotSchedule/daily"))
+		updateFinalSnapshotCondition(schedule, &schedule.Status.Conditions, &schedule.Spec, true, capture)
+		Expect(apimeta.IsStatusConditionTrue(schedule.Status.Conditions,
+			snapschedulerv1.ConditionFinalSnapshot)).To(BeTrue())
+	})
+	It("snapshots a deleted PVC and releases it once the snapshot is ready", func() {
+		createPVC("data", true)
+		pvc := deletePVC("data")
+		now := pvc.DeletionTimestamp.Time
+		Expect(handle(pvc, now)).To(Equal(5 * time.Minute))
+		snaps := finalSnapshots()
+		Expect(snaps).To(HaveLen(1))
+		Expect(snaps[0].Name).To(HavePrefix("data-daily-final-"))
+		Expect(*snaps[0].Spec.Source.PersistentVolumeClaimName).To(Equal("data"))
+		Expect(capture.regarding(eventReasonSnapshotCreated)).To(ConsistOf(
+			"SnapshotSchedule/daily", "PersistentVolumeClaim/data"))
+
+		// The PVC is held until the snapshot is ready
+		Expect(handle(getPVC("data"), now.Add(time.Minute))).To(Equal(4 * time.Minute))
+		Expect(finalSnapshots()).To(HaveLen(1))
+		snaps[0].Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+		Expect(k8sClient.Status().Update(context.TODO(), &snaps[0])).To(Succeed())
+		Expect(handle(getPVC("data"), now.Add(2*time.Minute))).To(BeZero())
+		Expect(getPVC("data")).To(BeNil())
+	})
+	It("takes the snapshot before the PVC's deletion is admitted", func() {
+		createPVC("data", true)
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		admitted := time.Now()
+		take := func() bool {
+			underway, err := TakeFinalSnapshots(context.TODO(), getPVC("data"), "delete-1", admitted, logger,
+				k8sClient, capture, "")
+			Expect(err).NotTo(HaveOccurred())
+			return underway
+		}
+		// Until the snapshot controller protects the PVC, the snapshot may not
+		// be taken
+		Expect(take()).To(BeFalse())
+		Expect(take()).To(BeFalse())
+		Expect(finalSnapshots()).To(HaveLen(1))
+		pvc := getPVC("data")
+		patch := client.MergeFrom(pvc.DeepCopy())
+		pvc.Finalizers = append(pvc.Finalizers, snapshotSourceProtectionFinalizer)
+		Expect(k8sClient.Patch(context.TODO(), pvc, patch)).To(Succeed())
+		Expect(take()).To(BeTrue())
+
+		// Once deleted, the PVC is held for that same snapshot
+		Expect(k8sClient.Delete(context.TODO(), getPVC("data"))).To(Succeed())
+		pvc = getPVC("data")
+		Expect(handle(pvc, pvc.DeletionTimestamp.Time)).To(Equal(5 * time.Minute))
+		snaps := finalSnapshots()
+		Expect(snaps).To(HaveLen(1))
+		snaps[0].Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+		Expect(k8sClient.Status().Update(context.TODO(), &snaps[0])).To(Succeed())
+		Expect(handle(getPVC("data"), pvc.DeletionTimestamp.Time)).To(BeZero())
+		Expect(getPVC("data").Finalizers).To(ConsistOf(snapshotSourceProtectionFinalizer))
+	})
+	It("takes a new snapshot when an earlier deletion was rejected", func() {
+		createPVC("data", true)
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		_, err := TakeFinalSnapshots(context.TODO(), getPVC("data"), "rejected", time.Now(), logger, k8sClient,
+			capture, "")
+		Expect(err).NotTo(HaveOccurred())
+		rejected := finalSnapshots()
+		Expect(rejected).To(HaveLen(1))
+		Expect(rejected[0].Annotations).To(HaveKeyWithValue(DeletionRequestKey, "rejected"))
+
+		// Final snapshots are named to the second
+		time.Sleep(time.Second)
+		_, err = TakeFinalSnapshots(context.TODO(), getPVC("data"), "delete-2", time.Now(), logger, k8sClient,
+			capture, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalSnapshots()).To(ConsistOf(
+			HaveField("Annotations", HaveKeyWithValue(DeletionRequestKey, "rejected")),
+			HaveField("Annotations", HaveKeyWithValue(DeletionRequestKey, "delete-2"))))
+	})
+	It("only holds a deleted PVC for snapshots taken as its deletion was admitted", func() {
+		created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		deleted := created.Add(24 * time.Hour)
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:              "data",
+			Namespace:         ns.Name,
+			CreationTimestamp: metav1.NewTime(created),
+			DeletionTimestamp: ptr.To(metav1.NewTime(deleted)),
+		}}
+		snapAt := func(when time.Time) *snapv1.VolumeSnapshot {
+			snap := newSnapForClaim("data-daily-final", *pvc, schedule, when,
+				map[string]string{TypeKey: TypeFinal}, nil, false)
+			snap.CreationTimestamp = metav1.NewTime(when)
+			return snap
+		}
+		Expect(isFinalSnapshotFor(snapAt(deleted.Add(-10*time.Second)), pvc, "")).To(BeTrue())
+		Expect(isFinalSnapshotFor(snapAt(deleted.Add(time.Minute)), pvc, "")).To(BeTrue())
+		// Left behind by a deletion that was rejected
+		Expect(isFinalSnapshotFor(snapAt(deleted.Add(-time.Hour)), pvc, "")).To(BeFalse())
+	})
+	It("ignores the final snapshots of an earlier PVC of the same name", func() {
+		earlier := newSnapForClaim("data-daily-final-202403010000",
+			corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+			schedule, time.Now(), map[string]string{TypeKey: TypeFinal}, nil, false)
+		Expect(k8sClient.Create(context.TODO(), earlier)).To(Succeed())
+		// Creation timestamps only have a resolution of seconds
+		time.Sleep(time.Second)
+		pvc := createPVC("data", true)
+		snap, err := finalSnapshotOf(context.TODO(), schedule, pvc, "", k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snap).To(BeNil())
+	})
+	It("releases all PVCs when snapscheduler is uninstalled", func() {
+		createPVC("data", true)
+		createPVC("logs", true)
+		Expect(protectClaims(context.TODO(), schedule, logger, k8sClient)).To(Succeed())
+		Expect(getPVC("logs").Finalizers).To(ContainElement(FinalSnapshotFinalizer))
+		Expect(ReleaseProtectedClaims(context.TODO(), logger, k8sClient)).To(Succeed())
+		Expect(getPVC("data").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+		Expect(getPVC("logs").Finalizers).NotTo(ContainElement(FinalSnapshotFinalizer))
+	})
+	It("releases a deleted PVC once the timeout passes", func() {
+		createPVC("data", true)
+		pvc := deletePVC("data")
+		Expect(handle(pvc, pvc.DeletionTimestamp.Time)).NotTo(BeZero())
+		Expect(handle(getPVC("data"), pvc.DeletionTimestamp.Add(5*time.Minute))).To(BeZero())
+		Expect(getPVC("data")).To(BeNil())
+		Expect(capture.regarding(eventReasonSnapshotFailed)).To(ConsistOf(
+			"SnapshotSchedule/daily", "PersistentVolumeClaim/data"))
+	})
+	It("waits for the final snapshots of cluster schedules", func() {
+		cs := &snapschedulerv1.ClusterSnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "final-" + ns.Name},
+			Spec: snapschedulerv1.ClusterSnapshotScheduleSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"final": "yes"}},
+				SnapshotScheduleSpec: snapschedulerv1.SnapshotScheduleSpec{
+					Schedule:      "@weekly",
+					FinalSnapshot: &snapschedulerv1.FinalSnapshotSpec{},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), cs)).To(Succeed())
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(context.TODO(), cs)).To(Succeed())
+		})
+		createPVC("data", true)
+		pvc := deletePVC("data")
+		schedules, err := schedulesProtectingClaim(context.TODO(), k8sClient, pvc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedules).To(ContainElements(HaveField("Name", "daily"), HaveField("Name", cs.Name)))
+		handle(pvc, pvc.DeletionTimestamp.Time)
+		Expect(finalSnapshots()).To(ContainElements(HaveField("Labels", HaveKeyWithValue(ScheduleKey, "daily")),
+			HaveField("Labels", HaveKeyWithValue(ClusterScheduleKey, cs.Name))))
+	})
+	It("doesn't hold unbound PVCs", func() {
+		pvc := createPVC("pending", false)
+		patch := client.MergeFrom(pvc.DeepCopy())
+		pvc.Finalizers = append(pvc.Finalizers, FinalSnapshotFinalizer)
+		Expect(k8sClient.Patch(context.TODO(), pvc, patch)).To(Succeed())
+		Expect(k8sClient.Delete(context.TODO(), pvc)).To(Succeed())
+		Expect(handle(getPVC("pending"), time.Now())).To(BeZero())
+		Expect(getPVC("pending")).To(BeNil())
+		Expect(finalSnapshots()).To(BeEmpty())
+	})
+	It("keeps final snapshots out of the schedule's other retention rules", func() {
+		schedule.Spec.Retention.MaxCount = ptr.To[int32](1)
+		schedule.Spec.FinalSnapshot.Expires = "24h"
+		var snapList []snapv1.VolumeSnapshot
+		for i, runType := range []string{typeScheduled, TypeFinal} {
+			snapTime := time.Now().Add(time.Duration(i-2) * time.Hour).UTC().Truncate(time.Minute)
+			labels, _ := snapshotSettings(schedule, &corev1.PersistentVolumeClaim{}, runType)
+			snapName := snapshotNameWithSuffix("data", schedule.Name, runType+snapTime.Format(timeYYYYMMDDHHMMSS))
+			snap := newSnapForClaim(snapName,
+				corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+				schedule, snapTime, labels, nil, false)
+			Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+			snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+			Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+			snapList = append(snapList, *snap)
+		}
+		Expect(expireSnapshots(context.TODO(), schedule, logger, k8sClient, capture, snapList,
+			groupSnapsByPVC(snapList), nil, nil)).To(Succeed())
+		Expect(finalSnapshots()).To(HaveLen(1))
+		remaining, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remaining).To(HaveLen(2))
+
+		// Final snapshots are deleted once they expire
+		Expect(expireFinalSnapshots(context.TODO(), schedule, time.Now().Add(48*time.Hour), logger,
+			k8sClient, capture, finalSnapshots())).To(Succeed())
+		Expect(finalSnapshots()).To(BeEmpty())
+	})
+})
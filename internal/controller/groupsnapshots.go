@@ -0,0 +1,176 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+//nolint:lll
+//+kubebuilder:rbac:groups=groupsnapshot.storage.k8s.io,resources=volumegroupsnapshots,verbs=get;list;watch;create;update;patch;delete
+
+// handleGroupSnapshotting ensures that a single VolumeGroupSnapshot of the
+// schedule's PVCs exists for the scheduled time
+func handleGroupSnapshotting(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, enableOwnerReferences bool) error {
+	if schedule.Spec.StorageClassSelector != nil || schedule.Spec.ClaimFilter != nil {
+		// The group snapshot would include PVCs that the selector excludes
+		err := errors.New("storageClassSelector and claimFilter may not be used with groupSnapshot")
+		logger.Error(err, "invalid schedule")
+		recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonInvalidSchedule, eventActionCreate,
+			"Unable to take group snapshot: %v", err)
+		return err
+	}
+	if len(pvcs) == 0 {
+		// An empty group can't be snapshotted
+		logger.Info("no PVCs match the schedule; skipping group snapshot")
+		return nil
+	}
+
+	groupSnapName := groupSnapshotName(schedule, snapTime, runType)
+	logger.V(4).Info("looking for group snapshot", "name", groupSnapName)
+	key := types.NamespacedName{Name: groupSnapName, Namespace: schedule.Namespace}
+	err := c.Get(ctx, key, &groupsnapv1beta2.VolumeGroupSnapshot{})
+	if err == nil {
+		// Already taken
+		return nil
+	}
+	if !kerrors.IsNotFound(err) {
+		logger.Error(err, "looking for group snapshot", "name", groupSnapName)
+		return err
+	}
+
+	groupSnap := newGroupSnapForSchedule(groupSnapName, schedule, snapTime, runType, enableOwnerReferences)
+	logger.Info("creating a group snapshot", "VolumeGroupSnapshot", groupSnapName, "PVCs", len(pvcs))
+	if err = c.Create(ctx, groupSnap); err != nil {
+		if _, throttled := creationThrottled(err); throttled {
+			return err
+		}
+		logger.Error(err, "while creating group snapshot", "name", groupSnapName)
+		for _, pvc := range pvcs {
+			snapshotCreateErrorTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+			recorder.Eventf(&pvc, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed, eventActionCreate,
+				"Failed to create group snapshot %s: %v", groupSnapName, err)
+		}
+		recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonSnapshotFailed, eventActionCreate,
+			"Failed to create group snapshot %s: %v", groupSnapName, err)
+		return err
+	}
+	for _, pvc := range pvcs {
+		snapshotCreateTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+		recorder.Eventf(&pvc, groupSnap, corev1.EventTypeNormal, eventReasonSnapshotCreated, eventActionCreate,
+			"Created group snapshot %s", groupSnapName)
+	}
+	recorder.Eventf(schedule, groupSnap, corev1.EventTypeNormal, eventReasonSnapshotCreated, eventActionCreate,
+		"Created group snapshot %s of %d PVC(s)", groupSnapName, len(pvcs))
+	return nil
+}
+
+// groupSnapshotName returns the name of the schedule's VolumeGroupSnapshot for
+// the given time
+func groupSnapshotName(schedule *snapschedulerv1.SnapshotSchedule, time time.Time, runType string) string {
+	suffix := scheduleNameSuffix(schedule, runNameSuffix(time, runType))
+	scheduleName := schedule.Name
+	nameBudget := validation.DNS1123SubdomainMaxLength - len(suffix) - 1
+	if len(scheduleName) > nameBudget {
+		scheduleName = scheduleName[0:nameBudget]
+	}
+	return scheduleName + "-" + suffix
+}
+
+// newGroupSnapForSchedule returns a VolumeGroupSnapshot that covers the PVCs
+// selected by the schedule's claimSelector
+func newGroupSnapForSchedule(name string, schedule *snapschedulerv1.SnapshotSchedule, scheduleTime time.Time,
+	runType string, enableOwnerReferences bool) *groupsnapv1beta2.VolumeGroupSnapshot {
+	labels := map[string]string{}
+	if schedule.Spec.SnapshotTemplate != nil {
+		for k, v := range schedule.Spec.SnapshotTemplate.Labels {
+			labels[k] = v
+		}
+	}
+	labels[scheduleLabelKey(schedule)] = schedule.Name
+	labels[WhenKey] = scheduleTime.Format(timeYYYYMMDDHHMMSS)
+	if runType != typeScheduled {
+		labels[TypeKey] = runType
+	}
+
+	groupSnap := &groupsnapv1beta2.VolumeGroupSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: schedule.Namespace,
+			Labels:    labels,
+		},
+		Spec: groupsnapv1beta2.VolumeGroupSnapshotSpec{
+			Source: groupsnapv1beta2.VolumeGroupSnapshotSource{
+				Selector: schedule.Spec.ClaimSelector.DeepCopy(),
+			},
+			VolumeGroupSnapshotClassName: schedule.Spec.GroupSnapshot.VolumeGroupSnapshotClassName,
+		},
+	}
+
+	if enableOwnerReferences {
+		groupSnap.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: schedule.APIVersion,
+				Kind:       schedule.Kind,
+				Name:       schedule.Name,
+				UID:        schedule.UID,
+			},
+		}
+	}
+
+	return groupSnap
+}
+
+// groupSnapshotsFromSchedule returns the VolumeGroupSnapshots that were
+// created by the supplied schedule. If the VolumeGroupSnapshot CRD is not
+// installed, there can't be any.
+func groupSnapshotsFromSchedule(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	logger logr.Logger, c client.Client) ([]groupsnapv1beta2.VolumeGroupSnapshot, error) {
+	listOpts := []client.ListOption{
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			scheduleLabelKey(schedule): schedule.Name,
+		},
+	}
+	var groupSnapList groupsnapv1beta2.VolumeGroupSnapshotList
+	if err := c.List(ctx, &groupSnapList, listOpts...); err != nil {
+		if apimeta.IsNoMatchError(err) {
+			logger.V(4).Info("VolumeGroupSnapshot CRD is not installed")
+			return nil, nil
+		}
+		return nil, err
+	}
+	return groupSnapList.Items, nil
+}
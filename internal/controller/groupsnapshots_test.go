@@ -0,0 +1,203 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Group snapshot names", func() {
+	namedSchedule := func(name string) *snapschedulerv1.SnapshotSchedule {
+		return &snapschedulerv1.SnapshotSchedule{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	It("combines the schedule name and time", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		Expect(groupSnapshotName(namedSchedule("db"), when, typeScheduled)).To(Equal("db-202403010230"))
+	})
+	It("marks those of cluster schedules", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		schedule := namedSchedule("db")
+		schedule.Kind = snapschedulerv1.ClusterSnapshotScheduleKind
+		Expect(groupSnapshotName(schedule, when, typeScheduled)).To(Equal("db-cluster-202403010230"))
+	})
+	It("is truncated to a valid length", func() {
+		name := groupSnapshotName(namedSchedule(strings.Repeat("x", 300)), time.Now(), typeScheduled)
+		Expect(len(name)).To(BeNumerically("<=", 253))
+	})
+})
+
+var _ = Describe("newGroupSnapForSchedule", func() {
+	It("selects the schedule's PVCs and carries its labels", func() {
+		schedule := &snapschedulerv1.SnapshotSchedule{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "SnapshotSchedule",
+				APIVersion: snapschedulerv1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db",
+				Namespace: "myns",
+				UID:       "9c9ea1b3-0f1f-4c5b-8a43-8e7d0c0b4a11",
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				ClaimSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "db"},
+				},
+				SnapshotTemplate: &snapschedulerv1.SnapshotTemplateSpec{
+					Labels:            map[string]string{"one": "two"},
+					SnapshotClassName: ptr.To("unused"),
+				},
+				GroupSnapshot: &snapschedulerv1.GroupSnapshotSpec{
+					VolumeGroupSnapshotClassName: ptr.To("groupclass"),
+				},
+			},
+		}
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		groupSnap := newGroupSnapForSchedule("db-202403010230", schedule, when, typeScheduled, true)
+		Expect(groupSnap.Name).To(Equal("db-202403010230"))
+		Expect(groupSnap.Namespace).To(Equal("myns"))
+		Expect(groupSnap.Labels).To(Equal(map[string]string{
+			"one":       "two",
+			ScheduleKey: "db",
+			WhenKey:     "202403010230",
+		}))
+		Expect(groupSnap.Spec.Source.Selector).To(Equal(&schedule.Spec.ClaimSelector))
+		Expect(groupSnap.Spec.VolumeGroupSnapshotClassName).To(Equal(ptr.To("groupclass")))
+		Expect(groupSnap.OwnerReferences).To(HaveLen(1))
+		Expect(groupSnap.OwnerReferences[0].UID).To(Equal(schedule.UID))
+
+		// Modifying the group snapshot must not alter the schedule
+		groupSnap.Spec.Source.Selector.MatchLabels["app"] = "other"
+		groupSnap.Labels["one"] = "three"
+		Expect(schedule.Spec.ClaimSelector.MatchLabels).To(HaveKeyWithValue("app", "db"))
+		Expect(schedule.Spec.SnapshotTemplate.Labels).To(HaveKeyWithValue("one", "two"))
+
+		manual := newGroupSnapForSchedule("db-202403010231", schedule, when, TypeManual, false)
+		Expect(manual.Labels).To(HaveKeyWithValue(TypeKey, TypeManual))
+	})
+})
+
+var _ = Describe("Taking group snapshots", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var pvcs []corev1.PersistentVolumeClaim
+	var snapTime time.Time
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				ClaimSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "db"},
+				},
+				GroupSnapshot: &snapschedulerv1.GroupSnapshotSpec{},
+			},
+		}
+		pvcs = []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "wal", Namespace: ns.Name}},
+		}
+		snapTime, _ = time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	listGroupSnaps := func() []groupsnapv1beta2.VolumeGroupSnapshot {
+		groupSnaps := &groupsnapv1beta2.VolumeGroupSnapshotList{}
+		Expect(k8sClient.List(context.TODO(), groupSnaps, client.InNamespace(ns.Name))).To(Succeed())
+		return groupSnaps.Items
+	}
+
+	It("creates a single group snapshot for all PVCs", func() {
+		Expect(handleGroupSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient, recorder,
+			false)).To(Succeed())
+		Eventually(listGroupSnaps, timeout, interval).Should(HaveLen(1))
+		// Repeating for the same time doesn't create another
+		Expect(handleGroupSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient, recorder,
+			false)).To(Succeed())
+		Consistently(listGroupSnaps, "1s", interval).Should(HaveLen(1))
+		Expect(listGroupSnaps()[0].Name).To(Equal("db-202403010230"))
+	})
+	It("skips the group snapshot if there are no PVCs", func() {
+		Expect(handleGroupSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, nil, logger, k8sClient, recorder,
+			false)).To(Succeed())
+		Consistently(listGroupSnaps, "1s", interval).Should(BeEmpty())
+	})
+	It("refuses to snapshot a group filtered by StorageClass", func() {
+		schedule.Spec.StorageClassSelector = &snapschedulerv1.StorageClassSelector{Names: []string{"fast"}}
+		Expect(handleGroupSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient, recorder,
+			false)).NotTo(Succeed())
+		Consistently(listGroupSnaps, "1s", interval).Should(BeEmpty())
+	})
+	It("applies retention to the group snapshots as a whole", func() {
+		for _, day := range []string{"2024-03-01", "2024-03-02", "2024-03-03"} {
+			when, _ := time.Parse(timeFormat, day+"T02:30:00Z")
+			Expect(handleGroupSnapshotting(context.TODO(), schedule, when, typeScheduled, pvcs, logger, k8sClient, recorder,
+				false)).To(Succeed())
+		}
+		// An unrelated group snapshot in the same namespace
+		other := schedule.DeepCopy()
+		other.Name = "other"
+		Expect(handleGroupSnapshotting(context.TODO(), other, snapTime, typeScheduled, pvcs, logger, k8sClient, recorder,
+			false)).To(Succeed())
+		Eventually(listGroupSnaps, timeout, interval).Should(HaveLen(4))
+
+		schedule.Spec.Retention.Tiers = &snapschedulerv1.TieredRetentionSpec{
+			Daily: ptr.To[int32](2),
+		}
+		groupSnaps, err := groupSnapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupSnaps).To(HaveLen(3))
+		// Only ready group snapshots are subject to tiered retention
+		for i := range groupSnaps {
+			groupSnaps[i].Status = &groupsnapv1beta2.VolumeGroupSnapshotStatus{ReadyToUse: ptr.To(true)}
+			Expect(k8sClient.Status().Update(context.TODO(), &groupSnaps[i])).To(Succeed())
+		}
+		grouped := map[string][]groupsnapv1beta2.VolumeGroupSnapshot{schedule.Name: groupSnaps}
+		Expect(expireSnapshots(context.TODO(), schedule, logger, k8sClient, recorder, groupSnaps, grouped,
+			nil, nil)).To(Succeed())
+
+		Eventually(func() []string {
+			names := []string{}
+			for _, groupSnap := range listGroupSnaps() {
+				names = append(names, groupSnap.Name)
+			}
+			return names
+		}, timeout, interval).Should(ConsistOf("db-202403020230", "db-202403030230", "other-202403010230"))
+	})
+})
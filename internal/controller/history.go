@@ -0,0 +1,313 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+const (
+	// Number of runs kept in a schedule's status
+	maxRunHistory = 10
+	// Number of PVCs listed in each run, so that the status of a schedule
+	// that selects many PVCs remains small
+	maxRunSnapshots = 100
+)
+
+// errSnapshotsSkipped indicates that a run's snapshots were deliberately not
+// taken
+var errSnapshotsSkipped = errors.New("snapshots skipped")
+
+// claimError is an error snapshotting a particular PVC
+type claimError struct {
+	claim string
+	err   error
+}
+
+func (e *claimError) Error() string {
+	return fmt.Sprintf("PVC %s: %v", e.claim, e.err)
+}
+
+func (e *claimError) Unwrap() error {
+	return e.err
+}
+
+// claimErrors are the errors snapshotting each of several PVCs
+type claimErrors []*claimError
+
+func (e claimErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, ce := range e {
+		msgs = append(msgs, ce.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e claimErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, ce := range e {
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// failedClaims returns the errors snapshotting particular PVCs, by PVC name
+func failedClaims(err error) map[string]error {
+	failed := map[string]error{}
+	var errs claimErrors
+	var ce *claimError
+	switch {
+	case errors.As(err, &errs):
+		for _, ce := range errs {
+			failed[ce.claim] = ce.err
+		}
+	case errors.As(err, &ce):
+		failed[ce.claim] = ce.err
+	}
+	return failed
+}
+
+// recordRun adds the outcome of the run at snapTime to the schedule's history,
+// replacing any previous record of the same run
+func recordRun(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time, runType string,
+	pvcs []corev1.PersistentVolumeClaim, err error) {
+	run := snapschedulerv1.SnapshotRunStatus{
+		ScheduledTime: metav1.NewTime(snapTime),
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if trigger := schedule.Status.LastTrigger; runType == TypeManual && trigger != nil {
+		run.Trigger = trigger.Token
+	}
+
+	failed := failedClaims(err)
+	grouped := schedule.Spec.GroupSnapshot != nil
+	if grouped && err == nil && len(pvcs) > 0 {
+		run.VolumeGroupSnapshotName = groupSnapshotName(schedule, snapTime, runType)
+	}
+	for _, pvc := range pvcs {
+		entry := snapschedulerv1.PVCSnapshotStatus{PVCName: pvc.Name}
+		if claimErr, found := failed[pvc.Name]; found {
+			entry.Error = claimErr.Error()
+			run.Snapshots = append(run.Snapshots, entry)
+			continue
+		}
+		if !grouped {
+			entry.SnapshotName = scheduleSnapshotName(pvc.Name, schedule, runNameSuffix(snapTime, runType))
+		}
+		run.Snapshots = append(run.Snapshots, entry)
+	}
+	run.SnapshotCount = int32(len(run.Snapshots))
+	if len(run.Snapshots) > maxRunSnapshots {
+		// The PVCs whose snapshots couldn't be created are the ones worth
+		// listing
+		failing := slices.DeleteFunc(slices.Clone(run.Snapshots), func(entry snapschedulerv1.PVCSnapshotStatus) bool {
+			return entry.Error == ""
+		})
+		rest := slices.DeleteFunc(run.Snapshots, func(entry snapschedulerv1.PVCSnapshotStatus) bool {
+			return entry.Error != ""
+		})
+		run.Snapshots = append(failing, rest...)[:maxRunSnapshots]
+	}
+
+	prev := findRun(schedule, snapTime, runType)
+	if prev != nil {
+		run.FailedAttempts = prev.FailedAttempts
+		run.LastFailureTime = prev.LastFailureTime
+	}
+	if len(failed) > 0 {
+		run.FailedAttempts++
+		now := metav1.Now()
+		run.LastFailureTime = &now
+	}
+
+	if prev != nil {
+		// Keep the readiness that was already observed for this run
+		for i := range run.Snapshots {
+			for _, prevEntry := range prev.Snapshots {
+				if prevEntry.PVCName == run.Snapshots[i].PVCName && run.Snapshots[i].Error == "" {
+					run.Snapshots[i].ReadyToUse = prevEntry.ReadyToUse
+				}
+			}
+		}
+		*prev = run
+	} else {
+		runs := append([]snapschedulerv1.SnapshotRunStatus{run}, schedule.Status.RecentRuns...)
+		if len(runs) > maxRunHistory {
+			runs = runs[:maxRunHistory]
+		}
+		schedule.Status.RecentRuns = runs
+	}
+
+	cond := metav1.Condition{
+		Type:    snapschedulerv1.ConditionLastRunSucceeded,
+		Status:  metav1.ConditionTrue,
+		Reason:  snapschedulerv1.LastRunReasonSucceeded,
+		Message: fmt.Sprintf("%d snapshot(s) created for %s", run.SnapshotCount, snapTime.Format(time.RFC3339)),
+	}
+	if grouped && run.VolumeGroupSnapshotName != "" {
+		cond.Message = fmt.Sprintf("group snapshot %s created", run.VolumeGroupSnapshotName)
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = snapschedulerv1.LastRunReasonFailed
+		if errors.Is(err, errSnapshotsSkipped) {
+			cond.Reason = snapschedulerv1.LastRunReasonSkipped
+		}
+		cond.Message = fmt.Sprintf("run for %s: %s", snapTime.Format(time.RFC3339), err)
+	}
+	apimeta.SetStatusCondition(&schedule.Status.Conditions, cond)
+	setSnapshotsReadyCondition(schedule)
+}
+
+// findRun returns the schedule's record of the run of the given type at
+// snapTime, if there is one
+func findRun(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string) *snapschedulerv1.SnapshotRunStatus {
+	for i := range schedule.Status.RecentRuns {
+		run := &schedule.Status.RecentRuns[i]
+		if run.ScheduledTime.Time.Equal(snapTime) && runTypeOf(run) == runType {
+			return run
+		}
+	}
+	return nil
+}
+
+// runTypeOf returns the type of the recorded run
+func runTypeOf(run *snapschedulerv1.SnapshotRunStatus) string {
+	if run.Trigger != "" {
+		return TypeManual
+	}
+	return typeScheduled
+}
+
+// refreshRunHistory updates the readiness of the snapshots in the schedule's
+// history. Snapshots that no longer exist (e.g., because they have expired)
+// are left as they were.
+func refreshRunHistory(schedule *snapschedulerv1.SnapshotSchedule, snaps []snapv1.VolumeSnapshot,
+	groupSnaps []groupsnapv1beta2.VolumeGroupSnapshot) {
+	if len(schedule.Status.RecentRuns) == 0 {
+		return
+	}
+	snapsByName := make(map[string]*snapv1.VolumeSnapshot, len(snaps))
+	for i := range snaps {
+		snapsByName[snaps[i].Name] = &snaps[i]
+	}
+	groupSnapsByName := make(map[string]*groupsnapv1beta2.VolumeGroupSnapshot, len(groupSnaps))
+	for i := range groupSnaps {
+		groupSnapsByName[groupSnaps[i].Name] = &groupSnaps[i]
+	}
+
+	for i := range schedule.Status.RecentRuns {
+		run := &schedule.Status.RecentRuns[i]
+		var groupStatus *groupsnapv1beta2.VolumeGroupSnapshotStatus
+		if groupSnap, found := groupSnapsByName[run.VolumeGroupSnapshotName]; found {
+			groupStatus = groupSnap.Status
+			if groupStatus == nil {
+				groupStatus = &groupsnapv1beta2.VolumeGroupSnapshotStatus{}
+			}
+		}
+		for j := range run.Snapshots {
+			entry := &run.Snapshots[j]
+			if groupStatus != nil {
+				entry.ReadyToUse = groupStatus.ReadyToUse != nil && *groupStatus.ReadyToUse
+				entry.Error = snapshotErrorMessage(groupStatus.Error)
+				continue
+			}
+			snap, found := snapsByName[entry.SnapshotName]
+			if !found || entry.SnapshotName == "" {
+				continue
+			}
+			entry.ReadyToUse = isSnapshotReady(snap)
+			if snap.Status != nil {
+				entry.Error = snapshotErrorMessage(snap.Status.Error)
+			}
+		}
+	}
+	setSnapshotsReadyCondition(schedule)
+}
+
+// setSnapshotsReadyCondition summarizes the readiness of the snapshots of the
+// most recent run. Only the snapshots listed in the run are considered.
+func setSnapshotsReadyCondition(schedule *snapschedulerv1.SnapshotSchedule) {
+	if len(schedule.Status.RecentRuns) == 0 {
+		return
+	}
+	run := schedule.Status.RecentRuns[0]
+	if len(run.Snapshots) == 0 {
+		// Nothing was snapshotted, so there's nothing to become ready
+		apimeta.RemoveStatusCondition(&schedule.Status.Conditions, snapschedulerv1.ConditionSnapshotsReady)
+		return
+	}
+	ready, pending, failed := 0, 0, 0
+	for _, entry := range run.Snapshots {
+		switch {
+		case entry.Error != "":
+			failed++
+		case entry.ReadyToUse:
+			ready++
+		default:
+			pending++
+		}
+	}
+
+	cond := metav1.Condition{
+		Type:   snapschedulerv1.ConditionSnapshotsReady,
+		Status: metav1.ConditionTrue,
+		Reason: snapschedulerv1.SnapshotsReadyReasonReady,
+		Message: fmt.Sprintf("%d of %d snapshot(s) for %s are ready", ready, len(run.Snapshots),
+			run.ScheduledTime.UTC().Format(time.RFC3339)),
+	}
+	if int(run.SnapshotCount) > len(run.Snapshots) {
+		cond.Message = fmt.Sprintf("%d of the %d listed snapshot(s) for %s are ready", ready, len(run.Snapshots),
+			run.ScheduledTime.UTC().Format(time.RFC3339))
+	}
+	switch {
+	case failed > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = snapschedulerv1.SnapshotsReadyReasonError
+	case pending > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = snapschedulerv1.SnapshotsReadyReasonPending
+	}
+	apimeta.SetStatusCondition(&schedule.Status.Conditions, cond)
+}
+
+// snapshotErrorMessage returns the message of a snapshot's error, if any
+func snapshotErrorMessage(snapErr *snapv1.VolumeSnapshotError) string {
+	if snapErr == nil {
+		return ""
+	}
+	if snapErr.Message != nil && *snapErr.Message != "" {
+		return *snapErr.Message
+	}
+	return "snapshot failed"
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Recording run history", func() {
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var pvcs []corev1.PersistentVolumeClaim
+	var snapTime time.Time
+	BeforeEach(func() {
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "myns"},
+		}
+		pvcs = []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "myns"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "wal", Namespace: "myns"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "logs", Namespace: "myns"}},
+		}
+		snapTime, _ = time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+	})
+	condition := func(conditionType string) *metav1.Condition {
+		return apimeta.FindStatusCondition(schedule.Status.Conditions, conditionType)
+	}
+	snapshot := func(name string, ready bool, errMsg *string) snapv1.VolumeSnapshot {
+		snap := snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "myns"},
+			Status:     &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(ready)},
+		}
+		if errMsg != nil {
+			snap.Status.Error = &snapv1.VolumeSnapshotError{Message: errMsg}
+		}
+		return snap
+	}
+
+	It("records the snapshot of each PVC", func() {
+		recordRun(schedule, snapTime, typeScheduled, pvcs, nil)
+		Expect(schedule.Status.RecentRuns).To(HaveLen(1))
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.ScheduledTime.Time).To(Equal(snapTime))
+		Expect(run.Error).To(BeEmpty())
+		Expect(run.Snapshots).To(Equal([]snapschedulerv1.PVCSnapshotStatus{
+			{PVCName: "data", SnapshotName: "data-db-202403010230"},
+			{PVCName: "wal", SnapshotName: "wal-db-202403010230"},
+			{PVCName: "logs", SnapshotName: "logs-db-202403010230"},
+		}))
+		Expect(condition(snapschedulerv1.ConditionLastRunSucceeded).Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Reason).To(
+			Equal(snapschedulerv1.SnapshotsReadyReasonPending))
+	})
+	It("attributes a failure to its PVC", func() {
+		err := &claimError{claim: "wal", err: errors.New("quota exceeded")}
+		recordRun(schedule, snapTime, typeScheduled, pvcs, err)
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.Error).To(ContainSubstring("quota exceeded"))
+		Expect(run.Snapshots).To(Equal([]snapschedulerv1.PVCSnapshotStatus{
+			{PVCName: "data", SnapshotName: "data-db-202403010230"},
+			{PVCName: "wal", Error: "quota exceeded"},
+			{PVCName: "logs", SnapshotName: "logs-db-202403010230"},
+		}))
+		Expect(run.FailedAttempts).To(Equal(int32(1)))
+		Expect(run.LastFailureTime).NotTo(BeNil())
+		cond := condition(snapschedulerv1.ConditionLastRunSucceeded)
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(snapschedulerv1.LastRunReasonFailed))
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Reason).To(
+			Equal(snapschedulerv1.SnapshotsReadyReasonError))
+	})
+	It("attributes each of several failures to its PVC", func() {
+		err := claimErrors{
+			{claim: "data", err: errors.New("quota exceeded")},
+			{claim: "logs", err: errors.New("no snapshot class")},
+		}
+		recordRun(schedule, snapTime, typeScheduled, pvcs, err)
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.Error).To(Equal("PVC data: quota exceeded; PVC logs: no snapshot class"))
+		Expect(run.Snapshots).To(Equal([]snapschedulerv1.PVCSnapshotStatus{
+			{PVCName: "data", Error: "quota exceeded"},
+			{PVCName: "wal", SnapshotName: "wal-db-202403010230"},
+			{PVCName: "logs", Error: "no snapshot class"},
+		}))
+	})
+	It("counts the failed attempts at a run", func() {
+		err := &claimError{claim: "wal", err: errors.New("quota exceeded")}
+		recordRun(schedule, snapTime, typeScheduled, pvcs, err)
+		recordRun(schedule, snapTime, typeScheduled, pvcs, err)
+		Expect(schedule.Status.RecentRuns[0].FailedAttempts).To(Equal(int32(2)))
+		// A later success keeps the count
+		recordRun(schedule, snapTime, typeScheduled, pvcs, nil)
+		Expect(schedule.Status.RecentRuns[0].FailedAttempts).To(Equal(int32(2)))
+		Expect(schedule.Status.RecentRuns[0].Error).To(BeEmpty())
+	})
+	It("records skipped runs", func() {
+		recordRun(schedule, snapTime, typeScheduled, nil, fmt.Errorf("%w: pre-snapshot hook failed", errSnapshotsSkipped))
+		Expect(schedule.Status.RecentRuns[0].Snapshots).To(BeEmpty())
+		Expect(condition(snapschedulerv1.ConditionLastRunSucceeded).Reason).To(
+			Equal(snapschedulerv1.LastRunReasonSkipped))
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady)).To(BeNil())
+	})
+	It("records the group snapshot", func() {
+		schedule.Spec.GroupSnapshot = &snapschedulerv1.GroupSnapshotSpec{}
+		recordRun(schedule, snapTime, typeScheduled, pvcs[:2], nil)
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.VolumeGroupSnapshotName).To(Equal("db-202403010230"))
+		Expect(run.Snapshots).To(Equal([]snapschedulerv1.PVCSnapshotStatus{
+			{PVCName: "data"},
+			{PVCName: "wal"},
+		}))
+
+		groupSnap := groupsnapv1beta2.VolumeGroupSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-202403010230", Namespace: "myns"},
+			Status:     &groupsnapv1beta2.VolumeGroupSnapshotStatus{ReadyToUse: ptr.To(true)},
+		}
+		refreshRunHistory(schedule, nil, []groupsnapv1beta2.VolumeGroupSnapshot{groupSnap})
+		Expect(schedule.Status.RecentRuns[0].Snapshots[0].ReadyToUse).To(BeTrue())
+		Expect(schedule.Status.RecentRuns[0].Snapshots[1].ReadyToUse).To(BeTrue())
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Status).To(Equal(metav1.ConditionTrue))
+	})
+	It("keeps a bounded history, newest first", func() {
+		for i := range maxRunHistory + 5 {
+			recordRun(schedule, snapTime.Add(time.Duration(i)*time.Hour), typeScheduled, pvcs, nil)
+		}
+		Expect(schedule.Status.RecentRuns).To(HaveLen(maxRunHistory))
+		Expect(schedule.Status.RecentRuns[0].ScheduledTime.Time).To(
+			Equal(snapTime.Add(time.Duration(maxRunHistory+4) * time.Hour)))
+		Expect(schedule.Status.RecentRuns[maxRunHistory-1].ScheduledTime.Time).To(
+			Equal(snapTime.Add(5 * time.Hour)))
+	})
+	It("lists a bounded number of PVCs, failures first", func() {
+		many := []corev1.PersistentVolumeClaim{}
+		for i := range maxRunSnapshots + 10 {
+			many = append(many, corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pvc%03d", i), Namespace: "myns"},
+			})
+		}
+		last := many[len(many)-1].Name
+		recordRun(schedule, snapTime, typeScheduled, many, &claimError{claim: last, err: errors.New("boom")})
+		run := schedule.Status.RecentRuns[0]
+		Expect(run.SnapshotCount).To(Equal(int32(maxRunSnapshots + 10)))
+		Expect(run.Snapshots).To(HaveLen(maxRunSnapshots))
+		Expect(run.Snapshots[0]).To(Equal(snapschedulerv1.PVCSnapshotStatus{PVCName: last, Error: "boom"}))
+		Expect(run.Snapshots[1].PVCName).To(Equal("pvc000"))
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Message).To(
+			ContainSubstring("of the 100 listed snapshot(s)"))
+	})
+	It("keeps a manual run apart from the scheduled run at the same time", func() {
+		schedule.Status.LastTrigger = &snapschedulerv1.TriggerStatus{
+			Token: "before-deploy",
+			Time:  metav1.NewTime(snapTime),
+		}
+		recordRun(schedule, snapTime, typeScheduled, pvcs[:1], nil)
+		recordRun(schedule, snapTime, TypeManual, pvcs[:1], &claimError{claim: "data", err: errors.New("boom")})
+		recordRun(schedule, snapTime, TypeManual, pvcs[:1], nil)
+		Expect(schedule.Status.RecentRuns).To(HaveLen(2))
+		manual := schedule.Status.RecentRuns[0]
+		Expect(manual.Trigger).To(Equal("before-deploy"))
+		Expect(manual.FailedAttempts).To(Equal(int32(1)))
+		Expect(manual.Snapshots[0].SnapshotName).To(Equal("data-db-202403010230-manual"))
+		scheduled := schedule.Status.RecentRuns[1]
+		Expect(scheduled.Trigger).To(BeEmpty())
+		Expect(scheduled.FailedAttempts).To(BeZero())
+		Expect(scheduled.Snapshots[0].SnapshotName).To(Equal("data-db-202403010230"))
+		Expect(findRun(schedule, snapTime, typeScheduled)).To(Equal(&schedule.Status.RecentRuns[1]))
+	})
+	It("replaces the record when a run is retried", func() {
+		recordRun(schedule, snapTime, typeScheduled, pvcs, &claimError{claim: "data", err: errors.New("boom")})
+		recordRun(schedule, snapTime, typeScheduled, pvcs, nil)
+		Expect(schedule.Status.RecentRuns).To(HaveLen(1))
+		Expect(schedule.Status.RecentRuns[0].Error).To(BeEmpty())
+		Expect(schedule.Status.RecentRuns[0].Snapshots).To(HaveLen(3))
+	})
+	It("tracks the readiness of the snapshots", func() {
+		earlier := snapTime.Add(-time.Hour)
+		recordRun(schedule, earlier, typeScheduled, pvcs[:1], nil)
+		recordRun(schedule, snapTime, typeScheduled, pvcs[:2], nil)
+		snaps := []snapv1.VolumeSnapshot{
+			snapshot("data-db-202403010130", true, nil),
+			snapshot("data-db-202403010230", true, nil),
+			snapshot("wal-db-202403010230", false, nil),
+		}
+		refreshRunHistory(schedule, snaps, nil)
+		Expect(schedule.Status.RecentRuns[1].Snapshots[0].ReadyToUse).To(BeTrue())
+		Expect(schedule.Status.RecentRuns[0].Snapshots[0].ReadyToUse).To(BeTrue())
+		Expect(schedule.Status.RecentRuns[0].Snapshots[1].ReadyToUse).To(BeFalse())
+		cond := condition(snapschedulerv1.ConditionSnapshotsReady)
+		Expect(cond.Reason).To(Equal(snapschedulerv1.SnapshotsReadyReasonPending))
+		Expect(cond.Message).To(ContainSubstring("1 of 2"))
+
+		snaps[2] = snapshot("wal-db-202403010230", true, nil)
+		refreshRunHistory(schedule, snaps, nil)
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Status).To(Equal(metav1.ConditionTrue))
+
+		snaps[2] = snapshot("wal-db-202403010230", false, ptr.To("driver error"))
+		refreshRunHistory(schedule, snaps, nil)
+		Expect(schedule.Status.RecentRuns[0].Snapshots[1].Error).To(Equal("driver error"))
+		Expect(condition(snapschedulerv1.ConditionSnapshotsReady).Reason).To(
+			Equal(snapschedulerv1.SnapshotsReadyReasonError))
+
+		// Expired snapshots keep their last known state
+		refreshRunHistory(schedule, nil, nil)
+		Expect(schedule.Status.RecentRuns[1].Snapshots[0].ReadyToUse).To(BeTrue())
+	})
+})
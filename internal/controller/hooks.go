@@ -0,0 +1,614 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/tools/remotecommand"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=pods/exec,verbs=create
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+const (
+	// Time allowed for a hook that doesn't specify a timeout
+	defaultHookTimeout = 30 * time.Second
+	// How often to check on a running hook Job
+	hookPollInterval = 5 * time.Second
+	// How long to keep finished hook Jobs that don't specify their own TTL
+	defaultHookJobTTLSeconds = int32(24 * 60 * 60)
+	// Max amount of time the post-snapshot hook waits for the snapshots to be
+	// cut before running anyway
+	maxSnapshotCutWait = 5 * time.Minute
+	// Names of the hook phases, used in Job names and messages
+	hookPhasePre  = "pre"
+	hookPhasePost = "post"
+)
+
+// errHookTimedOut indicates that a hook did not finish within its timeout
+var errHookTimedOut = errors.New("hook timed out")
+
+// errHooksDisabled indicates that the controller was started without hooks
+var errHooksDisabled = errors.New("hooks are disabled; the controller must be started with --enable-hooks")
+
+// podExecutor runs a command in a container of a pod
+type podExecutor interface {
+	Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error
+}
+
+// remotePodExecutor runs commands via the pods/exec subresource
+type remotePodExecutor struct {
+	config     *rest.Config
+	restClient rest.Interface
+}
+
+// newPodExecutor returns a podExecutor that uses the API server at config
+func newPodExecutor(config *rest.Config, httpClient *http.Client) (podExecutor, error) {
+	clientset, err := kubernetes.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &remotePodExecutor{
+		config:     config,
+		restClient: clientset.CoreV1().RESTClient(),
+	}, nil
+}
+
+func (e *remotePodExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
+	req := e.restClient.Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	// Prefer websockets, falling back to SPDY for older API servers
+	wsExec, err := remotecommand.NewWebSocketExecutor(e.config, "GET", req.URL().String())
+	if err != nil {
+		return err
+	}
+	spdyExec, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	executor, err := remotecommand.NewFallbackExecutor(wsExec, spdyExec, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// hookRunner carries out the schedules' hooks. Exec hooks run in the
+// background so that they don't hold up the reconcile, and their outcome is
+// kept until the run is done. A nil hookRunner means that hooks are disabled.
+type hookRunner struct {
+	executor podExecutor
+	mu       sync.Mutex
+	execRuns map[execRunKey]*execRun
+}
+
+// execRunKey identifies the exec hook of one phase of a schedule's run
+type execRunKey struct {
+	schedule scheduleID
+	phase    string
+	when     time.Time
+	runType  string
+}
+
+// execRun is the outcome of an exec hook, once it is done
+type execRun struct {
+	done bool
+	err  error
+}
+
+func newHookRunner(executor podExecutor) *hookRunner {
+	return &hookRunner{
+		executor: executor,
+		execRuns: make(map[execRunKey]*execRun),
+	}
+}
+
+// started reports whether the exec hook has been started for a run that isn't
+// done yet
+func (h *hookRunner) started(key execRunKey) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, exists := h.execRuns[key]
+	return exists
+}
+
+// forget drops the outcome of the schedule's exec hooks. Hooks that are still
+// running finish on their own.
+func (h *hookRunner) forget(id scheduleID) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.execRuns {
+		if key.schedule == id {
+			delete(h.execRuns, key)
+		}
+	}
+}
+
+// forgetRun drops the outcome of the exec hooks of one of the schedule's runs
+func (h *hookRunner) forgetRun(id scheduleID, when time.Time, runType string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.execRuns {
+		if key.schedule == id && key.when.Equal(when) && key.runType == runType {
+			delete(h.execRuns, key)
+		}
+	}
+}
+
+// snapshotWithHooks takes the snapshots for the scheduled time, running the
+// schedule's hooks around them. Hooks run asynchronously, so this is repeated
+// on subsequent reconciles until the post-snapshot hook finishes. The hooks and
+// snapshots record how far the previous attempts got.
+func snapshotWithHooks(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, enableOwnerReferences bool, runner *hookRunner) (ctrl.Result, error) {
+	hooks := schedule.Spec.Hooks
+	found, _, _, err := snapshotProgress(ctx, schedule, snapTime, runType, c)
+	if err != nil {
+		logger.Error(err, "unable to retrieve snapshots for the scheduled time")
+		return ctrl.Result{}, err
+	}
+
+	// Once the post-snapshot hook has started, it's too late to run the
+	// pre-snapshot hook or take the snapshots
+	postStarted, err := hookStarted(ctx, schedule, snapTime, runType, hookPhasePost, hooks.Post, c, runner)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	skipSnapshots := !found && postStarted
+	if hooks.Pre != nil && !found && !postStarted {
+		done, err := runHook(ctx, schedule, snapTime, runType, hookPhasePre, hooks.Pre, logger, c, runner)
+		if !done && err != nil {
+			return ctrl.Result{}, err
+		}
+		setHookCondition(schedule, snapschedulerv1.ConditionPreSnapshotHook, hookPhasePre, snapTime, done, err)
+		if !done {
+			return ctrl.Result{RequeueAfter: hookPollInterval}, nil
+		}
+		if err != nil {
+			logger.Error(err, "pre-snapshot hook failed", "onFailure", hookFailurePolicy(hooks.Pre))
+			if hookFailurePolicy(hooks.Pre) == snapschedulerv1.HookFailureSkip {
+				skipSnapshots = true
+				recordRun(schedule, snapTime, runType, nil, fmt.Errorf("%w: pre-snapshot hook failed", errSnapshotsSkipped))
+			}
+		}
+	}
+
+	var snapErr error
+	if !skipSnapshots {
+		snapErr = takeSnapshots(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder, enableOwnerReferences)
+		if wait, throttled := creationThrottled(snapErr); throttled {
+			// The outcome of the pre-snapshot hook is kept, so it isn't run
+			// again when the snapshots are retried
+			logger.V(4).Info("snapshot creation throttled", "wait", wait)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		recordRun(schedule, snapTime, runType, pvcs, snapErr)
+	}
+
+	if hooks.Post != nil {
+		if snapErr == nil && !skipSnapshots {
+			// Application I/O should only resume once the snapshots have been
+			// cut by the storage system
+			found, cut, since, err := snapshotProgress(ctx, schedule, snapTime, runType, c)
+			if err != nil {
+				logger.Error(err, "unable to retrieve snapshots for the scheduled time")
+				return ctrl.Result{}, err
+			}
+			if found && !cut && time.Since(since) < maxSnapshotCutWait {
+				logger.V(4).Info("waiting for snapshots to be cut before running post-snapshot hook")
+				return ctrl.Result{RequeueAfter: hookPollInterval}, nil
+			}
+		}
+		done, err := runHook(ctx, schedule, snapTime, runType, hookPhasePost, hooks.Post, logger, c, runner)
+		if !done && err != nil {
+			return ctrl.Result{}, err
+		}
+		setHookCondition(schedule, snapschedulerv1.ConditionPostSnapshotHook, hookPhasePost, snapTime, done, err)
+		if !done {
+			return ctrl.Result{RequeueAfter: hookPollInterval}, nil
+		}
+		if err != nil {
+			// The snapshots have already been taken, so they are kept
+			logger.Error(err, "post-snapshot hook failed")
+		}
+	}
+
+	// Retrying the run, if needed, starts over with the pre-snapshot hook
+	runner.forgetRun(scheduleIDFor(schedule), snapTime, runType)
+	if snapErr != nil && !abandonFailedClaims(schedule, snapTime, runType, snapErr, logger, recorder) {
+		return ctrl.Result{}, snapErr
+	}
+	if skipSnapshots {
+		logger.Info("skipped snapshots for the scheduled time")
+	}
+	return advanceSchedule(schedule, runType, logger)
+}
+
+// hookFailurePolicy returns the hook's effective onFailure policy
+func hookFailurePolicy(hook *snapschedulerv1.SnapshotHook) snapschedulerv1.HookFailurePolicy {
+	if hook.OnFailure == "" {
+		return snapschedulerv1.HookFailureSkip
+	}
+	return hook.OnFailure
+}
+
+// hookTimeout returns the amount of time the hook is allowed to run
+func hookTimeout(hook *snapschedulerv1.SnapshotHook) time.Duration {
+	if hook.TimeoutSeconds == nil {
+		return defaultHookTimeout
+	}
+	return time.Duration(*hook.TimeoutSeconds) * time.Second
+}
+
+// runHook runs the hook for the scheduled time. It returns false if the hook
+// is still running, or if it could not be started. Once the hook is done, the
+// error describes its failure.
+func runHook(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time, runType string,
+	phase string, hook *snapschedulerv1.SnapshotHook, logger logr.Logger, c client.Client,
+	runner *hookRunner) (bool, error) {
+	switch {
+	case runner == nil:
+		return true, errHooksDisabled
+	case hook.Exec != nil:
+		return runExecHook(ctx, schedule, snapTime, runType, phase, hook, logger, c, runner)
+	case hook.Job != nil:
+		return runJobHook(ctx, schedule, snapTime, runType, phase, hook, logger, c)
+	default:
+		return true, errors.New("hook must specify either exec or job")
+	}
+}
+
+// runExecHook starts the hook's command for the scheduled time in the
+// background and reports its outcome once it is done
+func runExecHook(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, phase string, hook *snapschedulerv1.SnapshotHook, logger logr.Logger, c client.Client,
+	runner *hookRunner) (bool, error) {
+	key := execRunKey{schedule: scheduleIDFor(schedule), phase: phase, when: snapTime, runType: runType}
+	runner.mu.Lock()
+	run, exists := runner.execRuns[key]
+	if exists {
+		done, err := run.done, run.err
+		runner.mu.Unlock()
+		return done, err
+	}
+	runner.mu.Unlock()
+
+	pods, err := hookPods(ctx, schedule, hook, c)
+	if err != nil {
+		return true, err
+	}
+
+	logger.Info("running hook command", "phase", phase)
+	run = &execRun{}
+	runner.mu.Lock()
+	runner.execRuns[key] = run
+	runner.mu.Unlock()
+	// The command outlives this reconcile, but not the hook's timeout
+	execCtx := context.WithoutCancel(ctx)
+	go func() {
+		err := execInPods(execCtx, pods, hook, logger, runner.executor)
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		run.done = true
+		run.err = err
+	}()
+	return false, nil
+}
+
+// hookPods returns the running pods that the exec hook selects
+func hookPods(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, hook *snapschedulerv1.SnapshotHook,
+	c client.Client) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&hook.Exec.PodSelector)
+	if err != nil {
+		return nil, err
+	}
+	podList := &corev1.PodList{}
+	if err = c.List(ctx, podList, client.InNamespace(schedule.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp.IsZero() {
+			pods = append(pods, pod)
+		}
+	}
+	if len(pods) == 0 {
+		return nil, errors.New("no running pods match the hook's podSelector")
+	}
+	return pods, nil
+}
+
+// execInPods runs the exec hook's command in each of the pods, stopping at the
+// first failure
+func execInPods(ctx context.Context, pods []corev1.Pod, hook *snapschedulerv1.SnapshotHook, logger logr.Logger,
+	executor podExecutor) error {
+	execCtx, cancel := context.WithTimeout(ctx, hookTimeout(hook))
+	defer cancel()
+	for i := range pods {
+		pod := &pods[i]
+		container := hook.Exec.Container
+		if container == "" && len(pod.Spec.Containers) > 0 {
+			container = pod.Spec.Containers[0].Name
+		}
+		logger.V(4).Info("executing hook command", "pod", pod.Name, "container", container)
+		if err := executor.Exec(execCtx, pod, container, hook.Exec.Command); err != nil {
+			if errors.Is(execCtx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: pod %s", errHookTimedOut, pod.Name)
+			}
+			return fmt.Errorf("pod %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// runJobHook ensures the hook's Job for the scheduled time exists and reports
+// its outcome. Jobs that exceed the hook's timeout are deleted.
+func runJobHook(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, phase string, hook *snapschedulerv1.SnapshotHook, logger logr.Logger,
+	c client.Client) (bool, error) {
+	jobName := hookJobName(schedule, phase, snapTime, runType)
+	job := &batchv1.Job{}
+	err := c.Get(ctx, types.NamespacedName{Name: jobName, Namespace: schedule.Namespace}, job)
+	if kerrors.IsNotFound(err) {
+		job = newHookJob(jobName, schedule, snapTime, hook)
+		logger.Info("creating hook job", "phase", phase, "Job", jobName)
+		if err = c.Create(ctx, job); kerrors.IsAlreadyExists(err) {
+			// Created by an earlier pass that the cache hasn't caught up with
+			return false, nil
+		}
+		return false, err
+	}
+	if err != nil {
+		logger.Error(err, "looking for hook job", "name", jobName)
+		return false, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type { //nolint:exhaustive // Only the terminal conditions are of interest
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return true, fmt.Errorf("job %s failed: %s", jobName, cond.Message)
+		}
+	}
+
+	if time.Since(job.CreationTimestamp.Time) > hookTimeout(hook) {
+		logger.Info("hook job timed out; deleting", "Job", jobName)
+		if err = c.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+			!kerrors.IsNotFound(err) {
+			return false, err
+		}
+		return true, fmt.Errorf("%w: job %s", errHookTimedOut, jobName)
+	}
+	return false, nil
+}
+
+// hookStarted reports whether the hook has been started for the scheduled
+// time. Exec hooks count as started until the run is done.
+func hookStarted(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, phase string, hook *snapschedulerv1.SnapshotHook, c client.Client,
+	runner *hookRunner) (bool, error) {
+	if hook == nil {
+		return false, nil
+	}
+	if hook.Job == nil {
+		key := execRunKey{schedule: scheduleIDFor(schedule), phase: phase, when: snapTime, runType: runType}
+		return runner.started(key), nil
+	}
+	key := types.NamespacedName{Name: hookJobName(schedule, phase, snapTime, runType), Namespace: schedule.Namespace}
+	err := c.Get(ctx, key, &batchv1.Job{})
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// hookJobName returns the name of the schedule's hook Job for the given phase
+// and run. Jobs are limited to the length of a label value since their name
+// is applied to their pods as a label.
+func hookJobName(schedule *snapschedulerv1.SnapshotSchedule, phase string, time time.Time, runType string) string {
+	suffix := scheduleNameSuffix(schedule, phase+"-"+runNameSuffix(time, runType))
+	scheduleName := schedule.Name
+	nameBudget := validation.LabelValueMaxLength - len(suffix) - 1
+	if len(scheduleName) > nameBudget {
+		scheduleName = scheduleName[0:nameBudget]
+	}
+	return scheduleName + "-" + suffix
+}
+
+// newHookJob returns the Job that carries out a Job hook
+func newHookJob(name string, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	hook *snapschedulerv1.SnapshotHook) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				scheduleLabelKey(schedule): schedule.Name,
+				WhenKey:                    snapTime.Format(timeYYYYMMDDHHMMSS),
+			},
+			// Deleted along with the schedule, if they outlive their TTL
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: snapschedulerv1.GroupVersion.String(),
+				Kind:       scheduleKind(schedule),
+				Name:       schedule.Name,
+				UID:        schedule.UID,
+			}},
+		},
+		Spec: *hook.Job.Template.DeepCopy(),
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		ttl := defaultHookJobTTLSeconds
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	return job
+}
+
+// setHookCondition records the outcome of a hook in the schedule's status
+func setHookCondition(schedule *snapschedulerv1.SnapshotSchedule, conditionType string, phase string,
+	snapTime time.Time, done bool, err error) {
+	cond := metav1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  snapschedulerv1.HookReasonSucceeded,
+		Message: fmt.Sprintf("%s-snapshot hook for %s succeeded", phase, snapTime.Format(time.RFC3339)),
+	}
+	switch {
+	case !done:
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = snapschedulerv1.HookReasonRunning
+		cond.Message = fmt.Sprintf("%s-snapshot hook for %s is running", phase, snapTime.Format(time.RFC3339))
+	case errors.Is(err, errHookTimedOut):
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = snapschedulerv1.HookReasonTimedOut
+		cond.Message = fmt.Sprintf("%s-snapshot hook for %s: %s", phase, snapTime.Format(time.RFC3339), err)
+	case err != nil:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = snapschedulerv1.HookReasonFailed
+		cond.Message = fmt.Sprintf("%s-snapshot hook for %s: %s", phase, snapTime.Format(time.RFC3339), err)
+	}
+	apimeta.SetStatusCondition(&schedule.Status.Conditions, cond)
+}
+
+// snapshotProgress reports whether any snapshots have been created for the
+// run at the scheduled time and, if so, whether all of them have been cut (or
+// have failed). It also returns when the earliest of them was created.
+func snapshotProgress(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, c client.Client) (bool, bool, time.Time, error) {
+	listOpts := []client.ListOption{
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			scheduleLabelKey(schedule): schedule.Name,
+			WhenKey:                    snapTime.Format(timeYYYYMMDDHHMMSS),
+		},
+	}
+	var since time.Time
+	noteCreation := func(created metav1.Time) {
+		if since.IsZero() || created.Time.Before(since) {
+			since = created.Time
+		}
+	}
+
+	// A manual run and a scheduled run can share the scheduled time
+	found := false
+	ofRun := func(obj metav1.Object) bool {
+		if obj.GetLabels()[TypeKey] != runType {
+			return false
+		}
+		found = true
+		return true
+	}
+
+	cut := true
+	if schedule.Spec.GroupSnapshot != nil {
+		groupSnapList := &groupsnapv1beta2.VolumeGroupSnapshotList{}
+		if err := c.List(ctx, groupSnapList, listOpts...); err != nil && !apimeta.IsNoMatchError(err) {
+			return false, false, since, err
+		}
+		for _, groupSnap := range groupSnapList.Items {
+			if !ofRun(&groupSnap) {
+				continue
+			}
+			noteCreation(groupSnap.CreationTimestamp)
+			status := groupSnap.Status
+			if status == nil || (status.CreationTime == nil && status.Error == nil) {
+				cut = false
+			}
+		}
+		return found, cut, since, nil
+	}
+
+	snapList := &snapv1.VolumeSnapshotList{}
+	if err := c.List(ctx, snapList, listOpts...); err != nil {
+		return false, false, since, err
+	}
+	for _, snap := range snapList.Items {
+		if !ofRun(&snap) {
+			continue
+		}
+		noteCreation(snap.CreationTimestamp)
+		status := snap.Status
+		if status == nil || (status.CreationTime == nil && status.Error == nil) {
+			cut = false
+		}
+	}
+	return found, cut, since, nil
+}
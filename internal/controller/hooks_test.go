@@ -0,0 +1,528 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// staleJobClient doesn't find Jobs, like a cache that hasn't caught up with
+// their creation
+type staleJobClient struct {
+	client.Client
+}
+
+func (c *staleJobClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	if _, ok := obj.(*batchv1.Job); ok {
+		return kerrors.NewNotFound(batchv1.Resource("jobs"), key.Name)
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// fakeExecutor records the commands it is asked to run instead of running them
+type fakeExecutor struct {
+	mu    sync.Mutex
+	calls []string
+	// Commands (by their first word) that fail
+	failures map[string]error
+	// Whether commands run until they are canceled
+	block bool
+}
+
+func (e *fakeExecutor) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
+	e.mu.Lock()
+	e.calls = append(e.calls, pod.Name+"/"+container+": "+strings.Join(command, " "))
+	e.mu.Unlock()
+	if e.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return e.failures[command[0]]
+}
+
+// Calls returns the commands that have been run so far
+func (e *fakeExecutor) Calls() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.calls)
+}
+
+var _ = Describe("Hook Job names", func() {
+	namedSchedule := func(name string) *snapschedulerv1.SnapshotSchedule {
+		return &snapschedulerv1.SnapshotSchedule{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	It("combines the schedule name, phase, and time", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		Expect(hookJobName(namedSchedule("db"), hookPhasePre, when, typeScheduled)).To(Equal("db-pre-202403010230"))
+		Expect(hookJobName(namedSchedule("db"), hookPhasePre, when, TypeManual)).To(
+			Equal("db-pre-202403010230-manual"))
+	})
+	It("marks those of cluster schedules", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		schedule := namedSchedule("db")
+		schedule.Kind = snapschedulerv1.ClusterSnapshotScheduleKind
+		Expect(hookJobName(schedule, hookPhasePre, when, typeScheduled)).To(Equal("db-cluster-pre-202403010230"))
+	})
+	It("is short enough to be used as a label value", func() {
+		name := hookJobName(namedSchedule(strings.Repeat("x", 100)), hookPhasePost, time.Now(), typeScheduled)
+		Expect(len(name)).To(BeNumerically("<=", 63))
+		Expect(name).To(HaveSuffix("-post-" + time.Now().Format(timeYYYYMMDDHHMMSS)))
+	})
+})
+
+var _ = Describe("newHookJob", func() {
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var hook *snapschedulerv1.SnapshotHook
+	BeforeEach(func() {
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "myns", UID: "db-uid"},
+		}
+		hook = &snapschedulerv1.SnapshotHook{
+			Job: &snapschedulerv1.JobHook{Template: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "freeze", Image: "busybox"}},
+				}},
+			}},
+		}
+	})
+	It("labels the Job and limits its lifetime", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		job := newHookJob("db-pre-202403010230", schedule, when, hook)
+		Expect(job.Namespace).To(Equal("myns"))
+		Expect(job.Labels).To(Equal(map[string]string{
+			ScheduleKey: "db",
+			WhenKey:     "202403010230",
+		}))
+		Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal("freeze"))
+		Expect(job.Spec.TTLSecondsAfterFinished).To(Equal(ptr.To(defaultHookJobTTLSeconds)))
+		Expect(job.OwnerReferences).To(ConsistOf(metav1.OwnerReference{
+			APIVersion: snapschedulerv1.GroupVersion.String(),
+			Kind:       snapschedulerv1.SnapshotScheduleKind,
+			Name:       "db",
+			UID:        "db-uid",
+		}))
+
+		// Modifying the Job must not alter the schedule
+		job.Spec.Template.Spec.Containers[0].Name = "other"
+		Expect(hook.Job.Template.Template.Spec.Containers[0].Name).To(Equal("freeze"))
+	})
+	It("keeps the template's TTL", func() {
+		hook.Job.Template.TTLSecondsAfterFinished = ptr.To[int32](60)
+		job := newHookJob("db-pre-202403010230", schedule, time.Now(), hook)
+		Expect(job.Spec.TTLSecondsAfterFinished).To(Equal(ptr.To[int32](60)))
+	})
+})
+
+var _ = Describe("Taking snapshots with hooks", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var pvcs []corev1.PersistentVolumeClaim
+	var snapTime time.Time
+	var executor *fakeExecutor
+	var runner *hookRunner
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		snapTime, _ = time.Parse(timeFormat, "2024-03-01T02:30:00Z")
+		next := metav1.NewTime(snapTime)
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "30 2 * * *",
+				Hooks: &snapschedulerv1.SnapshotHooksSpec{
+					Pre: &snapschedulerv1.SnapshotHook{
+						Exec: &snapschedulerv1.ExecHook{
+							PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+							Command:     []string{"freeze"},
+						},
+					},
+					Post: &snapschedulerv1.SnapshotHook{
+						Exec: &snapschedulerv1.ExecHook{
+							PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+							Container:   "sidecar",
+							Command:     []string{"thaw"},
+						},
+					},
+				},
+			},
+			Status: snapschedulerv1.SnapshotScheduleStatus{NextSnapshotTime: &next},
+		}
+		pvcs = []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name}},
+		}
+		executor = &fakeExecutor{}
+		runner = newHookRunner(executor)
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db-0",
+				Namespace: ns.Name,
+				Labels:    map[string]string{"app": "db"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "db", Image: "db"},
+					{Name: "sidecar", Image: "sidecar"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), pod)).To(Succeed())
+		pod.Status.Phase = corev1.PodRunning
+		Expect(k8sClient.Status().Update(context.TODO(), pod)).To(Succeed())
+		Eventually(func() corev1.PodPhase {
+			p := &corev1.Pod{}
+			Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(pod), p)).To(Succeed())
+			return p.Status.Phase
+		}, timeout, interval).Should(Equal(corev1.PodRunning))
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	listSnaps := func() []snapv1.VolumeSnapshot {
+		snaps := &snapv1.VolumeSnapshotList{}
+		Expect(k8sClient.List(context.TODO(), snaps, client.InNamespace(ns.Name))).To(Succeed())
+		return snaps.Items
+	}
+	markSnapsCut := func() {
+		for _, snap := range listSnaps() {
+			snap.Status = &snapv1.VolumeSnapshotStatus{CreationTime: ptr.To(metav1.Now())}
+			Expect(k8sClient.Status().Update(context.TODO(), &snap)).To(Succeed())
+		}
+		Eventually(func() bool {
+			for _, snap := range listSnaps() {
+				if snap.Status == nil || snap.Status.CreationTime == nil {
+					return false
+				}
+			}
+			return true
+		}, timeout, interval).Should(BeTrue())
+	}
+	reconcileOnce := func() (ctrl.Result, error) {
+		return snapshotWithHooks(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger, k8sClient,
+			recorder, false, runner)
+	}
+	pendingCommands := func() int {
+		if runner == nil {
+			return 0
+		}
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		pending := 0
+		for _, run := range runner.execRuns {
+			if !run.done {
+				pending++
+			}
+		}
+		return pending
+	}
+	// run reconciles until the hook commands that were started have finished
+	// and their outcome has been collected
+	run := func() (bool, error) {
+		var result ctrl.Result
+		var err error
+		Eventually(func() bool {
+			finished := pendingCommands() == 0
+			result, err = reconcileOnce()
+			return err != nil || (finished && pendingCommands() == 0)
+		}, timeout, interval).Should(BeTrue())
+		return result.RequeueAfter > 0, err
+	}
+	hookCondition := func(conditionType string) *metav1.Condition {
+		return apimeta.FindStatusCondition(schedule.Status.Conditions, conditionType)
+	}
+
+	It("freezes, snapshots, and thaws once the snapshots are cut", func() {
+		requeue, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeue).To(BeTrue())
+		Expect(executor.Calls()).To(Equal([]string{"db-0/db: freeze"}))
+		Eventually(listSnaps, timeout, interval).Should(HaveLen(1))
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonSucceeded))
+		Expect(schedule.Status.NextSnapshotTime.Time).To(Equal(snapTime))
+
+		markSnapsCut()
+		requeue, err = run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeue).To(BeFalse())
+		Expect(executor.Calls()).To(Equal([]string{"db-0/db: freeze", "db-0/sidecar: thaw"}))
+		Expect(hookCondition(snapschedulerv1.ConditionPostSnapshotHook).Status).To(Equal(metav1.ConditionTrue))
+		Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally(">", snapTime))
+		Expect(schedule.Status.LastSnapshotTime).NotTo(BeNil())
+	})
+	It("doesn't run the pre-snapshot hook again while the snapshots are throttled", func() {
+		limiter := NewSnapshotCreationLimiter(0.05, 1, 0)
+		release, _ := limiter.tryAcquire(0)
+		release()
+		throttledOnce := func() ctrl.Result {
+			result, err := snapshotWithHooks(context.TODO(), schedule, snapTime, typeScheduled, pvcs, logger,
+				limiter.Client(k8sClient), recorder, false, runner)
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+		Eventually(func() time.Duration {
+			return throttledOnce().RequeueAfter
+		}, timeout, interval).Should(BeNumerically(">", hookPollInterval))
+		Expect(listSnaps()).To(BeEmpty())
+		Expect(throttledOnce().RequeueAfter).To(BeNumerically(">", hookPollInterval))
+
+		_, err := reconcileOnce()
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(listSnaps, timeout, interval).Should(HaveLen(1))
+		Expect(executor.Calls()).To(Equal([]string{"db-0/db: freeze"}))
+	})
+	It("skips the snapshots if the pre-snapshot hook fails", func() {
+		executor.failures = map[string]error{"freeze": errors.New("device busy")}
+		requeue, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeue).To(BeFalse())
+		Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+		cond := hookCondition(snapschedulerv1.ConditionPreSnapshotHook)
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(snapschedulerv1.HookReasonFailed))
+		Expect(cond.Message).To(ContainSubstring("device busy"))
+		Expect(hookCondition(snapschedulerv1.ConditionLastRunSucceeded).Reason).To(
+			Equal(snapschedulerv1.LastRunReasonSkipped))
+		// The post-snapshot hook still undoes any partial effects
+		Expect(executor.Calls()).To(ContainElement("db-0/sidecar: thaw"))
+		Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally(">", snapTime))
+	})
+	It("proceeds with the snapshots if requested", func() {
+		executor.failures = map[string]error{"freeze": errors.New("device busy")}
+		schedule.Spec.Hooks.Pre.OnFailure = snapschedulerv1.HookFailureProceed
+		_, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(listSnaps, timeout, interval).Should(HaveLen(1))
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonFailed))
+	})
+	It("fails the hook if no pods are running", func() {
+		schedule.Spec.Hooks.Pre.Exec.PodSelector.MatchLabels = map[string]string{"app": "missing"}
+		_, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Message).To(ContainSubstring("no running pods"))
+		Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+	})
+	It("doesn't wait for commands to finish", func() {
+		executor.block = true
+		schedule.Spec.Hooks.Pre.TimeoutSeconds = ptr.To[int32](1)
+		schedule.Spec.Hooks.Post = nil
+		result, err := reconcileOnce()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(hookPollInterval))
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonRunning))
+		// The command isn't started again while it runs
+		_, err = reconcileOnce()
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(executor.Calls, timeout, interval).Should(Equal([]string{"db-0/db: freeze"}))
+		_, err = run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonTimedOut))
+	})
+	It("fails the hooks if they're disabled", func() {
+		runner = nil
+		_, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		cond := hookCondition(snapschedulerv1.ConditionPreSnapshotHook)
+		Expect(cond.Reason).To(Equal(snapschedulerv1.HookReasonFailed))
+		Expect(cond.Message).To(ContainSubstring("--enable-hooks"))
+		Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+		Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally(">", snapTime))
+	})
+	It("times out commands that don't finish", func() {
+		executor.block = true
+		schedule.Spec.Hooks.Pre.TimeoutSeconds = ptr.To[int32](1)
+		schedule.Spec.Hooks.Post = nil
+		_, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonTimedOut))
+		Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+	})
+	It("keeps the snapshots if the post-snapshot hook fails", func() {
+		executor.failures = map[string]error{"thaw": errors.New("not frozen")}
+		schedule.Spec.Hooks.Pre = nil
+		_, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(listSnaps, timeout, interval).Should(HaveLen(1))
+		markSnapsCut()
+		requeue, err := run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeue).To(BeFalse())
+		Expect(hookCondition(snapschedulerv1.ConditionPostSnapshotHook).Reason).To(
+			Equal(snapschedulerv1.HookReasonFailed))
+		Consistently(listSnaps, "1s", interval).Should(HaveLen(1))
+	})
+
+	Context("using Jobs", func() {
+		getJob := func(name string) (*batchv1.Job, error) {
+			job := &batchv1.Job{}
+			err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: ns.Name}, job)
+			return job, err
+		}
+		finishJob := func(name string, conditionType batchv1.JobConditionType) {
+			var job *batchv1.Job
+			Eventually(func() error {
+				var err error
+				job, err = getJob(name)
+				return err
+			}, timeout, interval).Should(Succeed())
+			now := metav1.Now()
+			job.Status.StartTime = &now
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               conditionType,
+				Status:             corev1.ConditionTrue,
+				LastProbeTime:      now,
+				LastTransitionTime: now,
+				Message:            "job finished",
+			}}
+			if conditionType == batchv1.JobComplete {
+				job.Status.CompletionTime = &now
+			}
+			Expect(k8sClient.Status().Update(context.TODO(), job)).To(Succeed())
+			Eventually(func() []batchv1.JobCondition {
+				job, err := getJob(name)
+				Expect(err).NotTo(HaveOccurred())
+				return job.Status.Conditions
+			}, timeout, interval).ShouldNot(BeEmpty())
+		}
+		BeforeEach(func() {
+			jobHook := func(name string) *snapschedulerv1.SnapshotHook {
+				return &snapschedulerv1.SnapshotHook{
+					Job: &snapschedulerv1.JobHook{Template: batchv1.JobSpec{
+						Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+							Containers:    []corev1.Container{{Name: name, Image: "busybox"}},
+							RestartPolicy: corev1.RestartPolicyNever,
+						}},
+					}},
+				}
+			}
+			schedule.Spec.Hooks.Pre = jobHook("freeze")
+			schedule.Spec.Hooks.Post = jobHook("thaw")
+			// The Jobs are owned by the schedule
+			schedule.UID = "db-uid"
+		})
+		It("waits for Jobs that the cache hasn't caught up with", func() {
+			stale := &staleJobClient{Client: k8sClient}
+			for range 2 {
+				done, err := runJobHook(context.TODO(), schedule, snapTime, typeScheduled, hookPhasePre,
+					schedule.Spec.Hooks.Pre, logger, stale)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(done).To(BeFalse())
+			}
+			Eventually(func() error {
+				_, err := getJob("db-pre-202403010230")
+				return err
+			}, timeout, interval).Should(Succeed())
+		})
+		It("waits for each Job to complete", func() {
+			requeue, err := run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeTrue())
+			Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+				Equal(snapschedulerv1.HookReasonRunning))
+			Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+
+			finishJob("db-pre-202403010230", batchv1.JobComplete)
+			requeue, err = run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeTrue())
+			Eventually(listSnaps, timeout, interval).Should(HaveLen(1))
+			markSnapsCut()
+
+			requeue, err = run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeTrue())
+			Expect(hookCondition(snapschedulerv1.ConditionPostSnapshotHook).Reason).To(
+				Equal(snapschedulerv1.HookReasonRunning))
+			finishJob("db-post-202403010230", batchv1.JobComplete)
+
+			requeue, err = run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeFalse())
+			Expect(hookCondition(snapschedulerv1.ConditionPostSnapshotHook).Status).To(Equal(metav1.ConditionTrue))
+			Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally(">", snapTime))
+		})
+		It("skips the snapshots if the pre-snapshot Job fails", func() {
+			_, err := run()
+			Expect(err).NotTo(HaveOccurred())
+			finishJob("db-pre-202403010230", batchv1.JobFailed)
+			requeue, err := run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeTrue())
+			Expect(hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason).To(
+				Equal(snapschedulerv1.HookReasonFailed))
+
+			// Waiting on the post-snapshot Job doesn't rerun the pre-snapshot Job
+			Eventually(func() error {
+				_, err := getJob("db-post-202403010230")
+				return err
+			}, timeout, interval).Should(Succeed())
+			finishJob("db-post-202403010230", batchv1.JobComplete)
+			requeue, err = run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeue).To(BeFalse())
+			Consistently(listSnaps, "1s", interval).Should(BeEmpty())
+		})
+		It("deletes Jobs that time out", func() {
+			schedule.Spec.Hooks.Pre.TimeoutSeconds = ptr.To[int32](1)
+			_, err := run()
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func() string {
+				_, err := run()
+				Expect(err).NotTo(HaveOccurred())
+				return hookCondition(snapschedulerv1.ConditionPreSnapshotHook).Reason
+			}, timeout, interval).Should(Equal(snapschedulerv1.HookReasonTimedOut))
+			Eventually(func() bool {
+				job, err := getJob("db-pre-202403010230")
+				return err != nil || !job.DeletionTimestamp.IsZero()
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
@@ -18,17 +18,41 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package controller
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"k8s.io/apimachinery/pkg/types"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
 )
 
-func scheduleLabels(scheduleName, scheduleNamespace, pvcName string) prometheus.Labels {
+// scheduleID identifies a schedule in the metrics and the reconcilers'
+// tracking state. A ClusterSnapshotSchedule is identified separately in each
+// of its namespaces, with its kind keeping it apart from a same-named
+// SnapshotSchedule.
+type scheduleID struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// scheduleIDFor returns the ID of the schedule
+func scheduleIDFor(schedule *snapschedulerv1.SnapshotSchedule) scheduleID {
+	return scheduleID{
+		kind:      scheduleKind(schedule),
+		namespace: schedule.Namespace,
+		name:      schedule.Name,
+	}
+}
+
+func scheduleLabels(id scheduleID, pvcName string) prometheus.Labels {
 	return prometheus.Labels{
-		"schedule_name":      scheduleName,
-		"schedule_namespace": scheduleNamespace,
+		"schedule_kind":      id.kind,
+		"schedule_name":      id.name,
+		"schedule_namespace": id.namespace,
 		"pvc_name":           pvcName,
 	}
 }
@@ -43,35 +67,61 @@ var (
 			Name: "snapscheduler_snapshot_current_count",
 			Help: "Current number of VolumeSnapshots managed by a schedule for a given PVC.",
 		},
-		[]string{"schedule_name", "schedule_namespace", "pvc_name"},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
 	)
 	snapshotCurrentReadyCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "snapscheduler_snapshot_current_ready_count",
 			Help: "Current number of readyToUse VolumeSnapshots managed by a schedule for a given PVC.",
 		},
-		[]string{"schedule_name", "schedule_namespace", "pvc_name"},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
+	)
+	snapshotCurrentPinnedCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "snapscheduler_snapshot_current_pinned_count",
+			Help: "Current number of VolumeSnapshots managed by a schedule for a given PVC that are exempt from retention.",
+		},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
 	)
 	snapshotCreateTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "snapscheduler_snapshot_create_total",
 			Help: "Cumulative number of snapshots created by a schedule for a given PVC.",
 		},
-		[]string{"schedule_name", "schedule_namespace", "pvc_name"},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
 	)
 	snapshotReadyTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "snapscheduler_snapshot_ready_total",
 			Help: "Cumulative number of snapshots that became readyToUse.",
 		},
-		[]string{"schedule_name", "schedule_namespace", "pvc_name"},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
 	)
 	snapshotCreateErrorTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "snapscheduler_snapshot_create_error_total",
 			Help: "Cumulative number of snapshot creation errors.",
 		},
-		[]string{"schedule_name", "schedule_namespace", "pvc_name"},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace", "pvc_name"},
+	)
+	snapshotMissedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "snapscheduler_snapshot_missed_total",
+			Help: "Cumulative number of scheduled snapshot times that were missed.",
+		},
+		[]string{"schedule_kind", "schedule_name", "schedule_namespace"},
+	)
+	snapshotCreateThrottledTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "snapscheduler_snapshot_create_throttled_total",
+			Help: "Total number of snapshot creations deferred by the creation rate and concurrency limits.",
+		},
+	)
+	snapshotCreateQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "snapscheduler_snapshot_create_queue_depth",
+			Help: "Number of snapshot creations waiting on the creation rate and concurrency limits.",
+		},
 	)
 )
 
@@ -79,38 +129,49 @@ func init() {
 	metrics.Registry.MustRegister(
 		snapshotCurrentCount,
 		snapshotCurrentReadyCount,
+		snapshotCurrentPinnedCount,
 		snapshotCreateTotal,
 		snapshotReadyTotal,
 		snapshotCreateErrorTotal,
+		snapshotMissedTotal,
+		snapshotCreateThrottledTotal,
+		snapshotCreateQueueDepth,
 	)
 }
 
-// updateSnapshotGauges sets the current snapshot count and ready count gauges
-// for each PVC in the grouped snapshot map. It also removes gauge entries for
-// PVCs that are no longer present (e.g. deleted PVCs).
-func updateSnapshotGauges(scheduleName, scheduleNamespace string,
-	grouped map[string][]snapv1.VolumeSnapshot, prevPVCs map[string]struct{}) {
+// updateSnapshotGauges sets the current snapshot count, ready count, and
+// pinned count gauges for each PVC in the grouped snapshot map. It also
+// removes gauge entries for PVCs that are no longer present (e.g. deleted
+// PVCs).
+func updateSnapshotGauges(id scheduleID, grouped map[string][]snapv1.VolumeSnapshot,
+	prevPVCs map[string]struct{}) {
 	currentPVCs := make(map[string]struct{}, len(grouped))
 	for pvcName, snaps := range grouped {
 		currentPVCs[pvcName] = struct{}{}
-		labels := scheduleLabels(scheduleName, scheduleNamespace, pvcName)
+		labels := scheduleLabels(id, pvcName)
 		snapshotCurrentCount.With(labels).Set(float64(len(snaps)))
 
 		readyCount := 0
+		pinnedCount := 0
 		for i := range snaps {
 			if isSnapshotReady(&snaps[i]) {
 				readyCount++
 			}
+			if isSnapshotPinned(&snaps[i], time.Now()) {
+				pinnedCount++
+			}
 		}
 		snapshotCurrentReadyCount.With(labels).Set(float64(readyCount))
+		snapshotCurrentPinnedCount.With(labels).Set(float64(pinnedCount))
 	}
 
 	// Remove stale gauge entries for PVCs that disappeared
 	for pvc := range prevPVCs {
 		if _, exists := currentPVCs[pvc]; !exists {
-			labels := scheduleLabels(scheduleName, scheduleNamespace, pvc)
+			labels := scheduleLabels(id, pvc)
 			snapshotCurrentCount.Delete(labels)
 			snapshotCurrentReadyCount.Delete(labels)
+			snapshotCurrentPinnedCount.Delete(labels)
 		}
 	}
 
@@ -126,8 +187,8 @@ func updateSnapshotGauges(scheduleName, scheduleNamespace string,
 // updateReadyCounter increments snapshotReadyTotal for snapshots that have
 // become readyToUse and haven't been counted yet. It also removes tracker
 // entries for snapshots that no longer exist.
-func updateReadyCounter(scheduleName, scheduleNamespace string,
-	grouped map[string][]snapv1.VolumeSnapshot, tracker map[types.UID]struct{}) {
+func updateReadyCounter(id scheduleID, grouped map[string][]snapv1.VolumeSnapshot,
+	tracker map[types.UID]struct{}) {
 	liveUIDs := make(map[types.UID]struct{})
 
 	for pvcName, snaps := range grouped {
@@ -136,7 +197,7 @@ func updateReadyCounter(scheduleName, scheduleNamespace string,
 			if isSnapshotReady(&snaps[i]) {
 				if _, tracked := tracker[snaps[i].UID]; !tracked {
 					tracker[snaps[i].UID] = struct{}{}
-					snapshotReadyTotal.With(scheduleLabels(scheduleName, scheduleNamespace, pvcName)).Inc()
+					snapshotReadyTotal.With(scheduleLabels(id, pvcName)).Inc()
 				}
 			}
 		}
@@ -151,11 +212,13 @@ func updateReadyCounter(scheduleName, scheduleNamespace string,
 }
 
 // cleanupScheduleGauges removes all gauge entries for the given schedule.
-func cleanupScheduleGauges(scheduleName, scheduleNamespace string) {
+func cleanupScheduleGauges(id scheduleID) {
 	partialLabels := prometheus.Labels{
-		"schedule_name":      scheduleName,
-		"schedule_namespace": scheduleNamespace,
+		"schedule_kind":      id.kind,
+		"schedule_name":      id.name,
+		"schedule_namespace": id.namespace,
 	}
 	snapshotCurrentCount.DeletePartialMatch(partialLabels)
 	snapshotCurrentReadyCount.DeletePartialMatch(partialLabels)
+	snapshotCurrentPinnedCount.DeletePartialMatch(partialLabels)
 }
@@ -28,12 +28,16 @@ import (
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
 )
 
 var _ = Describe("Snapshot metrics", func() {
+	sched1 := scheduleID{kind: snapschedulerv1.SnapshotScheduleKind, namespace: "ns1", name: "sched1"}
 	AfterEach(func() {
 		snapshotCurrentCount.Reset()
 		snapshotCurrentReadyCount.Reset()
+		snapshotCurrentPinnedCount.Reset()
 		snapshotReadyTotal.Reset()
 	})
 
@@ -63,12 +67,14 @@ var _ = Describe("Snapshot metrics", func() {
 				},
 			}
 
-			updateSnapshotGauges("sched1", "ns1", grouped, make(map[string]struct{}))
+			updateSnapshotGauges(sched1, grouped, make(map[string]struct{}))
 
 			labels1 := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			labels2 := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc2",
 			}
 			Expect(testutil.ToFloat64(snapshotCurrentCount.With(labels1))).To(Equal(float64(2)))
@@ -77,8 +83,25 @@ var _ = Describe("Snapshot metrics", func() {
 			Expect(testutil.ToFloat64(snapshotCurrentReadyCount.With(labels2))).To(Equal(float64(1)))
 		})
 
+		It("counts pinned snapshots", func() {
+			grouped := map[string][]snapv1.VolumeSnapshot{
+				"pvc1": {
+					{ObjectMeta: metav1.ObjectMeta{Name: "snap1", Annotations: map[string]string{RetainKey: "true"}}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "snap2"}},
+				},
+			}
+			updateSnapshotGauges(sched1, grouped, make(map[string]struct{}))
+
+			labels := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
+				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
+			}
+			Expect(testutil.ToFloat64(snapshotCurrentCount.With(labels))).To(Equal(float64(2)))
+			Expect(testutil.ToFloat64(snapshotCurrentPinnedCount.With(labels))).To(Equal(float64(1)))
+		})
+
 		It("handles empty grouped map", func() {
-			updateSnapshotGauges("sched1", "ns1", map[string][]snapv1.VolumeSnapshot{}, make(map[string]struct{}))
+			updateSnapshotGauges(sched1, map[string][]snapv1.VolumeSnapshot{}, make(map[string]struct{}))
 			// No panic, no metrics created
 		})
 
@@ -88,9 +111,10 @@ var _ = Describe("Snapshot metrics", func() {
 					{ObjectMeta: metav1.ObjectMeta{Name: "snap1"}, Status: nil},
 				},
 			}
-			updateSnapshotGauges("sched1", "ns1", grouped, make(map[string]struct{}))
+			updateSnapshotGauges(sched1, grouped, make(map[string]struct{}))
 
 			labels := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			Expect(testutil.ToFloat64(snapshotCurrentCount.With(labels))).To(Equal(float64(1)))
@@ -113,9 +137,10 @@ var _ = Describe("Snapshot metrics", func() {
 				},
 			}
 
-			updateReadyCounter("sched1", "ns1", grouped, tracker)
+			updateReadyCounter(sched1, grouped, tracker)
 
 			labels := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			Expect(testutil.ToFloat64(snapshotReadyTotal.With(labels))).To(Equal(float64(1)))
@@ -138,9 +163,10 @@ var _ = Describe("Snapshot metrics", func() {
 				},
 			}
 
-			updateReadyCounter("sched1", "ns1", grouped, tracker)
+			updateReadyCounter(sched1, grouped, tracker)
 
 			labels := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			// Counter should be 0 since uid-1 was already tracked
@@ -153,7 +179,7 @@ var _ = Describe("Snapshot metrics", func() {
 			}
 			grouped := map[string][]snapv1.VolumeSnapshot{}
 
-			updateReadyCounter("sched1", "ns1", grouped, tracker)
+			updateReadyCounter(sched1, grouped, tracker)
 
 			Expect(tracker).NotTo(HaveKey(types.UID("uid-deleted")))
 		})
@@ -172,7 +198,7 @@ var _ = Describe("Snapshot metrics", func() {
 				},
 			}
 
-			updateReadyCounter("sched1", "ns1", grouped, tracker)
+			updateReadyCounter(sched1, grouped, tracker)
 
 			Expect(tracker).NotTo(HaveKey(types.UID("uid-1")))
 		})
@@ -181,12 +207,13 @@ var _ = Describe("Snapshot metrics", func() {
 	Describe("cleanupScheduleGauges", func() {
 		It("removes gauge entries for a schedule", func() {
 			labels := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			snapshotCurrentCount.With(labels).Set(5)
 			snapshotCurrentReadyCount.With(labels).Set(3)
 
-			cleanupScheduleGauges("sched1", "ns1")
+			cleanupScheduleGauges(sched1)
 
 			// After cleanup, getting the metric should return 0 (fresh counter)
 			Expect(testutil.ToFloat64(snapshotCurrentCount.With(labels))).To(Equal(float64(0)))
@@ -195,18 +222,35 @@ var _ = Describe("Snapshot metrics", func() {
 
 		It("does not affect other schedules", func() {
 			labels1 := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched1", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			labels2 := prometheus.Labels{
+				"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
 				"schedule_name": "sched2", "schedule_namespace": "ns1", "pvc_name": "pvc1",
 			}
 			snapshotCurrentCount.With(labels1).Set(5)
 			snapshotCurrentCount.With(labels2).Set(10)
 
-			cleanupScheduleGauges("sched1", "ns1")
+			cleanupScheduleGauges(sched1)
 
 			Expect(testutil.ToFloat64(snapshotCurrentCount.With(labels2))).To(Equal(float64(10)))
 		})
+
+		It("does not affect a same-named cluster schedule", func() {
+			clusterSched1 := scheduleID{kind: snapschedulerv1.ClusterSnapshotScheduleKind, namespace: "ns1",
+				name: "sched1"}
+			grouped := map[string][]snapv1.VolumeSnapshot{
+				"pvc1": {{ObjectMeta: metav1.ObjectMeta{Name: "snap1"}}},
+			}
+			updateSnapshotGauges(sched1, grouped, make(map[string]struct{}))
+			updateSnapshotGauges(clusterSched1, grouped, make(map[string]struct{}))
+
+			cleanupScheduleGauges(sched1)
+
+			Expect(testutil.ToFloat64(snapshotCurrentCount.With(scheduleLabels(clusterSched1, "pvc1")))).To(
+				Equal(float64(1)))
+		})
 	})
 
 })
@@ -0,0 +1,171 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// Upper bound on the number of missed snapshot times that are counted
+const maxMissedRunCount = 1000
+
+// catchUpMissedRuns applies the schedule's starting deadline and missed run
+// policy once the next snapshot time has passed. It returns true if the
+// snapshots should be taken, in which case the next snapshot time may have
+// been moved up to the most recent of the missed times. Otherwise, the missed
+// times have been recorded and the schedule has moved on to the next
+// scheduled time.
+func catchUpMissedRuns(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, now time.Time,
+	logger logr.Logger, c client.Client, recorder events.EventRecorder, hooks *hookRunner) (bool, error) {
+	late := schedule.Status.NextSnapshotTime.Time
+	// A run that is already underway is allowed to finish
+	started, err := runStarted(ctx, schedule, late, c, hooks)
+	if err != nil || started {
+		return started, err
+	}
+
+	times := scheduledTimesSince(schedule, late, now)
+	latest := times[len(times)-1]
+	policy := missedRunPolicy(schedule)
+	missed := times
+	var action string
+	switch {
+	case pastStartingDeadline(schedule, latest, now):
+		action = "the starting deadline has passed"
+	case len(times) == 1:
+		return true, nil
+	case policy == snapschedulerv1.MissedRunTakeOne:
+		missed = times[:len(times)-1]
+		action = "taking snapshots for " + latest.UTC().Format(time.RFC3339)
+	default:
+		action = fmt.Sprintf("no snapshots taken (missedRunPolicy is %s)", policy)
+	}
+
+	recordMissedRuns(schedule, missed, policy)
+	logger.Info("scheduled snapshot times were missed", "missed", len(missed),
+		"scheduled", late.Format(time.RFC3339), "policy", policy)
+	recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonMissedSchedule, eventActionSchedule,
+		"Missed %d scheduled snapshot time(s) starting at %s; %s", len(missed), late.UTC().Format(time.RFC3339),
+		action)
+
+	if len(missed) < len(times) {
+		next := metav1.NewTime(latest)
+		schedule.Status.NextSnapshotTime = &next
+		return true, nil
+	}
+	if err := updateNextSnapTime(schedule, now); err != nil {
+		logger.Error(err, "couldn't update next snap time",
+			"cronspec", schedule.Spec.Schedule)
+		return false, err
+	}
+	// Changing .status will automatically cause requeuing
+	return false, nil
+}
+
+// runStarted reports whether the run at the scheduled time has already begun,
+// either by attempting the snapshots or by starting the pre-snapshot hook
+func runStarted(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	c client.Client, hooks *hookRunner) (bool, error) {
+	if runRecorded(schedule, snapTime) {
+		return true, nil
+	}
+	if schedule.Spec.Hooks == nil {
+		return false, nil
+	}
+	return hookStarted(ctx, schedule, snapTime, typeScheduled, hookPhasePre, schedule.Spec.Hooks.Pre, c, hooks)
+}
+
+// runRecorded reports whether the run at the scheduled time has already been
+// recorded in the schedule's history
+func runRecorded(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time) bool {
+	return findRun(schedule, snapTime, typeScheduled) != nil
+}
+
+// scheduledTimesSince returns the late snapshot time followed by the times at
+// which the schedule should have fired since, up to and including now. At most
+// maxMissedRunCount times are returned.
+func scheduledTimesSince(schedule *snapschedulerv1.SnapshotSchedule, late time.Time, now time.Time) []time.Time {
+	times := []time.Time{late}
+	loc, err := scheduleLocation(&schedule.Spec)
+	if err != nil {
+		return times
+	}
+	jitter := scheduleJitter(schedule)
+	t := late
+	for len(times) < maxMissedRunCount {
+		t, err = getNextSnapTime(schedule.Spec.Schedule, loc, jitter, t)
+		if err != nil || t.After(now) {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// pastStartingDeadline reports whether it is too late to take the snapshots
+// for the scheduled time
+func pastStartingDeadline(schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time, now time.Time) bool {
+	deadline := schedule.Spec.StartingDeadlineSeconds
+	return deadline != nil && now.Sub(snapTime) > time.Duration(*deadline)*time.Second
+}
+
+func missedRunPolicy(schedule *snapschedulerv1.SnapshotSchedule) snapschedulerv1.MissedRunPolicy {
+	if schedule.Spec.MissedRunPolicy == "" {
+		return snapschedulerv1.MissedRunTakeOne
+	}
+	return schedule.Spec.MissedRunPolicy
+}
+
+// recordMissedRuns counts the missed times in the schedule's status and
+// metrics. If the policy is RecordOnly, each of them is also added to the run
+// history.
+func recordMissedRuns(schedule *snapschedulerv1.SnapshotSchedule, missed []time.Time,
+	policy snapschedulerv1.MissedRunPolicy) {
+	if len(missed) == 0 {
+		return
+	}
+	schedule.Status.MissedRuns += int64(len(missed))
+	lastMissed := metav1.NewTime(missed[len(missed)-1])
+	schedule.Status.LastMissedTime = &lastMissed
+	snapshotMissedTotal.With(prometheus.Labels{
+		"schedule_kind":      scheduleKind(schedule),
+		"schedule_name":      schedule.Name,
+		"schedule_namespace": schedule.Namespace,
+	}).Add(float64(len(missed)))
+
+	if policy != snapschedulerv1.MissedRunRecordOnly {
+		return
+	}
+	if len(missed) > maxRunHistory {
+		missed = missed[len(missed)-maxRunHistory:]
+	}
+	for _, t := range missed {
+		recordRun(schedule, t.UTC(), typeScheduled, nil, fmt.Errorf("%w: scheduled time was missed", errSnapshotsSkipped))
+	}
+}
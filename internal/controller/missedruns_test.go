@@ -0,0 +1,141 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Catching up on missed runs", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var capture *capturingRecorder
+	var late time.Time
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		late, _ = time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		next := metav1.NewTime(late)
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+			Status: snapschedulerv1.SnapshotScheduleStatus{
+				NextSnapshotTime: &next,
+			},
+		}
+		capture = &capturingRecorder{}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	catchUp := func(now string) bool {
+		when, _ := time.Parse(timeFormat, now)
+		take, err := catchUpMissedRuns(context.TODO(), schedule, when, logger, k8sClient, capture, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return take
+	}
+	nextTime := func() string {
+		return schedule.Status.NextSnapshotTime.UTC().Format(timeFormat)
+	}
+
+	It("takes snapshots that are on time", func() {
+		Expect(catchUp("2024-03-01T02:00:30Z")).To(BeTrue())
+		Expect(nextTime()).To(Equal("2024-03-01T02:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(BeZero())
+		Expect(capture.events).To(BeEmpty())
+	})
+	It("takes one snapshot for the most recent missed time by default", func() {
+		Expect(catchUp("2024-03-01T05:10:00Z")).To(BeTrue())
+		Expect(nextTime()).To(Equal("2024-03-01T05:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(Equal(int64(3)))
+		Expect(schedule.Status.LastMissedTime.UTC().Format(timeFormat)).To(Equal("2024-03-01T04:00:00Z"))
+		Expect(schedule.Status.RecentRuns).To(BeEmpty())
+		Expect(capture.regarding(eventReasonMissedSchedule)).To(ConsistOf("SnapshotSchedule/hourly"))
+		Expect(capture.events[0].note).To(HavePrefix("Missed 3 scheduled snapshot time(s)"))
+	})
+	It("skips all of the missed times", func() {
+		schedule.Spec.MissedRunPolicy = snapschedulerv1.MissedRunSkip
+		Expect(catchUp("2024-03-01T05:10:00Z")).To(BeFalse())
+		Expect(nextTime()).To(Equal("2024-03-01T06:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(Equal(int64(4)))
+		Expect(schedule.Status.LastMissedTime.UTC().Format(timeFormat)).To(Equal("2024-03-01T05:00:00Z"))
+		Expect(schedule.Status.RecentRuns).To(BeEmpty())
+	})
+	It("records each missed time in the run history", func() {
+		schedule.Spec.MissedRunPolicy = snapschedulerv1.MissedRunRecordOnly
+		Expect(catchUp("2024-03-01T05:10:00Z")).To(BeFalse())
+		Expect(schedule.Status.MissedRuns).To(Equal(int64(4)))
+		Expect(schedule.Status.RecentRuns).To(HaveLen(4))
+		Expect(schedule.Status.RecentRuns[0].ScheduledTime.UTC().Format(timeFormat)).To(
+			Equal("2024-03-01T05:00:00Z"))
+		Expect(schedule.Status.RecentRuns[3].ScheduledTime.UTC().Format(timeFormat)).To(
+			Equal("2024-03-01T02:00:00Z"))
+		Expect(schedule.Status.RecentRuns[0].Error).To(ContainSubstring("missed"))
+		cond := apimeta.FindStatusCondition(schedule.Status.Conditions, snapschedulerv1.ConditionLastRunSucceeded)
+		Expect(cond.Reason).To(Equal(snapschedulerv1.LastRunReasonSkipped))
+	})
+	It("doesn't start runs after the starting deadline", func() {
+		schedule.Spec.StartingDeadlineSeconds = ptr.To[int64](300)
+		Expect(catchUp("2024-03-01T02:10:00Z")).To(BeFalse())
+		Expect(nextTime()).To(Equal("2024-03-01T03:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(Equal(int64(1)))
+		Expect(capture.events[0].note).To(ContainSubstring("starting deadline"))
+	})
+	It("takes the most recent missed time if it is within the deadline", func() {
+		schedule.Spec.StartingDeadlineSeconds = ptr.To[int64](900)
+		Expect(catchUp("2024-03-01T04:10:00Z")).To(BeTrue())
+		Expect(nextTime()).To(Equal("2024-03-01T04:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(Equal(int64(2)))
+	})
+	It("lets a run that has started finish", func() {
+		schedule.Spec.StartingDeadlineSeconds = ptr.To[int64](300)
+		recordRun(schedule, late, typeScheduled, nil, nil)
+		Expect(catchUp("2024-03-01T05:10:00Z")).To(BeTrue())
+		Expect(nextTime()).To(Equal("2024-03-01T02:00:00Z"))
+		Expect(schedule.Status.MissedRuns).To(BeZero())
+	})
+	It("counts missed times in the metrics", func() {
+		labels := prometheus.Labels{"schedule_kind": snapschedulerv1.SnapshotScheduleKind,
+			"schedule_name": schedule.Name, "schedule_namespace": schedule.Namespace}
+		schedule.Spec.MissedRunPolicy = snapschedulerv1.MissedRunSkip
+		catchUp("2024-03-01T05:10:00Z")
+		Expect(testutil.ToFloat64(snapshotMissedTotal.With(labels))).To(Equal(float64(4)))
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// orphanedClaims returns the PVCs that have snapshots from the schedule but no
+// longer exist or are no longer selected by it (including those excluded via
+// the ExcludeKey annotation), recording the number of them and of their
+// snapshots in the schedule's status. Pinned and final snapshots aren't
+// subject to the orphan policy, so they aren't counted.
+func orphanedClaims(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	grouped map[string][]snapv1.VolumeSnapshot, logger logr.Logger, c client.Client) (map[string]struct{}, error) {
+	pvcList, err := listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace,
+		&schedule.Spec.ClaimSelector, schedule.Spec.StorageClassSelector)
+	if err != nil {
+		logger.Error(err, "unable to get matching PVCs")
+		return nil, err
+	}
+	selected := make(map[string]struct{}, len(pvcList.Items))
+	for _, pvc := range pvcList.Items {
+		if !isClaimExcluded(&pvc) {
+			selected[pvc.Name] = struct{}{}
+		}
+	}
+
+	now := time.Now()
+	orphaned := make(map[string]struct{})
+	snapCount := 0
+	for pvcName, snaps := range grouped {
+		if _, found := selected[pvcName]; found {
+			continue
+		}
+		regular, _ := partitionFinalSnaps(filterUnpinnedSnaps(snaps, now))
+		if len(regular) > 0 {
+			orphaned[pvcName] = struct{}{}
+			snapCount += len(regular)
+		}
+	}
+	schedule.Status.OrphanedClaims = int32(len(orphaned))
+	schedule.Status.OrphanedSnapshots = int32(snapCount)
+	return orphaned, nil
+}
+
+// splitOrphanedSnaps moves the orphaned groups out of the grouped snapshots,
+// returning the snapshots from the list that aren't orphaned along with the
+// orphaned groups
+func splitOrphanedSnaps[S any, P snapshotObject[S]](snapList []S, grouped map[string][]S,
+	orphaned map[string]struct{}) ([]S, map[string][]S) {
+	orphanGroups := make(map[string][]S, len(orphaned))
+	orphanNames := make(map[string]struct{})
+	for key := range orphaned {
+		list, found := grouped[key]
+		if !found {
+			continue
+		}
+		orphanGroups[key] = list
+		delete(grouped, key)
+		for i := range list {
+			orphanNames[P(&list[i]).GetName()] = struct{}{}
+		}
+	}
+	remaining := make([]S, 0, len(snapList))
+	for i := range snapList {
+		if _, found := orphanNames[P(&snapList[i]).GetName()]; !found {
+			remaining = append(remaining, snapList[i])
+		}
+	}
+	return remaining, orphanGroups
+}
+
+// expireOrphans applies the schedule's orphan policy to the orphaned groups
+func expireOrphans[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	now time.Time, logger logr.Logger, c client.Client, recorder events.EventRecorder,
+	orphanGroups map[string][]S) error {
+	spec := schedule.Spec.Retention.Orphans
+	switch spec.Policy {
+	case snapschedulerv1.OrphanPolicyExpire:
+		lifetime, err := parseRetentionDuration(spec.Expires)
+		if err != nil {
+			logger.Error(err, "invalid value for spec.retention.orphans.expires")
+			return err
+		}
+		for _, list := range orphanGroups {
+			expired := filterExpiredSnaps[S, P](list, now.Add(-lifetime))
+			if err := deleteSnapshots[S, P](ctx, schedule, expired, logger, c, recorder); err != nil {
+				return err
+			}
+		}
+	case snapschedulerv1.OrphanPolicyKeepNewest:
+		if spec.MaxCount == nil {
+			return nil
+		}
+		maxCounts := make(map[string]int32, len(orphanGroups))
+		for key := range orphanGroups {
+			maxCounts[key] = *spec.MaxCount
+		}
+		return expireByCount[S, P](ctx, schedule, logger, c, recorder, orphanGroups, maxCounts)
+	}
+	// Orphans are otherwise retained
+	return nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Handling orphaned snapshots", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: ns.Name,
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+				Retention: snapschedulerv1.SnapshotRetentionSpec{
+					MaxCount: ptr.To[int32](1),
+				},
+			},
+		}
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data",
+				Namespace: ns.Name,
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		// The snapshots of "gone" are orphaned since the PVC doesn't exist
+		for i := range 2 {
+			snapTime := time.Date(2024, 3, 1, i, 0, 0, 0, time.UTC)
+			for _, pvcName := range []string{"data", "gone"} {
+				snap := newSnapForClaim(snapshotName(pvcName, schedule.Name, snapTime),
+					corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: ns.Name}},
+					schedule, snapTime, nil, nil, false)
+				Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+				snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+			}
+			time.Sleep(time.Second)
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	snapNames := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+	updateSnap := func(name string, update func(*snapv1.VolumeSnapshot)) {
+		snap := &snapv1.VolumeSnapshot{}
+		Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: ns.Name}, snap)).To(Succeed())
+		update(snap)
+		Expect(k8sClient.Update(context.TODO(), snap)).To(Succeed())
+	}
+	pin := func(name string) {
+		updateSnap(name, func(snap *snapv1.VolumeSnapshot) {
+			snap.Annotations = map[string]string{RetainKey: "true"}
+		})
+	}
+	expire := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		grouped := groupSnapsByPVC(snapList)
+		orphaned, err := orphanedClaims(context.TODO(), schedule, grouped, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expireSnapshots(context.TODO(), schedule, logger, k8sClient, &capturingRecorder{}, snapList,
+			grouped, nil, orphaned)).To(Succeed())
+		return snapNames()
+	}
+
+	It("counts the orphaned PVCs and snapshots", func() {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		orphaned, err := orphanedClaims(context.TODO(), schedule, groupSnapsByPVC(snapList), logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(HaveKey("gone"))
+		Expect(orphaned).To(HaveLen(1))
+		Expect(schedule.Status.OrphanedClaims).To(Equal(int32(1)))
+		Expect(schedule.Status.OrphanedSnapshots).To(Equal(int32(2)))
+
+		// PVCs that are no longer selected are orphaned too
+		schedule.Spec.ClaimSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+		orphaned, err = orphanedClaims(context.TODO(), schedule, groupSnapsByPVC(snapList), logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(HaveLen(2))
+		Expect(schedule.Status.OrphanedSnapshots).To(Equal(int32(4)))
+	})
+	It("counts excluded PVCs as orphaned", func() {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Name: "data", Namespace: ns.Name}, pvc)).To(Succeed())
+		pvc.Annotations = map[string]string{ExcludeKey: "true"}
+		Expect(k8sClient.Update(context.TODO(), pvc)).To(Succeed())
+
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		orphaned, err := orphanedClaims(context.TODO(), schedule, groupSnapsByPVC(snapList), logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(HaveKey("data"))
+		Expect(orphaned).To(HaveLen(2))
+	})
+	It("doesn't count pinned or final snapshots", func() {
+		pin("gone-hourly-202403010100")
+		updateSnap("gone-hourly-202403010000", func(snap *snapv1.VolumeSnapshot) {
+			snap.Labels[TypeKey] = TypeFinal
+		})
+
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		orphaned, err := orphanedClaims(context.TODO(), schedule, groupSnapsByPVC(snapList), logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphaned).To(BeEmpty())
+		Expect(schedule.Status.OrphanedClaims).To(BeZero())
+		Expect(schedule.Status.OrphanedSnapshots).To(BeZero())
+	})
+	It("applies the usual retention to orphans without a policy", func() {
+		Expect(expire()).To(ConsistOf("data-hourly-202403010100", "gone-hourly-202403010100"))
+	})
+	It("retains orphans with the Retain policy", func() {
+		schedule.Spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{
+			Policy: snapschedulerv1.OrphanPolicyRetain,
+		}
+		Expect(expire()).To(ConsistOf("data-hourly-202403010100",
+			"gone-hourly-202403010000", "gone-hourly-202403010100"))
+	})
+	It("keeps the newest orphans with the KeepNewest policy", func() {
+		schedule.Spec.Retention.MaxCount = nil
+		schedule.Spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{
+			Policy:   snapschedulerv1.OrphanPolicyKeepNewest,
+			MaxCount: ptr.To[int32](1),
+		}
+		Expect(expire()).To(ConsistOf("data-hourly-202403010000", "data-hourly-202403010100",
+			"gone-hourly-202403010100"))
+	})
+	It("doesn't count pinned orphans against the KeepNewest maxCount", func() {
+		schedule.Spec.Retention.MaxCount = nil
+		schedule.Spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{
+			Policy:   snapschedulerv1.OrphanPolicyKeepNewest,
+			MaxCount: ptr.To[int32](1),
+		}
+		pin("gone-hourly-202403010100")
+		Expect(expire()).To(ConsistOf("data-hourly-202403010000", "data-hourly-202403010100",
+			"gone-hourly-202403010000", "gone-hourly-202403010100"))
+		Expect(schedule.Status.OrphanedSnapshots).To(Equal(int32(1)))
+	})
+	It("expires orphans with the Expire policy", func() {
+		schedule.Spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{
+			Policy:  snapschedulerv1.OrphanPolicyExpire,
+			Expires: "1h",
+		}
+		Expect(expire()).To(ConsistOf("data-hourly-202403010100",
+			"gone-hourly-202403010000", "gone-hourly-202403010100"))
+
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		orphanGroups := map[string][]snapv1.VolumeSnapshot{"gone": groupSnapsByPVC(snapList)["gone"]}
+		Expect(expireOrphans(context.TODO(), schedule, time.Now().Add(2*time.Hour), logger, k8sClient,
+			&capturingRecorder{}, orphanGroups)).To(Succeed())
+		Expect(snapNames()).To(ConsistOf("data-hourly-202403010100"))
+	})
+})
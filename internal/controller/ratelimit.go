@@ -0,0 +1,245 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"golang.org/x/time/rate"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+const (
+	// How long to wait for a concurrent creation request to complete before
+	// trying again
+	creationSlotRetryInterval = time.Second
+	// How long to wait for snapshots that are still being cut before trying
+	// again. Snapshots usually take a while to become ready.
+	creationPendingRetryInterval = 10 * time.Second
+	// How long past its expected retry a deferred creation is still counted
+	// as waiting. Creations that aren't retried (e.g., since the schedule was
+	// deleted) stop being counted.
+	deferredCreationGrace = time.Minute
+)
+
+// SnapshotCreationLimiter bounds the rate at which, and the number of
+// concurrent requests with which, snapshots are created across all schedules.
+// Snapshots that have been created but aren't ready to use yet count as
+// concurrent creations too. Creations that exceed the limits are rejected
+// rather than waited for, so that they don't hold up the reconcile; see
+// creationThrottled. A nil limiter imposes no limits.
+type SnapshotCreationLimiter struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+	mu      sync.Mutex
+	// The creations that have been deferred by the limits, and when each is
+	// expected to have been retried by
+	deferred map[client.ObjectKey]time.Time
+}
+
+// NewSnapshotCreationLimiter returns a limiter that allows qps snapshot
+// creations per second, with bursts of up to burst, and at most maxConcurrent
+// creation requests at a time. A qps or maxConcurrent of 0 removes the
+// respective limit.
+func NewSnapshotCreationLimiter(qps float64, burst int, maxConcurrent int) *SnapshotCreationLimiter {
+	l := &SnapshotCreationLimiter{deferred: make(map[client.ObjectKey]time.Time)}
+	if qps > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(qps), max(burst, 1))
+	}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// Client returns a client whose snapshot and group snapshot creations are
+// subject to the limiter. Other requests are passed through unchanged.
+func (l *SnapshotCreationLimiter) Client(c client.Client) client.Client {
+	if l == nil {
+		return c
+	}
+	return &limitedClient{Client: c, limiter: l}
+}
+
+// tryAcquire reserves the creation of a snapshot without waiting, given the
+// number of snapshots that are still being cut. The returned function must be
+// called once the creation request has completed. If the limits don't allow a
+// creation yet, it instead returns how long to wait before trying again.
+func (l *SnapshotCreationLimiter) tryAcquire(pending int) (func(), time.Duration) {
+	if l.slots != nil {
+		if pending >= cap(l.slots) {
+			return nil, creationPendingRetryInterval
+		}
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			return nil, creationSlotRetryInterval
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+	if l.limiter != nil {
+		reservation := l.limiter.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			release()
+			return nil, delay
+		}
+	}
+	return release, 0
+}
+
+// countsPending reports whether snapshots that are still being cut count
+// toward the limits
+func (l *SnapshotCreationLimiter) countsPending() bool {
+	return l.slots != nil
+}
+
+// deferCreation records that the creation of the object was deferred for
+// wait, and resumeCreation that it went ahead. The number of deferred
+// creations is exported as a metric.
+func (l *SnapshotCreationLimiter) deferCreation(key client.ObjectKey, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deferred[key] = time.Now().Add(wait + deferredCreationGrace)
+	l.updateDeferredGauge()
+}
+
+func (l *SnapshotCreationLimiter) resumeCreation(key client.ObjectKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.deferred, key)
+	l.updateDeferredGauge()
+}
+
+func (l *SnapshotCreationLimiter) updateDeferredGauge() {
+	now := time.Now()
+	for key, until := range l.deferred {
+		if now.After(until) {
+			delete(l.deferred, key)
+		}
+	}
+	snapshotCreateQueueDepth.Set(float64(len(l.deferred)))
+}
+
+// pendingCreations counts the snapshots and group snapshots taken by
+// snapscheduler that are neither ready to use nor failed
+func pendingCreations(ctx context.Context, c client.Client) (int, error) {
+	pending := 0
+	snapList := &snapv1.VolumeSnapshotList{}
+	if err := c.List(ctx, snapList, client.HasLabels{WhenKey}); err != nil {
+		return 0, err
+	}
+	for i := range snapList.Items {
+		status := snapList.Items[i].Status
+		if status == nil || (status.Error == nil && !ptr.Deref(status.ReadyToUse, false)) {
+			pending++
+		}
+	}
+	groupSnapList := &groupsnapv1beta2.VolumeGroupSnapshotList{}
+	if err := c.List(ctx, groupSnapList, client.HasLabels{WhenKey}); err != nil {
+		if apimeta.IsNoMatchError(err) {
+			return pending, nil
+		}
+		return 0, err
+	}
+	for i := range groupSnapList.Items {
+		status := groupSnapList.Items[i].Status
+		if status == nil || (status.Error == nil && !ptr.Deref(status.ReadyToUse, false)) {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// creationThrottledError is returned in place of creating a snapshot that the
+// limits don't allow yet
+type creationThrottledError struct {
+	wait time.Duration
+}
+
+func (e *creationThrottledError) Error() string {
+	return fmt.Sprintf("snapshot creation throttled; retrying in %s", e.wait)
+}
+
+// creationThrottled reports whether err is due to the creation limits and, if
+// so, how long to wait before trying again
+func creationThrottled(err error) (time.Duration, bool) {
+	var throttled *creationThrottledError
+	if errors.As(err, &throttled) {
+		return throttled.wait, true
+	}
+	return 0, false
+}
+
+// limitedClient applies a SnapshotCreationLimiter to the creation of
+// snapshots and group snapshots
+type limitedClient struct {
+	client.Client
+	limiter *SnapshotCreationLimiter
+}
+
+func (c *limitedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch obj.(type) {
+	case *snapv1.VolumeSnapshot, *groupsnapv1beta2.VolumeGroupSnapshot:
+		pending := 0
+		if c.limiter.countsPending() {
+			var err error
+			if pending, err = pendingCreations(ctx, c.Client); err != nil {
+				return err
+			}
+		}
+		key := client.ObjectKeyFromObject(obj)
+		release, wait := c.limiter.tryAcquire(pending)
+		if release == nil {
+			snapshotCreateThrottledTotal.Inc()
+			c.limiter.deferCreation(key, wait)
+			return &creationThrottledError{wait: wait}
+		}
+		c.limiter.resumeCreation(key)
+		defer release()
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// scheduleJitter returns the fixed delay applied to each of the schedule's
+// snapshot times. It is derived from the schedule's namespace and name so
+// that it doesn't change between reconciles, but differs between schedules.
+func scheduleJitter(schedule *snapschedulerv1.SnapshotSchedule) time.Duration {
+	window := schedule.Spec.JitterSeconds
+	if window == nil || *window <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(schedule.Namespace + "/" + schedule.Name))
+	return time.Duration(h.Sum64()%uint64(*window+1)) * time.Second
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Limiting snapshot creation", func() {
+	It("doesn't wrap the client without a limiter", func() {
+		var limiter *SnapshotCreationLimiter
+		Expect(limiter.Client(k8sClient)).NotTo(BeAssignableToTypeOf(&limitedClient{}))
+	})
+	It("limits the number of concurrent creations", func() {
+		limiter := NewSnapshotCreationLimiter(0, 0, 1)
+		release, _ := limiter.tryAcquire(0)
+		Expect(release).NotTo(BeNil())
+
+		blocked, wait := limiter.tryAcquire(0)
+		Expect(blocked).To(BeNil())
+		Expect(wait).To(Equal(creationSlotRetryInterval))
+
+		release()
+		release, _ = limiter.tryAcquire(0)
+		Expect(release).NotTo(BeNil())
+		release()
+	})
+	It("counts the snapshots still being cut as concurrent creations", func() {
+		limiter := NewSnapshotCreationLimiter(0, 0, 2)
+		blocked, wait := limiter.tryAcquire(2)
+		Expect(blocked).To(BeNil())
+		Expect(wait).To(Equal(creationPendingRetryInterval))
+		release, _ := limiter.tryAcquire(1)
+		Expect(release).NotTo(BeNil())
+		release()
+	})
+	It("limits the rate of creations", func() {
+		limiter := NewSnapshotCreationLimiter(0.1, 1, 0)
+		release, _ := limiter.tryAcquire(0)
+		Expect(release).NotTo(BeNil())
+		release()
+
+		blocked, wait := limiter.tryAcquire(0)
+		Expect(blocked).To(BeNil())
+		Expect(wait).To(BeNumerically("~", 10*time.Second, time.Second))
+		// A rejected creation doesn't use up the next allowed one
+		blocked, wait2 := limiter.tryAcquire(0)
+		Expect(blocked).To(BeNil())
+		Expect(wait2).To(BeNumerically("<=", wait))
+	})
+	It("applies the limits to snapshot creation", func() {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+		}()
+
+		c := NewSnapshotCreationLimiter(0.1, 1, 0).Client(k8sClient)
+		newSnap := func(name string) *snapv1.VolumeSnapshot {
+			return &snapv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns.Name},
+				Spec: snapv1.VolumeSnapshotSpec{
+					Source: snapv1.VolumeSnapshotSource{PersistentVolumeClaimName: ptr.To("data")},
+				},
+			}
+		}
+		Expect(c.Create(context.TODO(), newSnap("first"))).To(Succeed())
+		throttledBefore := testutil.ToFloat64(snapshotCreateThrottledTotal)
+		err := c.Create(context.TODO(), newSnap("second"))
+		wait, throttled := creationThrottled(err)
+		Expect(throttled).To(BeTrue())
+		Expect(wait).To(BeNumerically(">", 0))
+		Expect(testutil.ToFloat64(snapshotCreateThrottledTotal)).To(Equal(throttledBefore + 1))
+		Expect(testutil.ToFloat64(snapshotCreateQueueDepth)).To(Equal(1.0))
+		// Other objects aren't limited
+		Expect(c.Create(context.TODO(), &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: ns.Name},
+		})).To(Succeed())
+	})
+	It("finds the snapshots that are still being cut", func() {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+		}()
+
+		pending := func() int {
+			n, err := pendingCreations(context.TODO(), k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			return n
+		}
+		before := pending()
+		snap := &snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data-hourly-202403010230",
+				Namespace: ns.Name,
+				Labels:    map[string]string{WhenKey: "202403010230"},
+			},
+			Spec: snapv1.VolumeSnapshotSpec{
+				Source: snapv1.VolumeSnapshotSource{PersistentVolumeClaimName: ptr.To("data")},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), snap)).To(Succeed())
+		// Snapshots not taken by snapscheduler aren't counted
+		Expect(k8sClient.Create(context.TODO(), &snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: ns.Name},
+			Spec:       snap.Spec,
+		})).To(Succeed())
+		Eventually(pending, timeout, interval).Should(Equal(before + 1))
+
+		snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+		Expect(k8sClient.Status().Update(context.TODO(), snap)).To(Succeed())
+		Eventually(pending, timeout, interval).Should(Equal(before))
+	})
+	It("requeues a run rather than waiting on the limits", func() {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+		}()
+		for _, pvcName := range []string{"data", "logs"} {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: ns.Name},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+			bindPVC(pvc)
+		}
+		snapTime := time.Now().Add(-time.Minute).Truncate(time.Minute).UTC()
+		next := metav1.NewTime(snapTime)
+		schedule := &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: ns.Name},
+			Spec:       snapschedulerv1.SnapshotScheduleSpec{Schedule: "0 * * * *"},
+			Status:     snapschedulerv1.SnapshotScheduleStatus{NextSnapshotTime: &next},
+		}
+		capture := &capturingRecorder{}
+		c := NewSnapshotCreationLimiter(0.1, 1, 0).Client(k8sClient)
+
+		result, err := handleSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, logger, c, capture,
+			false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", 10*time.Second))
+		// The run isn't over, and being throttled isn't a failure
+		Expect(schedule.Status.RecentRuns).To(BeEmpty())
+		Expect(schedule.Status.NextSnapshotTime.UTC()).To(Equal(snapTime))
+		Expect(capture.regarding(eventReasonSnapshotCreated)).To(ConsistOf(
+			"SnapshotSchedule/hourly", HavePrefix("PersistentVolumeClaim/")))
+		Expect(capture.regarding(eventReasonSnapshotFailed)).To(BeEmpty())
+
+		// Once the limits allow it, the run picks up where it left off
+		_, err = handleSnapshotting(context.TODO(), schedule, snapTime, typeScheduled, logger, k8sClient, capture,
+			false, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schedule.Status.RecentRuns).To(HaveLen(1))
+		Expect(schedule.Status.RecentRuns[0].Snapshots).To(HaveLen(2))
+		Expect(capture.regarding(eventReasonSnapshotCreated)).To(HaveLen(4))
+	})
+})
+
+var _ = Describe("Spreading out snapshot times", func() {
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: "myns",
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+	})
+
+	It("doesn't delay schedules without jitter", func() {
+		Expect(scheduleJitter(schedule)).To(BeZero())
+	})
+	It("delays each schedule by a fixed amount within the window", func() {
+		schedule.Spec.JitterSeconds = ptr.To[int64](600)
+		jitter := scheduleJitter(schedule)
+		Expect(jitter).To(BeNumerically(">=", 0))
+		Expect(jitter).To(BeNumerically("<=", 600*time.Second))
+		Expect(scheduleJitter(schedule)).To(Equal(jitter))
+
+		other := schedule.DeepCopy()
+		other.Name = "hourly-2"
+		Expect(scheduleJitter(other)).NotTo(Equal(jitter))
+	})
+	It("applies the delay to the cronspec's times", func() {
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:03:00Z")
+		next, err := getNextSnapTime(schedule.Spec.Schedule, nil, 7*time.Minute, when)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next.Format(timeFormat)).To(Equal("2024-03-01T02:07:00Z"))
+
+		when, _ = time.Parse(timeFormat, "2024-03-01T02:08:00Z")
+		next, err = getNextSnapTime(schedule.Spec.Schedule, nil, 7*time.Minute, when)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next.Format(timeFormat)).To(Equal("2024-03-01T03:07:00Z"))
+	})
+	It("uses the schedule's jitter for the next snapshot time", func() {
+		schedule.Spec.JitterSeconds = ptr.To[int64](3600)
+		when, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		Expect(updateNextSnapTime(schedule, when)).To(Succeed())
+		next := schedule.Status.NextSnapshotTime.Time
+		Expect(next.After(when)).To(BeTrue())
+		Expect(next.Sub(next.Truncate(time.Hour))).To(Equal(scheduleJitter(schedule) % time.Hour))
+	})
+})
@@ -20,33 +20,113 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
 	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
 )
 
-// expireByCount deletes the oldest snapshots until the number of snapshots for
-// a given PVC (created by the supplied schedule) is no more than the
-// schedule's maxCount. This function is the entry point for count-based
-// expiration of snapshots.
-func expireByCount(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
-	logger logr.Logger, c client.Client, grouped map[string][]snapv1.VolumeSnapshot) error {
-	if schedule.Spec.Retention.MaxCount == nil {
-		// No count-based retention configured
-		return nil
+// snapshotObject is implemented by pointers to the kinds of snapshots that a
+// schedule creates (VolumeSnapshots and VolumeGroupSnapshots), allowing the
+// same retention rules to be applied to each.
+type snapshotObject[S any] interface {
+	*S
+	client.Object
+}
+
+// expireSnapshots applies all of the schedule's retention rules to its
+// snapshots. The grouped snapshots are expired independently of each other:
+// VolumeSnapshots are grouped by PVC while all of a schedule's
+// VolumeGroupSnapshots form a single group. The maxCounts override the
+// schedule's maxCount for the groups they name, and the orphaned groups are
+// subject to the schedule's orphan policy instead, if it has one.
+func expireSnapshots[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	logger logr.Logger, c client.Client, recorder events.EventRecorder, snapList []S, grouped map[string][]S,
+	maxCounts map[string]int32, orphaned map[string]struct{}) error {
+	// Pinned snapshots are exempt from all of the retention rules, so they are
+	// neither deleted nor counted against maxCount
+	now := time.Now()
+	snapList = filterUnpinnedSnaps[S, P](snapList, now)
+	unpinned := make(map[string][]S, len(grouped))
+	for key, list := range grouped {
+		unpinned[key] = filterUnpinnedSnaps[S, P](list, now)
+	}
+	grouped = unpinned
+
+	// Final snapshots are subject only to the finalSnapshot's own expiration
+	snapList, finalSnaps := partitionFinalSnaps[S, P](snapList)
+	for key, list := range grouped {
+		grouped[key], _ = partitionFinalSnaps[S, P](list)
+	}
+	if err := expireFinalSnapshots[S, P](ctx, schedule, now, logger, c, recorder, finalSnaps); err != nil {
+		logger.Error(err, "expireFinalSnapshots")
+		return err
+	}
+
+	if schedule.Spec.Retention.Orphans != nil && len(orphaned) > 0 {
+		var orphanGroups map[string][]S
+		snapList, orphanGroups = splitOrphanedSnaps[S, P](snapList, grouped, orphaned)
+		if err := expireOrphans[S, P](ctx, schedule, now, logger, c, recorder, orphanGroups); err != nil {
+			logger.Error(err, "expireOrphans")
+			return err
+		}
+	}
+
+	if err := expireByTime[S, P](ctx, schedule, now, logger, c, recorder, snapList); err != nil {
+		logger.Error(err, "expireByTime")
+		return err
+	}
+
+	if err := expireNotReady[S, P](ctx, schedule, now, logger, c, recorder, snapList); err != nil {
+		logger.Error(err, "expireNotReady")
+		return err
 	}
 
-	for _, list := range grouped {
-		list = sortSnapsByTime(list)
-		if len(list) > int(*schedule.Spec.Retention.MaxCount) {
-			list = list[:len(list)-int(*schedule.Spec.Retention.MaxCount)]
-			err := deleteSnapshots(ctx, list, logger, c)
+	if err := expireByTiers[S, P](ctx, schedule, logger, c, recorder, grouped); err != nil {
+		logger.Error(err, "expireByTiers")
+		return err
+	}
+
+	if err := expireByCount[S, P](ctx, schedule, logger, c, recorder, grouped, maxCounts); err != nil {
+		logger.Error(err, "expireByCount")
+		return err
+	}
+	return nil
+}
+
+// expireByCount deletes the oldest snapshots until the number of ready
+// snapshots for a given PVC (created by the supplied schedule) is no more than
+// the schedule's maxCount. Snapshots that are not ready are neither counted nor
+// deleted, so at least maxCount ready snapshots are always kept. A group's
+// entry in maxCounts takes the place of the schedule's maxCount. This function
+// is the entry point for count-based expiration of snapshots.
+func expireByCount[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	logger logr.Logger, c client.Client, recorder events.EventRecorder, grouped map[string][]S,
+	maxCounts map[string]int32) error {
+	for key, list := range grouped {
+		maxCount, found := maxCounts[key]
+		if !found {
+			if schedule.Spec.Retention.MaxCount == nil {
+				// No count-based retention configured
+				continue
+			}
+			maxCount = *schedule.Spec.Retention.MaxCount
+		}
+		list = sortSnapsByTime[S, P](filterReadySnaps[S, P](list))
+		if len(list) > int(maxCount) {
+			list = list[:len(list)-int(maxCount)]
+			err := deleteSnapshots[S, P](ctx, schedule, list, logger, c, recorder)
 			if err != nil {
 				return err
 			}
@@ -56,11 +136,107 @@ func expireByCount(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedu
 	return nil
 }
 
+// expireByTiers deletes the ready snapshots for each PVC that are not retained
+// by any of the schedule's retention tiers. Snapshots that are not ready don't
+// occupy a tier. This function is the entry point for tiered
+// (grandfather-father-son) expiration of snapshots.
+func expireByTiers[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	logger logr.Logger, c client.Client, recorder events.EventRecorder, grouped map[string][]S) error {
+	tiers := retentionTiers(schedule.Spec.Retention.Tiers)
+	if len(tiers) == 0 {
+		// No tiered retention configured
+		return nil
+	}
+
+	// Periods follow the schedule's time zone so that, for example, daily
+	// snapshots are grouped by the local day.
+	loc, err := scheduleLocation(&schedule.Spec)
+	if err != nil {
+		return err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for pvcName, list := range grouped {
+		list = filterReadySnaps[S, P](list)
+		expiredSnaps := filterUntieredSnaps[S, P](list, tiers, loc)
+		logger.Info("deleting snapshots not retained by any tier", "PVC", pvcName,
+			"total", len(list), "expired", len(expiredSnaps))
+		if err := deleteSnapshots[S, P](ctx, schedule, expiredSnaps, logger, c, recorder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retentionTier retains the newest snapshot from each of the count most
+// recent periods, as determined by the period function.
+type retentionTier struct {
+	count  int
+	period func(time.Time) string
+}
+
+// retentionTiers returns the tiers with a non-zero count from the supplied
+// tiered retention spec
+func retentionTiers(spec *snapschedulerv1.TieredRetentionSpec) []retentionTier {
+	if spec == nil {
+		return nil
+	}
+	candidates := []struct {
+		count  *int32
+		period func(time.Time) string
+	}{
+		{spec.Hourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{spec.Daily, func(t time.Time) string { return t.Format("20060102") }},
+		{spec.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-%02d", year, week)
+		}},
+		{spec.Monthly, func(t time.Time) string { return t.Format("200601") }},
+		{spec.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	tiers := []retentionTier{}
+	for _, candidate := range candidates {
+		if candidate.count != nil && *candidate.count > 0 {
+			tiers = append(tiers, retentionTier{int(*candidate.count), candidate.period})
+		}
+	}
+	return tiers
+}
+
+// filterUntieredSnaps returns the snapshots from the list (all of the same PVC)
+// that are not retained by any of the tiers. Periods are determined in loc.
+func filterUntieredSnaps[S any, P snapshotObject[S]](snaps []S, tiers []retentionTier,
+	loc *time.Location) []S {
+	sorted := sortSnapsByScheduledTime[S, P](snaps)
+	retained := make([]bool, len(sorted))
+	for _, tier := range tiers {
+		periods := make(map[string]struct{}, tier.count)
+		// Walk from newest to oldest, keeping the first snapshot of each period
+		for i := len(sorted) - 1; i >= 0 && len(periods) < tier.count; i-- {
+			period := tier.period(snapshotScheduledTime(P(&sorted[i])).In(loc))
+			if _, seen := periods[period]; !seen {
+				periods[period] = struct{}{}
+				retained[i] = true
+			}
+		}
+	}
+
+	outList := make([]S, 0)
+	for i := range sorted {
+		if !retained[i] {
+			outList = append(outList, sorted[i])
+		}
+	}
+	return outList
+}
+
 // expireByTime deletes snapshots that are older than the retention time in the
 // specified schedule. It only affects snapshots that were created by the provided schedule.
 // This function is the entry point for the time-based expiration of snapshots
-func expireByTime(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
-	now time.Time, logger logr.Logger, c client.Client, snapList []snapv1.VolumeSnapshot) error {
+func expireByTime[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	now time.Time, logger logr.Logger, c client.Client, recorder events.EventRecorder, snapList []S) error {
 	expiration, err := getExpirationTime(schedule, now, logger)
 	if err != nil {
 		logger.Error(err, "unable to determine snapshot expiration time")
@@ -71,22 +247,125 @@ func expireByTime(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedul
 		return nil
 	}
 
-	expiredSnaps := filterExpiredSnaps(snapList, *expiration)
+	expiredSnaps := filterExpiredSnaps[S, P](snapList, *expiration)
 
 	logger.Info("deleting expired snapshots", "expiration", expiration.Format(time.RFC3339),
 		"total", len(snapList), "expired", len(expiredSnaps))
-	err = deleteSnapshots(ctx, expiredSnaps, logger, c)
+	err = deleteSnapshots[S, P](ctx, schedule, expiredSnaps, logger, c, recorder)
 	return err
 }
 
-func deleteSnapshots(ctx context.Context, snapshots []snapv1.VolumeSnapshot,
-	logger logr.Logger, c client.Client) error {
+// expireFinalSnapshots deletes the final snapshots that are older than the
+// schedule's finalSnapshot expiration. Without one, they are kept.
+func expireFinalSnapshots[S any, P snapshotObject[S]](ctx context.Context,
+	schedule *snapschedulerv1.SnapshotSchedule, now time.Time, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, finalSnaps []S) error {
+	spec := schedule.Spec.FinalSnapshot
+	if spec == nil || spec.Expires == "" || len(finalSnaps) == 0 {
+		return nil
+	}
+	lifetime, err := parseRetentionDuration(spec.Expires)
+	if err != nil {
+		logger.Error(err, "invalid value for spec.finalSnapshot.expires")
+		return err
+	}
+	expiredSnaps := filterExpiredSnaps[S, P](finalSnaps, now.Add(-lifetime))
+	if len(expiredSnaps) > 0 {
+		logger.Info("deleting expired final snapshots", "expired", len(expiredSnaps))
+	}
+	return deleteSnapshots[S, P](ctx, schedule, expiredSnaps, logger, c, recorder)
+}
+
+// defaultNotReadyTimeout is the notReadyTimeout of schedules with count-based
+// or tiered retention that don't set one. Those rules skip snapshots that are
+// not ready, so without a timeout, failed snapshots would pile up.
+const defaultNotReadyTimeout = 24 * time.Hour
+
+// expireNotReady deletes the snapshots that have not become ready to use
+// within the schedule's notReadyTimeout. This function is the entry point for
+// the cleanup of snapshots that are stuck or have failed.
+func expireNotReady[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	now time.Time, logger logr.Logger, c client.Client, recorder events.EventRecorder, snapList []S) error {
+	retention := &schedule.Spec.Retention
+	timeout := defaultNotReadyTimeout
+	if retention.NotReadyTimeout != "" {
+		var err error
+		timeout, err = parseRetentionDuration(retention.NotReadyTimeout)
+		if err != nil {
+			logger.Error(err, "invalid value for spec.retention.notReadyTimeout")
+			return err
+		}
+	} else if retention.MaxCount == nil && len(retentionTiers(retention.Tiers)) == 0 {
+		// Snapshots that aren't ready are only removed by the expiration period
+		return nil
+	}
+
+	cutoff := now.Add(-timeout)
+	expiredSnaps := make([]S, 0)
+	for i := range snapList {
+		snap := P(&snapList[i])
+		if created := snap.GetCreationTimestamp(); !isSnapshotObjectReady(snap) && created.Time.Before(cutoff) {
+			expiredSnaps = append(expiredSnaps, snapList[i])
+		}
+	}
+
+	logger.Info("deleting snapshots that did not become ready", "timeout", timeout.String(),
+		"total", len(snapList), "expired", len(expiredSnaps))
+	return deleteSnapshots[S, P](ctx, schedule, expiredSnaps, logger, c, recorder)
+}
+
+// isSnapshotObjectReady reports whether a snapshot of either kind is ready to
+// use
+func isSnapshotObjectReady(snap client.Object) bool {
+	switch s := snap.(type) {
+	case *snapv1.VolumeSnapshot:
+		return isSnapshotReady(s)
+	case *groupsnapv1beta2.VolumeGroupSnapshot:
+		return s.Status != nil && s.Status.ReadyToUse != nil && *s.Status.ReadyToUse
+	}
+	return false
+}
+
+// filterReadySnaps returns the snapshots from the list that are ready to use
+func filterReadySnaps[S any, P snapshotObject[S]](snaps []S) []S {
+	outList := make([]S, 0, len(snaps))
+	for i := range snaps {
+		if isSnapshotObjectReady(P(&snaps[i])) {
+			outList = append(outList, snaps[i])
+		}
+	}
+	return outList
+}
+
+// deleteSnapshots deletes the snapshots, recording an Event for each on the
+// schedule and, for VolumeSnapshots, on the source PVC. In dry-run mode, the
+// snapshots are only noted in the schedule's status.
+func deleteSnapshots[S any, P snapshotObject[S]](ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	snapshots []S, logger logr.Logger, c client.Client, recorder events.EventRecorder) error {
+	if schedule.Spec.DryRun {
+		for i := range snapshots {
+			recordWouldExpire(schedule, P(&snapshots[i]).GetName())
+		}
+		return nil
+	}
 	for i := range snapshots {
-		snap := snapshots[i]
-		if err := c.Delete(ctx, &snap, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
-			logger.Error(err, "error deleting snapshot", "name", snap.Name)
+		snap := P(&snapshots[i])
+		err := c.Delete(ctx, snap, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		// A snapshot may be selected by more than one retention rule, so it
+		// could already be gone.
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "error deleting snapshot", "name", snap.GetName())
 			return err
 		}
+		var pvc *corev1.PersistentVolumeClaim
+		if vs, ok := any(snap).(*snapv1.VolumeSnapshot); ok && vs.Spec.Source.PersistentVolumeClaimName != nil {
+			pvc = sourceClaim(ctx, c, vs.Namespace, *vs.Spec.Source.PersistentVolumeClaimName)
+		}
+		recordClaimEvent(recorder, schedule, pvc, snap, corev1.EventTypeNormal, eventReasonSnapshotExpired,
+			eventActionExpire, "Deleted snapshot %s per retention policy", snap.GetName())
 	}
 	return nil
 }
@@ -101,14 +380,8 @@ func getExpirationTime(schedule *snapschedulerv1.SnapshotSchedule,
 		return nil, nil
 	}
 
-	lifetime, err := time.ParseDuration(schedule.Spec.Retention.Expires)
+	lifetime, err := parseRetentionDuration(schedule.Spec.Retention.Expires)
 	if err != nil {
-		logger.Error(err, "unable to parse spec.retention.expires")
-		return nil, err
-	}
-
-	if lifetime < 0 {
-		err := errors.New("duration must be greater than 0")
 		logger.Error(err, "invalid value for spec.retention.expires")
 		return nil, err
 	}
@@ -117,25 +390,109 @@ func getExpirationTime(schedule *snapschedulerv1.SnapshotSchedule,
 	return &expiration, nil
 }
 
+// parseRetentionDuration parses a retention period, ensuring it is not
+// negative
+func parseRetentionDuration(duration string) (time.Duration, error) {
+	lifetime, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, err
+	}
+	if lifetime < 0 {
+		return 0, errors.New("duration must be greater than 0")
+	}
+	return lifetime, nil
+}
+
 // filterExpiredSnaps returns the set of expired snapshots from the provided list.
-func filterExpiredSnaps(snaps []snapv1.VolumeSnapshot,
-	expiration time.Time) []snapv1.VolumeSnapshot {
-	outList := make([]snapv1.VolumeSnapshot, 0)
-	for _, snap := range snaps {
-		if snap.CreationTimestamp.Time.Before(expiration) {
-			outList = append(outList, snap)
+func filterExpiredSnaps[S any, P snapshotObject[S]](snaps []S, expiration time.Time) []S {
+	outList := make([]S, 0)
+	for i := range snaps {
+		if created := P(&snaps[i]).GetCreationTimestamp(); created.Time.Before(expiration) {
+			outList = append(outList, snaps[i])
 		}
 	}
 	return outList
 }
 
+// isSnapshotPinned reports whether the snapshot has been exempted from
+// retention, either indefinitely or until a time that has yet to pass
+func isSnapshotPinned(snap metav1.Object, now time.Time) bool {
+	annotations := snap.GetAnnotations()
+	if pinned, err := strconv.ParseBool(annotations[RetainKey]); err == nil && pinned {
+		return true
+	}
+	if until, found := annotations[RetainUntilKey]; found {
+		t, err := time.Parse(time.RFC3339, until)
+		// Keep snapshots with an unparsable time rather than risk losing one
+		// that was meant to be kept
+		return err != nil || now.Before(t)
+	}
+	return false
+}
+
+// filterUnpinnedSnaps returns the snapshots from the list that are not pinned
+func filterUnpinnedSnaps[S any, P snapshotObject[S]](snaps []S, now time.Time) []S {
+	outList := make([]S, 0, len(snaps))
+	for i := range snaps {
+		if !isSnapshotPinned(P(&snaps[i]), now) {
+			outList = append(outList, snaps[i])
+		}
+	}
+	return outList
+}
+
+// isFinalSnapshot reports whether the snapshot was taken as its PVC was deleted
+func isFinalSnapshot(snap metav1.Object) bool {
+	return snap.GetLabels()[TypeKey] == TypeFinal
+}
+
+// partitionFinalSnaps separates the final snapshots in the list from the rest
+func partitionFinalSnaps[S any, P snapshotObject[S]](snaps []S) ([]S, []S) {
+	regular := make([]S, 0, len(snaps))
+	var final []S
+	for i := range snaps {
+		if isFinalSnapshot(P(&snaps[i])) {
+			final = append(final, snaps[i])
+		} else {
+			regular = append(regular, snaps[i])
+		}
+	}
+	return regular, final
+}
+
+// claimMaxCounts returns the number of snapshots to keep for each of the PVCs
+// in the schedule's namespace that overrides it with the MaxCountKey
+// annotation. Invalid values are ignored.
+func claimMaxCounts(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
+	logger logr.Logger, c client.Client) (map[string]int32, error) {
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, pvcList, client.InNamespace(schedule.Namespace)); err != nil {
+		logger.Error(err, "unable to list PVCs")
+		return nil, err
+	}
+	maxCounts := make(map[string]int32)
+	for _, pvc := range pvcList.Items {
+		value, found := pvc.Annotations[MaxCountKey]
+		if !found {
+			continue
+		}
+		maxCount, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || maxCount < 1 {
+			logger.Info("ignoring invalid annotation", "PVC", pvc.Name, "annotation", MaxCountKey, "value", value)
+			continue
+		}
+		maxCounts[pvc.Name] = int32(maxCount)
+	}
+	return maxCounts, nil
+}
+
 // snapshotsFromSchedule returns a list of snapshots that were created by the
 // supplied schedule
 func snapshotsFromSchedule(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
 	logger logr.Logger, c client.Client) ([]snapv1.VolumeSnapshot, error) {
 	labelSelector := &metav1.LabelSelector{
 		MatchLabels: map[string]string{
-			ScheduleKey: schedule.Name,
+			scheduleLabelKey(schedule): schedule.Name,
 		},
 	}
 	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
@@ -177,11 +534,35 @@ func groupSnapsByPVC(snaps []snapv1.VolumeSnapshot) map[string][]snapv1.VolumeSn
 	return groupedSnaps
 }
 
+// snapshotScheduledTime returns the time at which the snapshot was scheduled
+// to be taken, as recorded in its WhenKey label. If the label is missing or
+// invalid, the creation time of the snapshot is used instead.
+func snapshotScheduledTime(snap metav1.Object) time.Time {
+	if when, found := snap.GetLabels()[WhenKey]; found {
+		if t, err := time.Parse(timeYYYYMMDDHHMMSS, when); err == nil {
+			return t
+		}
+	}
+	return snap.GetCreationTimestamp().UTC()
+}
+
+// sortSnapsByScheduledTime sorts the snapshots in order of ascending scheduled
+// time
+func sortSnapsByScheduledTime[S any, P snapshotObject[S]](snaps []S) []S {
+	sorted := append([]S(nil), snaps...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return snapshotScheduledTime(P(&sorted[i])).Before(snapshotScheduledTime(P(&sorted[j])))
+	})
+	return sorted
+}
+
 // sortSnapsByTime sorts the snapshots in order of ascending CreationTimestamp
-func sortSnapsByTime(snaps []snapv1.VolumeSnapshot) []snapv1.VolumeSnapshot {
-	sorted := append([]snapv1.VolumeSnapshot(nil), snaps...)
+func sortSnapsByTime[S any, P snapshotObject[S]](snaps []S) []S {
+	sorted := append([]S(nil), snaps...)
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+		iTime := P(&sorted[i]).GetCreationTimestamp()
+		jTime := P(&sorted[j]).GetCreationTimestamp()
+		return iTime.Before(&jTime)
 	})
 	return sorted
 }
@@ -29,7 +29,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/utils/pointer"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -43,6 +45,9 @@ const (
 
 var logger = logf.Log
 
+// Events emitted by tests that don't examine them are discarded
+var recorder events.EventRecorder = &events.FakeRecorder{}
+
 var _ = Describe("Snapshot expiration time is parsed correctly", func() {
 	When("no retention time is set", func() {
 		It("returns a nil expiration time", func() {
@@ -247,7 +252,7 @@ var _ = Describe("Expiring snapshots by time", func() {
 			}
 			snapList, err := snapshotsFromSchedule(context.TODO(), noexpire, logger, k8sClient)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(expireByTime(context.TODO(), noexpire, time.Now(), logger, k8sClient, snapList)).To(Succeed())
+			Expect(expireByTime(context.TODO(), noexpire, time.Now(), logger, k8sClient, recorder, snapList)).To(Succeed())
 
 			Eventually(func() int {
 				snapList := &snapv1.VolumeSnapshotList{}
@@ -271,7 +276,7 @@ var _ = Describe("Expiring snapshots by time", func() {
 
 			snapList, err := snapshotsFromSchedule(context.TODO(), s, logger, k8sClient)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(expireByTime(context.TODO(), s, time.Now(), logger, k8sClient, snapList)).To(Succeed())
+			Expect(expireByTime(context.TODO(), s, time.Now(), logger, k8sClient, recorder, snapList)).To(Succeed())
 			Eventually(func() int {
 				snapList := &snapv1.VolumeSnapshotList{}
 				Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns1.Name))).To(Succeed())
@@ -283,7 +288,8 @@ var _ = Describe("Expiring snapshots by time", func() {
 
 			snapList2, err := snapshotsFromSchedule(context.TODO(), s, logger, k8sClient)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(expireByTime(context.TODO(), s, time.Now().Add(48*time.Hour), logger, k8sClient, snapList2)).To(Succeed())
+			Expect(expireByTime(context.TODO(), s, time.Now().Add(48*time.Hour), logger, k8sClient, recorder,
+				snapList2)).To(Succeed())
 			Eventually(func() int {
 				snapList := &snapv1.VolumeSnapshotList{}
 				Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns1.Name))).To(Succeed())
@@ -369,7 +375,7 @@ var _ = Describe("Sorting snapshots", func() {
 		Expect(outSnapList[0].CreationTimestamp.Before(&outSnapList[1].CreationTimestamp)).To(BeTrue())
 		Expect(outSnapList[1].CreationTimestamp.Before(&outSnapList[2].CreationTimestamp)).To(BeTrue())
 
-		Expect(sortSnapsByTime(nil)).To(BeNil())
+		Expect(sortSnapsByTime([]snapv1.VolumeSnapshot(nil))).To(BeNil())
 	})
 })
 
@@ -397,6 +403,9 @@ var _ = Describe("Deleting snapshots", func() {
 		Expect(k8sClient.Delete(context.TODO(), ns2)).To(Succeed())
 	})
 	It("deletes snapshots in the provided list", func() {
+		schedule := &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "sched", Namespace: ns1.Name},
+		}
 		snaps := []snapv1.VolumeSnapshot{
 			{
 				ObjectMeta: metav1.ObjectMeta{
@@ -448,7 +457,7 @@ var _ = Describe("Deleting snapshots", func() {
 			Expect(k8sClient.Create(context.TODO(), &o)).To(Succeed())
 		}
 
-		Expect(deleteSnapshots(context.TODO(), snapList, logger, k8sClient)).To(Succeed())
+		Expect(deleteSnapshots(context.TODO(), schedule, snapList, logger, k8sClient, recorder)).To(Succeed())
 
 		snap := &snapv1.VolumeSnapshot{}
 		Eventually(func() bool {
@@ -460,7 +469,8 @@ var _ = Describe("Deleting snapshots", func() {
 			return k8sClient.Get(context.TODO(), client.ObjectKey{Name: "splat", Namespace: ns2.Name}, snap)
 		}, timeout, interval).Should(Succeed())
 
-		Expect(deleteSnapshots(context.TODO(), nil, logger, k8sClient)).To(Succeed())
+		Expect(deleteSnapshots(context.TODO(), schedule, []snapv1.VolumeSnapshot(nil), logger, k8sClient,
+			recorder)).To(Succeed())
 	})
 })
 
@@ -519,6 +529,8 @@ var _ = Describe("Expiring snapshots by count", func() {
 				},
 			}
 			Expect(k8sClient.Create(context.TODO(), &snap)).To(Succeed())
+			snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+			Expect(k8sClient.Status().Update(context.TODO(), &snap)).To(Succeed())
 			time.Sleep(time.Second)
 			Eventually(func() error {
 				s := snapv1.VolumeSnapshot{}
@@ -541,7 +553,8 @@ var _ = Describe("Expiring snapshots by count", func() {
 		// no maxCount, none should be pruned
 		snapList, err := snapshotsFromSchedule(context.TODO(), noexpire, logger, k8sClient)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(expireByCount(context.TODO(), noexpire, logger, k8sClient, groupSnapsByPVC(snapList))).To(Succeed())
+		Expect(expireByCount(context.TODO(), noexpire, logger, k8sClient, recorder, groupSnapsByPVC(snapList),
+			nil)).To(Succeed())
 		Eventually(func() int {
 			snapList := &snapv1.VolumeSnapshotList{}
 			Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns1.Name))).To(Succeed())
@@ -563,7 +576,8 @@ var _ = Describe("Expiring snapshots by count", func() {
 
 		snapList, err := snapshotsFromSchedule(context.TODO(), s, logger, k8sClient)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(expireByCount(context.TODO(), s, logger, k8sClient, groupSnapsByPVC(snapList))).To(Succeed())
+		Expect(expireByCount(context.TODO(), s, logger, k8sClient, recorder, groupSnapsByPVC(snapList),
+			nil)).To(Succeed())
 		Eventually(func() int {
 			snapList := &snapv1.VolumeSnapshotList{}
 			Expect(k8sClient.List(context.TODO(), snapList, client.InNamespace(ns1.Name))).To(Succeed())
@@ -573,4 +587,326 @@ var _ = Describe("Expiring snapshots by count", func() {
 			return count
 		}, timeout, interval).Should(Equal(len(data) - 1))
 	})
+	It("uses the PVC's own maxCount in place of the schedule's", func() {
+		s := &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "schedule",
+				Namespace: ns1.Name,
+			},
+		}
+		s.Spec.Retention.MaxCount = ptr.To[int32](3)
+
+		snapList, err := snapshotsFromSchedule(context.TODO(), s, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expireByCount(context.TODO(), s, logger, k8sClient, recorder, groupSnapsByPVC(snapList),
+			map[string]int32{"pvc1": 1})).To(Succeed())
+		snapList, err = snapshotsFromSchedule(context.TODO(), s, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapList).To(HaveLen(2))
+	})
+	It("reads each PVC's maxCount from its annotation", func() {
+		for pvcName, maxCount := range map[string]string{"pvc1": "2", "bogus": "two", "zero": "0"} {
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        pvcName,
+					Namespace:   ns1.Name,
+					Annotations: map[string]string{MaxCountKey: maxCount},
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		}
+		s := &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "schedule",
+				Namespace: ns1.Name,
+			},
+		}
+		Expect(claimMaxCounts(context.TODO(), s, logger, k8sClient)).To(Equal(map[string]int32{"pvc1": 2}))
+	})
+})
+
+var _ = Describe("Expiring snapshots by retention tiers", func() {
+	// snapsAt returns a snapshot for each of the supplied (scheduled) times
+	snapsAt := func(times ...string) []snapv1.VolumeSnapshot {
+		snaps := []snapv1.VolumeSnapshot{}
+		for _, t := range times {
+			when, err := time.Parse(timeFormat, t)
+			Expect(err).NotTo(HaveOccurred())
+			snaps = append(snaps, snapv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: t,
+					Labels: map[string]string{
+						WhenKey: when.Format(timeYYYYMMDDHHMMSS),
+					},
+				},
+			})
+		}
+		return snaps
+	}
+	names := func(snaps []snapv1.VolumeSnapshot) []string {
+		out := []string{}
+		for _, snap := range snaps {
+			out = append(out, snap.Name)
+		}
+		return out
+	}
+
+	It("ignores tiers that aren't set", func() {
+		Expect(retentionTiers(nil)).To(BeEmpty())
+		Expect(retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Hourly: pointer.Int32(0),
+		})).To(BeEmpty())
+		Expect(retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Daily:  pointer.Int32(7),
+			Yearly: pointer.Int32(1),
+		})).To(HaveLen(2))
+	})
+	It("keeps the newest snapshot in each period", func() {
+		snaps := snapsAt(
+			"2024-03-01T00:00:00Z",
+			"2024-03-01T12:00:00Z",
+			"2024-03-02T00:00:00Z",
+			"2024-03-02T12:00:00Z",
+			"2024-03-03T00:00:00Z",
+		)
+		tiers := retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Daily: pointer.Int32(2),
+		})
+		Expect(names(filterUntieredSnaps(snaps, tiers, time.UTC))).To(ConsistOf(
+			"2024-03-01T00:00:00Z",
+			"2024-03-01T12:00:00Z",
+			"2024-03-02T00:00:00Z",
+		))
+	})
+	It("retains snapshots kept by any tier", func() {
+		snaps := snapsAt(
+			"2023-12-31T23:00:00Z", // last of 2023
+			"2024-01-14T23:00:00Z", // last of ISO week 2
+			"2024-01-20T23:00:00Z",
+			"2024-01-21T23:00:00Z", // last of ISO week 3
+			"2024-01-22T22:00:00Z",
+			"2024-01-22T23:00:00Z", // newest
+		)
+		tiers := retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Hourly: pointer.Int32(1),
+			Weekly: pointer.Int32(3),
+			Yearly: pointer.Int32(2),
+		})
+		Expect(names(filterUntieredSnaps(snaps, tiers, time.UTC))).To(ConsistOf(
+			"2024-01-20T23:00:00Z",
+			"2024-01-22T22:00:00Z",
+		))
+	})
+	It("groups snapshots into periods in the given time zone", func() {
+		// All on March 1st in New York, but spanning two days in UTC
+		snaps := snapsAt(
+			"2024-03-01T20:00:00Z",
+			"2024-03-02T02:00:00Z",
+		)
+		tiers := retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Daily: pointer.Int32(2),
+		})
+		Expect(filterUntieredSnaps(snaps, tiers, time.UTC)).To(BeEmpty())
+		newYork, err := time.LoadLocation("America/New_York")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(filterUntieredSnaps(snaps, tiers, newYork))).To(ConsistOf("2024-03-01T20:00:00Z"))
+	})
+	It("uses the scheduled time rather than the creation time", func() {
+		snaps := snapsAt("2024-03-01T00:00:00Z", "2024-03-02T00:00:00Z")
+		// Created in the opposite order from which they were scheduled
+		snaps[0].CreationTimestamp = metav1.NewTime(time.Now())
+		snaps[1].CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		tiers := retentionTiers(&snapschedulerv1.TieredRetentionSpec{
+			Daily: pointer.Int32(1),
+		})
+		Expect(names(filterUntieredSnaps(snaps, tiers, time.UTC))).To(ConsistOf("2024-03-01T00:00:00Z"))
+	})
+	It("falls back to the creation time when the label is missing", func() {
+		created := time.Now().Add(-time.Hour)
+		snap := snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(created),
+			},
+		}
+		Expect(snapshotScheduledTime(&snap)).To(BeTemporally("==", created))
+		snap.Labels = map[string]string{WhenKey: "garbage"}
+		Expect(snapshotScheduledTime(&snap)).To(BeTemporally("==", created))
+	})
+})
+
+var _ = DescribeTable("Determining whether a snapshot is pinned",
+	func(annotations map[string]string, pinned bool) {
+		now, _ := time.Parse(timeFormat, "2024-03-01T02:00:00Z")
+		snap := &snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+		}
+		Expect(isSnapshotPinned(snap, now)).To(Equal(pinned))
+	},
+	Entry("no annotations", nil, false),
+	Entry("retained", map[string]string{RetainKey: "true"}, true),
+	Entry("explicitly not retained", map[string]string{RetainKey: "false"}, false),
+	Entry("retained until a future time", map[string]string{RetainUntilKey: "2024-03-02T00:00:00Z"}, true),
+	Entry("retained until a past time", map[string]string{RetainUntilKey: "2024-02-29T00:00:00Z"}, false),
+	Entry("retained until an unparsable time", map[string]string{RetainUntilKey: "next week"}, true),
+)
+
+var _ = Describe("Expiring pinned snapshots", func() {
+	var ns *v1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		ns = &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: ns.Name},
+		}
+		for i, name := range []string{"oldest", "middle", "newest"} {
+			snap := snapv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+					Labels: map[string]string{
+						ScheduleKey: schedule.Name,
+						WhenKey:     time.Date(2024, 3, 1, i, 0, 0, 0, time.UTC).Format(timeYYYYMMDDHHMMSS),
+					},
+				},
+				Spec: snapv1.VolumeSnapshotSpec{
+					Source: snapv1.VolumeSnapshotSource{
+						PersistentVolumeClaimName: pointer.String("data"),
+					},
+				},
+			}
+			if name == "oldest" {
+				snap.Annotations = map[string]string{RetainKey: "true"}
+			}
+			Expect(k8sClient.Create(context.TODO(), &snap)).To(Succeed())
+			snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+			Expect(k8sClient.Status().Update(context.TODO(), &snap)).To(Succeed())
+			time.Sleep(time.Second)
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	expire := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expireSnapshots(context.TODO(), schedule, logger, k8sClient, recorder, snapList,
+			groupSnapsByPVC(snapList), nil, nil)).To(Succeed())
+		snapList, err = snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+
+	It("doesn't count them against maxCount", func() {
+		schedule.Spec.Retention.MaxCount = ptr.To[int32](1)
+		Expect(expire()).To(ConsistOf("oldest", "newest"))
+	})
+	It("doesn't expire them by time", func() {
+		schedule.Spec.Retention.Expires = "1s"
+		Expect(expire()).To(ConsistOf("oldest"))
+	})
+	It("doesn't expire them by tier", func() {
+		schedule.Spec.Retention.Tiers = &snapschedulerv1.TieredRetentionSpec{Daily: ptr.To[int32](1)}
+		Expect(expire()).To(ConsistOf("oldest", "newest"))
+	})
+})
+
+var _ = Describe("Expiring snapshots that aren't ready", func() {
+	var ns *v1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		ns = &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: ns.Name},
+		}
+		// The newer snapshots have failed
+		for i, name := range []string{"good1", "good2", "bad1", "bad2"} {
+			snap := snapv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+					Labels: map[string]string{
+						ScheduleKey: schedule.Name,
+						WhenKey:     time.Date(2024, 3, 1, i, 0, 0, 0, time.UTC).Format(timeYYYYMMDDHHMMSS),
+					},
+				},
+				Spec: snapv1.VolumeSnapshotSpec{
+					Source: snapv1.VolumeSnapshotSource{
+						PersistentVolumeClaimName: pointer.String("data"),
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), &snap)).To(Succeed())
+			snap.Status = &snapv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(strings.HasPrefix(name, "good"))}
+			Expect(k8sClient.Status().Update(context.TODO(), &snap)).To(Succeed())
+			time.Sleep(time.Second)
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	expire := func() []string {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expireSnapshots(context.TODO(), schedule, logger, k8sClient, recorder, snapList,
+			groupSnapsByPVC(snapList), nil, nil)).To(Succeed())
+		snapList, err = snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, snap := range snapList {
+			names = append(names, snap.Name)
+		}
+		return names
+	}
+
+	It("keeps maxCount ready snapshots", func() {
+		schedule.Spec.Retention.MaxCount = ptr.To[int32](2)
+		Expect(expire()).To(ConsistOf("good1", "good2", "bad1", "bad2"))
+		schedule.Spec.Retention.MaxCount = ptr.To[int32](1)
+		Expect(expire()).To(ConsistOf("good2", "bad1", "bad2"))
+	})
+	It("doesn't let them occupy a tier", func() {
+		schedule.Spec.Retention.Tiers = &snapschedulerv1.TieredRetentionSpec{Hourly: ptr.To[int32](1)}
+		Expect(expire()).To(ConsistOf("good2", "bad1", "bad2"))
+	})
+	It("deletes them after the timeout", func() {
+		schedule.Spec.Retention.NotReadyTimeout = "1h"
+		Expect(expire()).To(ConsistOf("good1", "good2", "bad1", "bad2"))
+		schedule.Spec.Retention.NotReadyTimeout = "1s"
+		Expect(expire()).To(ConsistOf("good1", "good2"))
+	})
+	It("deletes them after a day when counting snapshots", func() {
+		expireAt := func(now time.Time) []string {
+			snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expireNotReady(context.TODO(), schedule, now, logger, k8sClient, recorder,
+				snapList)).To(Succeed())
+			names := []string{}
+			for _, snap := range snapList {
+				if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(&snap), &snap); err == nil {
+					names = append(names, snap.Name)
+				}
+			}
+			return names
+		}
+		later := time.Now().Add(defaultNotReadyTimeout + time.Minute)
+		// Without count-based or tiered retention, they're left to expire
+		Expect(expireAt(later)).To(ConsistOf("good1", "good2", "bad1", "bad2"))
+		schedule.Spec.Retention.MaxCount = ptr.To[int32](2)
+		Expect(expireAt(time.Now())).To(ConsistOf("good1", "good2", "bad1", "bad2"))
+		Expect(expireAt(later)).To(ConsistOf("good1", "good2"))
+	})
 })
@@ -20,21 +20,29 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	groupsnapv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
 	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"github.com/robfig/cron/v3"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
 )
@@ -50,20 +58,82 @@ const (
 	// WhenKey is a label applied to every snapshot created by
 	// snap-scheduler, denoting the scheduled (not actual) time of the snapshot
 	WhenKey = "snapscheduler.backube/when"
+	// TypeKey is a label applied to snapshots that were not taken at a
+	// scheduled time, denoting why they were taken
+	TypeKey = "snapscheduler.backube/type"
+	// TypeManual marks snapshots taken in response to a manual trigger
+	TypeManual = "manual"
+	// TypeBaseline marks the snapshot taken of a PVC as soon as a schedule
+	// with snapshotOnMatch selects it
+	TypeBaseline = "baseline"
+	// TypeFinal marks the snapshot taken of a PVC as it is deleted
+	TypeFinal = "final"
+	// Run type of the snapshots taken at a scheduled time
+	typeScheduled = ""
+	// RetryKey is a label applied to snapshots that replace a failed or
+	// stuck snapshot, denoting the retry attempt
+	RetryKey = "snapscheduler.backube/retry"
+	// RetainKey is an annotation that, when "true", exempts a snapshot from
+	// its schedule's retention rules
+	RetainKey = "snapscheduler.backube/retain"
+	// RetainUntilKey is an annotation that exempts a snapshot from its
+	// schedule's retention rules until the given (RFC 3339) time
+	RetainUntilKey = "snapscheduler.backube/retain-until"
+	// TriggerKey is an annotation on a schedule that requests an immediate
+	// run. Each new value of the annotation triggers a single run.
+	TriggerKey = "snapscheduler.backube/trigger"
+	// ClusterScheduleKey is a label applied to every snapshot created on
+	// behalf of a ClusterSnapshotSchedule, denoting the schedule that created
+	// it. It is used in place of ScheduleKey.
+	ClusterScheduleKey = "snapscheduler.backube/cluster-schedule"
+	// ExcludeKey is an annotation that, when "true", keeps a PVC from being
+	// snapshotted by any schedule
+	ExcludeKey = "snapscheduler.backube/exclude"
+	// SnapshotClassKey is an annotation on a PVC that names the
+	// VolumeSnapshotClass to use for its snapshots, overriding the schedule's
+	SnapshotClassKey = "snapscheduler.backube/snapshot-class"
+	// MaxCountKey is an annotation on a PVC that sets the number of its
+	// snapshots each schedule keeps, overriding the schedule's maxCount
+	MaxCountKey = "snapscheduler.backube/max-count"
+	// FinalSnapshotFinalizer is placed on the PVCs of schedules with a
+	// finalSnapshot, holding their deletion until the final snapshots are taken
+	FinalSnapshotFinalizer = "snapscheduler.backube/final-snapshot"
+	// DeletionRequestKey is an annotation on the final snapshots taken as a
+	// PVC's deletion was being admitted, naming the admission request
+	DeletionRequestKey = "snapscheduler.backube/deletion-request"
+	// ScheduleFinalizer is placed on schedules whose deletionPolicy deletes
+	// snapshots, holding their deletion until the policy has been applied
+	ScheduleFinalizer = "snapscheduler.backube/deletion-policy"
+	// Marks the names of the objects created on behalf of a
+	// ClusterSnapshotSchedule
+	clusterNameMarker = "cluster"
 )
 
 // scheduleTracker holds per-schedule metric tracking state.
 type scheduleTracker struct {
 	readyUIDs map[types.UID]struct{}
 	prevPVCs  map[string]struct{}
+	// The full status of a cluster schedule within a namespace, of which only
+	// a summary is persisted
+	status *snapschedulerv1.SnapshotScheduleStatus
 }
 
 // SnapshotScheduleReconciler reconciles a SnapshotSchedule object
 type SnapshotScheduleReconciler struct {
 	client.Client
 	Scheme                *runtime.Scheme
-	EnableOwnerReferences bool
-	trackers              map[types.NamespacedName]*scheduleTracker
+	Recorder              events.EventRecorder
+	DefaultDeletionPolicy snapschedulerv1.DeletionPolicy
+	CreationLimiter       *SnapshotCreationLimiter
+	// EnableHooks allows the schedules' hooks to run. Hooks run commands in,
+	// and create Jobs in, the schedules' namespaces with the controller's
+	// permissions.
+	EnableHooks bool
+	// EnableFinalSnapshots allows the schedules to hold their PVCs for final
+	// snapshots, which are taken by the PVC webhook
+	EnableFinalSnapshots bool
+	trackers             map[scheduleID]*scheduleTracker
+	hooks                *hookRunner
 }
 
 //nolint:lll
@@ -71,11 +141,13 @@ type SnapshotScheduleReconciler struct {
 //+kubebuilder:rbac:groups=snapscheduler.backube,resources=snapshotschedules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=snapscheduler.backube,resources=snapshotschedules/finalizers,verbs=update
 //+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 
 func (r *SnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	reqLogger := log.FromContext(ctx).WithValues("snapshotschedule", req.NamespacedName)
 	reqLogger.Info("Reconciling SnapshotSchedule")
+	id := scheduleID{kind: snapschedulerv1.SnapshotScheduleKind, namespace: req.Namespace, name: req.Name}
 
 	// Fetch the SnapshotSchedule instance
 	instance := &snapschedulerv1.SnapshotSchedule{}
@@ -84,16 +156,34 @@ func (r *SnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		if kerrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Clean up any lingering gauge metrics and tracker state.
-			cleanupScheduleGauges(req.Name, req.Namespace)
-			delete(r.trackers, req.NamespacedName)
+			cleanupScheduleGauges(id)
+			delete(r.trackers, id)
+			r.hooks.forget(id)
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
 
-	tracker := r.trackerFor(req.NamespacedName)
-	result, err := doReconcile(ctx, instance, reqLogger, r.Client, r.EnableOwnerReferences, tracker)
+	policy := scheduleDeletionPolicy(&instance.Spec, r.DefaultDeletionPolicy)
+	deleting, err := handleDeletionPolicy(ctx, instance, policy, instance.Spec.DryRun, ScheduleKey,
+		instance.Namespace, reqLogger, r.Client, r.Recorder)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if deleting {
+		cleanupScheduleGauges(id)
+		delete(r.trackers, id)
+		r.hooks.forget(id)
+		return ctrl.Result{}, nil
+	}
+
+	prevStatus := instance.Status.DeepCopy()
+	tracker := r.trackerFor(id)
+	result, err := doReconcile(ctx, instance, reqLogger, r.CreationLimiter.Client(r.Client), r.Recorder,
+		ownsSnapshots(&instance.Spec, r.DefaultDeletionPolicy), r.EnableFinalSnapshots, r.hooks, tracker)
+	updateFinalSnapshotCondition(instance, &instance.Status.Conditions, &instance.Spec, r.EnableFinalSnapshots,
+		r.Recorder)
 
 	// Update result in CR
 	if err != nil {
@@ -112,7 +202,11 @@ func (r *SnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		})
 	}
 
-	// Update instance.Status
+	// Update instance.Status, unless nothing changed. Writing an unchanged
+	// status would only trigger another reconcile.
+	if equality.Semantic.DeepEqual(prevStatus, &instance.Status) {
+		return result, err
+	}
 	err2 := r.Client.Status().Update(ctx, instance)
 	if err == nil { // Don't mask previous error
 		err = err2
@@ -122,13 +216,27 @@ func (r *SnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SnapshotScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	r.trackers = make(map[types.NamespacedName]*scheduleTracker)
+	r.trackers = make(map[scheduleID]*scheduleTracker)
+	if r.EnableHooks {
+		executor, err := newPodExecutor(mgr.GetConfig(), mgr.GetHTTPClient())
+		if err != nil {
+			return err
+		}
+		r.hooks = newHookRunner(executor)
+	}
+	// Changes to the schedule's PVCs and snapshots are picked up right away
+	// rather than at the next periodic reconcile
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&snapschedulerv1.SnapshotSchedule{}).
+		Watches(&corev1.PersistentVolumeClaim{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return schedulesForClaim(ctx, r.Client, obj)
+			})).
+		Watches(&snapv1.VolumeSnapshot{}, handler.EnqueueRequestsFromMapFunc(scheduleForSnapshot)).
 		Complete(r)
 }
 
-func (r *SnapshotScheduleReconciler) trackerFor(key types.NamespacedName) *scheduleTracker {
+func (r *SnapshotScheduleReconciler) trackerFor(key scheduleID) *scheduleTracker {
 	t, exists := r.trackers[key]
 	if !exists {
 		t = &scheduleTracker{
@@ -141,32 +249,47 @@ func (r *SnapshotScheduleReconciler) trackerFor(key types.NamespacedName) *sched
 }
 
 func doReconcile(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
-	logger logr.Logger, c client.Client, enableOwnerReferences bool,
-	tracker *scheduleTracker) (ctrl.Result, error) {
+	logger logr.Logger, c client.Client, recorder events.EventRecorder, enableOwnerReferences bool,
+	enableFinalSnapshots bool, hooks *hookRunner, tracker *scheduleTracker) (ctrl.Result, error) {
 	// If necessary, initialize time of next snap based on schedule
 	if schedule.Status.NextSnapshotTime.IsZero() {
 		// Update nextSnapshot time based on current time and cronspec
 		if err := updateNextSnapTime(schedule, time.Now()); err != nil {
 			logger.Error(err, "couldn't update next snap time",
 				"cronspec", schedule.Spec.Schedule)
+			recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonInvalidSchedule,
+				eventActionSchedule, "Unable to schedule snapshots: %v", err)
 			return ctrl.Result{}, err
 		}
 	}
 
+	// Manual runs are carried out even if the schedule is disabled
+	if trigger := pendingTrigger(schedule); trigger != nil {
+		return handleSnapshotting(ctx, schedule, trigger.Time.UTC(), TypeManual, logger, c, recorder,
+			enableOwnerReferences, hooks)
+	}
+
 	timeNow := time.Now()
 	timeNext := schedule.Status.NextSnapshotTime.Time
 	if !schedule.Spec.Disabled && timeNow.After(timeNext) {
+		take, err := catchUpMissedRuns(ctx, schedule, timeNow, logger, c, recorder, hooks)
+		if err != nil || !take {
+			return ctrl.Result{}, err
+		}
 		// It's not necessary to check and contitionally return on error since
 		// modifying .status will immediately cause an addl reconcile pass
 		// (which will cover the rest of this reconcile function). We also don't
 		// want to update nextSnapshot until this round is done.
-		return handleSnapshotting(ctx, schedule, logger, c, enableOwnerReferences)
+		return handleSnapshotting(ctx, schedule, schedule.Status.NextSnapshotTime.UTC(), typeScheduled, logger, c,
+			recorder, enableOwnerReferences, hooks)
 	}
 
 	// We always update nextSnapshot in case the schedule changed
 	if err := updateNextSnapTime(schedule, timeNow); err != nil {
 		logger.Error(err, "couldn't update next snap time",
 			"cronspec", schedule.Spec.Schedule)
+		recorder.Eventf(schedule, nil, corev1.EventTypeWarning, eventReasonInvalidSchedule,
+			eventActionSchedule, "Unable to schedule snapshots: %v", err)
 		return ctrl.Result{}, err
 	}
 
@@ -176,20 +299,92 @@ func doReconcile(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule
 		return ctrl.Result{}, err
 	}
 
-	if err := expireByTime(ctx, schedule, time.Now(), logger, c, snapList); err != nil {
-		logger.Error(err, "expireByTime")
+	// Creations held back by the limits are retried once they're allowed
+	// rather than holding up the rest of the reconcile
+	throttleWait := time.Duration(0)
+	noteThrottled := func(err error) error {
+		wait, throttled := creationThrottled(err)
+		if !throttled {
+			return err
+		}
+		if throttleWait == 0 || wait < throttleWait {
+			throttleWait = wait
+		}
+		return nil
+	}
+
+	took, err := takeBaselineSnapshots(ctx, schedule, snapList, logger, c, recorder, enableOwnerReferences)
+	if err = noteThrottled(err); err != nil {
+		return ctrl.Result{}, err
+	}
+	if took {
+		if snapList, err = snapshotsFromSchedule(ctx, schedule, logger, c); err != nil {
+			logger.Error(err, "unable to retrieve list of snapshots")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if enableFinalSnapshots {
+		if err := protectClaims(ctx, schedule, logger, c); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var previousWouldExpire []string
+	if schedule.Spec.DryRun {
+		previousWouldExpire = beginDryRunExpiration(schedule)
+	} else {
+		schedule.Status.DryRun = nil
+	}
+
+	changed, err := handleFailedSnapshots(ctx, schedule, snapList, timeNow, logger, c, recorder)
+	if err = noteThrottled(err); err != nil {
 		return ctrl.Result{}, err
 	}
+	if changed {
+		// Pick up the snapshots that were replaced or deleted
+		if snapList, err = snapshotsFromSchedule(ctx, schedule, logger, c); err != nil {
+			logger.Error(err, "unable to retrieve list of snapshots")
+			return ctrl.Result{}, err
+		}
+	}
 
+	maxCounts, err := claimMaxCounts(ctx, schedule, logger, c)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	grouped := groupSnapsByPVC(snapList)
-	if err := expireByCount(ctx, schedule, logger, c, grouped); err != nil {
-		logger.Error(err, "expireByCount")
+	orphaned, err := orphanedClaims(ctx, schedule, grouped, logger, c)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := expireSnapshots(ctx, schedule, logger, c, recorder, snapList, grouped, maxCounts,
+		orphaned); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// Group snapshots are expired even if the schedule is no longer in group
+	// mode so that they don't linger after switching modes.
+	groupSnapList, err := groupSnapshotsFromSchedule(ctx, schedule, logger, c)
+	if err != nil {
+		logger.Error(err, "unable to retrieve list of group snapshots")
+		return ctrl.Result{}, err
+	}
+	groupedGroupSnaps := map[string][]groupsnapv1beta2.VolumeGroupSnapshot{schedule.Name: groupSnapList}
+	if err := expireSnapshots(ctx, schedule, logger, c, recorder, groupSnapList, groupedGroupSnaps,
+		nil, nil); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if schedule.Spec.DryRun {
+		reportWouldExpire(schedule, previousWouldExpire, recorder)
+	}
+
+	refreshRunHistory(schedule, snapList, groupSnapList)
+
 	// Update snapshot metrics
-	updateSnapshotGauges(schedule.Name, schedule.Namespace, grouped, tracker.prevPVCs)
-	updateReadyCounter(schedule.Name, schedule.Namespace, grouped, tracker.readyUIDs)
+	updateSnapshotGauges(scheduleIDFor(schedule), grouped, tracker.prevPVCs)
+	updateReadyCounter(scheduleIDFor(schedule), grouped, tracker.readyUIDs)
 
 	// Ensure we requeue in time for the next scheduled snapshot time
 	durTillNext := timeNext.Sub(timeNow)
@@ -197,67 +392,209 @@ func doReconcile(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule
 	if durTillNext < requeueTime {
 		requeueTime = durTillNext
 	}
+	if throttleWait > 0 && throttleWait < requeueTime {
+		requeueTime = throttleWait
+	}
 	return ctrl.Result{RequeueAfter: requeueTime}, nil
 }
 
-func handleSnapshotting(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule,
-	logger logr.Logger, c client.Client, enableOwnerReferences bool) (ctrl.Result, error) {
-	pvcList, err := listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace, &schedule.Spec.ClaimSelector)
+// handleSnapshotting takes the schedule's snapshots for the given time. The run
+// type is empty for scheduled runs.
+func handleSnapshotting(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, logger logr.Logger, c client.Client, recorder events.EventRecorder, enableOwnerReferences bool,
+	hooks *hookRunner) (ctrl.Result, error) {
+	pvcList, err := listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace,
+		&schedule.Spec.ClaimSelector, schedule.Spec.StorageClassSelector)
 	if err != nil {
 		logger.Error(err, "unable to get matching PVCs")
 		return ctrl.Result{}, err
 	}
+	pvcs, err := filterClaims(ctx, schedule, pvcList.Items, logger, c)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if schedule.Spec.DryRun {
+		return previewSnapshots(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder)
+	}
+	if wait := claimRetryWait(schedule, snapTime, runType, time.Now()); wait > 0 {
+		logger.V(4).Info("waiting to retry failed snapshots", "wait", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	if schedule.Spec.Hooks != nil && len(pvcs) > 0 {
+		return snapshotWithHooks(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder,
+			enableOwnerReferences, hooks)
+	}
+	err = takeSnapshots(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder, enableOwnerReferences)
+	if wait, throttled := creationThrottled(err); throttled {
+		logger.V(4).Info("snapshot creation throttled", "wait", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	recordRun(schedule, snapTime, runType, pvcs, err)
+	if err != nil && !abandonFailedClaims(schedule, snapTime, runType, err, logger, recorder) {
+		return ctrl.Result{}, err
+	}
+	return advanceSchedule(schedule, runType, logger)
+}
+
+// pendingTrigger returns the schedule's manual trigger if its run has yet to
+// complete. A new value of the trigger annotation starts a new run, named for
+// the current time.
+func pendingTrigger(schedule *snapschedulerv1.SnapshotSchedule) *snapschedulerv1.TriggerStatus {
+	token := schedule.Annotations[TriggerKey]
+	if token == "" {
+		return nil
+	}
+	if schedule.Status.LastTrigger == nil || schedule.Status.LastTrigger.Token != token {
+		schedule.Status.LastTrigger = &snapschedulerv1.TriggerStatus{
+			Token: token,
+			Time:  metav1.NewTime(time.Now().UTC().Truncate(time.Minute)),
+		}
+	}
+	if schedule.Status.LastTrigger.Completed {
+		return nil
+	}
+	return schedule.Status.LastTrigger
+}
+
+// takeSnapshots creates the snapshots of the PVCs for the scheduled time,
+// either individually or as a group
+func takeSnapshots(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, enableOwnerReferences bool) error {
+	if schedule.Spec.GroupSnapshot != nil {
+		return handleGroupSnapshotting(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder,
+			enableOwnerReferences)
+	}
+	return snapshotClaims(ctx, schedule, snapTime, runType, pvcs, logger, c, recorder, enableOwnerReferences)
+}
+
+// advanceSchedule records that the run's snapshots are done. Scheduled runs
+// move on to the next scheduled time, while manual runs mark their trigger as
+// completed.
+func advanceSchedule(schedule *snapschedulerv1.SnapshotSchedule, runType string,
+	logger logr.Logger) (ctrl.Result, error) {
+	// Update lastSnapshot & nextSnapshot times
+	timeNow := metav1.Now()
+	schedule.Status.LastSnapshotTime = &timeNow
+	if runType == TypeManual {
+		schedule.Status.LastTrigger.Completed = true
+		return ctrl.Result{}, nil
+	}
+	if err := updateNextSnapTime(schedule, timeNow.Time); err != nil {
+		logger.Error(err, "couldn't update next snap time",
+			"cronspec", schedule.Spec.Schedule)
+		return ctrl.Result{}, err
+	}
+	// Changing .status will automatically cause requeuing
+	return ctrl.Result{}, nil
+}
 
-	// Iterate through the PVCs and make sure snapshots exist for each. We
-	// stop and re-queue at the first error.
-	snapTime := schedule.Status.NextSnapshotTime.UTC()
-	for _, pvc := range pvcList.Items {
-		snapName := snapshotName(pvc.Name, schedule.Name, snapTime)
+// snapshotClaims ensures a VolumeSnapshot exists for each of the PVCs at the
+// scheduled time. Every PVC is attempted; the errors of those that fail are
+// returned together as claimErrors. If the creation limits are reached, the
+// throttled error is returned instead, and the remaining PVCs are left for the
+// next attempt.
+func snapshotClaims(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule, snapTime time.Time,
+	runType string, pvcs []corev1.PersistentVolumeClaim, logger logr.Logger, c client.Client,
+	recorder events.EventRecorder, enableOwnerReferences bool) error {
+	var errs claimErrors
+	for _, pvc := range pvcs {
+		snapName := scheduleSnapshotName(pvc.Name, schedule, runNameSuffix(snapTime, runType))
 		logger.V(4).Info("looking for snapshot", "name", snapName)
 		key := types.NamespacedName{Name: snapName, Namespace: pvc.Namespace}
 		snap := snapv1.VolumeSnapshot{}
 		if err := c.Get(ctx, key, &snap); err != nil {
 			if kerrors.IsNotFound(err) {
-				labels := make(map[string]string)
-				var snapshotClassName *string
-				if schedule.Spec.SnapshotTemplate != nil {
-					labels = schedule.Spec.SnapshotTemplate.Labels
-					snapshotClassName = schedule.Spec.SnapshotTemplate.SnapshotClassName
-				}
+				labels, snapshotClassName := snapshotSettings(schedule, &pvc, runType)
 				snap := newSnapForClaim(snapName, pvc, schedule, snapTime, labels, snapshotClassName, enableOwnerReferences)
 				if snap != nil {
 					logger.Info("creating a snapshot", "PVC", pvc.Name, "Snapshot", snapName)
 					if err = c.Create(ctx, snap); err != nil {
+						if _, throttled := creationThrottled(err); throttled {
+							return err
+						}
 						logger.Error(err, "while creating snapshots", "name", snapName)
-						snapshotCreateErrorTotal.With(scheduleLabels(schedule.Name, schedule.Namespace, pvc.Name)).Inc()
-						return ctrl.Result{}, err
+						snapshotCreateErrorTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+						recordClaimEvent(recorder, schedule, &pvc, nil, corev1.EventTypeWarning,
+							eventReasonSnapshotFailed, eventActionCreate, "Failed to create snapshot %s: %v", snapName, err)
+						errs = append(errs, &claimError{claim: pvc.Name, err: err})
+						continue
 					}
-					snapshotCreateTotal.With(scheduleLabels(schedule.Name, schedule.Namespace, pvc.Name)).Inc()
+					snapshotCreateTotal.With(scheduleLabels(scheduleIDFor(schedule), pvc.Name)).Inc()
+					recordClaimEvent(recorder, schedule, &pvc, snap, corev1.EventTypeNormal,
+						eventReasonSnapshotCreated, eventActionCreate, "Created snapshot %s", snapName)
 				} else {
 					logger.Info("unable to create snapshot -- no supported VolumeSnapshot CRD is registered")
 				}
 			} else {
 				logger.Error(err, "looking for snapshot", "name", snapName)
-				return ctrl.Result{}, err
+				errs = append(errs, &claimError{claim: pvc.Name, err: err})
 			}
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
-	// Update lastSnapshot & nextSnapshot times
-	timeNow := metav1.Now()
-	schedule.Status.LastSnapshotTime = &timeNow
-	if err = updateNextSnapTime(schedule, timeNow.Time); err != nil {
-		logger.Error(err, "couldn't update next snap time",
-			"cronspec", schedule.Spec.Schedule)
-		return ctrl.Result{}, err
+// snapshotSettings returns the labels and VolumeSnapshotClass for a snapshot of
+// the PVC, based on the schedule's snapshotTemplate and the PVC's annotations
+func snapshotSettings(schedule *snapschedulerv1.SnapshotSchedule, pvc *corev1.PersistentVolumeClaim,
+	runType string) (map[string]string, *string) {
+	labels := make(map[string]string)
+	var snapshotClassName *string
+	if schedule.Spec.SnapshotTemplate != nil {
+		maps.Copy(labels, schedule.Spec.SnapshotTemplate.Labels)
+		snapshotClassName = schedule.Spec.SnapshotTemplate.SnapshotClassName
 	}
-	// Changing .status will automatically cause requeuing
-	return ctrl.Result{}, nil
+	if class := pvc.Annotations[SnapshotClassKey]; class != "" {
+		snapshotClassName = &class
+	}
+	if runType != typeScheduled {
+		labels[TypeKey] = runType
+	}
+	return labels, snapshotClassName
+}
+
+// scheduleSnapshotName returns the name of the schedule's snapshot of the PVC,
+// ending with the suffix
+func scheduleSnapshotName(pvcName string, schedule *snapschedulerv1.SnapshotSchedule, suffix string) string {
+	return snapshotNameWithSuffix(pvcName, schedule.Name, scheduleNameSuffix(schedule, suffix))
+}
+
+// scheduleNameSuffix returns the suffix of the names of the objects that the
+// schedule creates. Objects created on behalf of a ClusterSnapshotSchedule are
+// marked so that their names differ from those of a same-named
+// SnapshotSchedule in the namespace.
+func scheduleNameSuffix(schedule *snapschedulerv1.SnapshotSchedule, suffix string) string {
+	if scheduleKind(schedule) == snapschedulerv1.ClusterSnapshotScheduleKind {
+		return clusterNameMarker + "-" + suffix
+	}
+	return suffix
+}
+
+// runNameSuffix returns the part of the names of a run's objects that
+// identifies the run. Manual runs are marked so that their objects don't
+// collide with those of a scheduled run in the same minute.
+func runNameSuffix(snapTime time.Time, runType string) string {
+	suffix := snapTime.Format(timeYYYYMMDDHHMMSS)
+	if runType == TypeManual {
+		return suffix + "-" + TypeManual
+	}
+	return suffix
 }
 
 func snapshotName(pvcName string, scheduleName string, time time.Time) string {
+	return snapshotNameWithSuffix(pvcName, scheduleName, time.Format(timeYYYYMMDDHHMMSS))
+}
+
+// snapshotNameWithSuffix joins the PVC and schedule names with the suffix,
+// truncating the names as necessary to keep the result a valid object name
+func snapshotNameWithSuffix(pvcName string, scheduleName string, suffix string) string {
 	// How much room we have for PVC + schedule names
-	nameBudget := validation.DNS1123SubdomainMaxLength - len(timeYYYYMMDDHHMMSS) - 2
+	nameBudget := validation.DNS1123SubdomainMaxLength - len(suffix) - 2
 	// Goal is to minimize the truncation. If one name is short, let the other use the excess
 	if len(pvcName)+len(scheduleName) > nameBudget {
 		pvcOverBudget := len(pvcName) > nameBudget/2
@@ -273,16 +610,21 @@ func snapshotName(pvcName string, scheduleName string, time time.Time) string {
 			pvcName = pvcName[0 : nameBudget/2]
 		}
 	}
-	return pvcName + "-" + scheduleName + "-" + time.Format(timeYYYYMMDDHHMMSS)
+	return pvcName + "-" + scheduleName + "-" + suffix
 }
 
 func updateNextSnapTime(snapshotSchedule *snapschedulerv1.SnapshotSchedule, referenceTime time.Time) error {
 	if snapshotSchedule == nil {
 		return fmt.Errorf("nil snapshotschedule instance")
 	}
-	next, err := getNextSnapTime(snapshotSchedule.Spec.Schedule, referenceTime)
+	loc, err := scheduleLocation(&snapshotSchedule.Spec)
+	var next time.Time
+	if err == nil {
+		next, err = getNextSnapTime(snapshotSchedule.Spec.Schedule, loc, scheduleJitter(snapshotSchedule),
+			referenceTime)
+	}
 	if err != nil {
-		// Couldn't parse cronspec; clear the next snap time
+		// Couldn't parse cronspec or time zone; clear the next snap time
 		snapshotSchedule.Status.NextSnapshotTime = nil
 	} else {
 		mv1time := metav1.NewTime(next)
@@ -291,9 +633,12 @@ func updateNextSnapTime(snapshotSchedule *snapschedulerv1.SnapshotSchedule, refe
 	return err
 }
 
-// listPVCsMatchingSelector retrieves a list of PVCs that match the given selector
+// listPVCsMatchingSelector retrieves a list of PVCs that match the given
+// selector. If a StorageClass selector is provided, only PVCs using one of the
+// selected StorageClasses are returned.
 func listPVCsMatchingSelector(ctx context.Context, logger logr.Logger, c client.Client,
-	namespace string, ls *metav1.LabelSelector) (*corev1.PersistentVolumeClaimList, error) {
+	namespace string, ls *metav1.LabelSelector,
+	scSelector *snapschedulerv1.StorageClassSelector) (*corev1.PersistentVolumeClaimList, error) {
 	selector, err := metav1.LabelSelectorAsSelector(ls)
 	if err != nil {
 		return nil, err
@@ -305,9 +650,78 @@ func listPVCsMatchingSelector(ctx context.Context, logger logr.Logger, c client.
 			Selector: selector,
 		},
 	}
-	err = c.List(ctx, pvcList, listOpts...)
+	if err = c.List(ctx, pvcList, listOpts...); err != nil {
+		return nil, err
+	}
+	if scSelector != nil {
+		classes, err := storageClassesMatchingSelector(ctx, c, scSelector)
+		if err != nil {
+			return nil, err
+		}
+		filtered := make([]corev1.PersistentVolumeClaim, 0, len(pvcList.Items))
+		for _, pvc := range pvcList.Items {
+			if _, found := classes[PVCStorageClassName(&pvc)]; found {
+				filtered = append(filtered, pvc)
+			}
+		}
+		pvcList.Items = filtered
+	}
 	logger.Info("Created list of matching PVCs", "count", len(pvcList.Items))
-	return pvcList, err
+	return pvcList, nil
+}
+
+// ListPVCsForSchedule retrieves the PVCs that are currently selected by the
+// schedule
+func ListPVCsForSchedule(ctx context.Context, logger logr.Logger, c client.Client,
+	schedule *snapschedulerv1.SnapshotSchedule) (*corev1.PersistentVolumeClaimList, error) {
+	return listPVCsMatchingSelector(ctx, logger, c, schedule.Namespace, &schedule.Spec.ClaimSelector,
+		schedule.Spec.StorageClassSelector)
+}
+
+// storageClassesMatchingSelector returns the set of StorageClass names that
+// are selected by the supplied StorageClassSelector
+func storageClassesMatchingSelector(ctx context.Context, c client.Client,
+	scSelector *snapschedulerv1.StorageClassSelector) (map[string]struct{}, error) {
+	classes := make(map[string]struct{}, len(scSelector.Names))
+	for _, name := range scSelector.Names {
+		classes[name] = struct{}{}
+	}
+	if scSelector.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(scSelector.Selector)
+		if err != nil {
+			return nil, err
+		}
+		scList := &storagev1.StorageClassList{}
+		if err = c.List(ctx, scList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		for _, sc := range scList.Items {
+			classes[sc.Name] = struct{}{}
+		}
+	}
+	return classes, nil
+}
+
+// PVCStorageClassName returns the name of the StorageClass used by the PVC,
+// honoring the deprecated beta annotation if it is present.
+func PVCStorageClassName(pvc *corev1.PersistentVolumeClaim) string {
+	if class, found := pvc.Annotations[corev1.BetaStorageClassAnnotation]; found {
+		return class
+	}
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName
+	}
+	return ""
+}
+
+// NormalizeCronspec returns the cronspec with redundant whitespace removed and
+// descriptors (e.g., "@Daily") converted to lowercase so that they parse.
+func NormalizeCronspec(cronspec string) string {
+	normalized := strings.Join(strings.Fields(cronspec), " ")
+	if strings.HasPrefix(normalized, "@") {
+		normalized = strings.ToLower(normalized)
+	}
+	return normalized
 }
 
 func parseCronspec(cronspec string) (cron.Schedule, error) {
@@ -315,14 +729,105 @@ func parseCronspec(cronspec string) (cron.Schedule, error) {
 	return p.Parse(cronspec)
 }
 
-func getNextSnapTime(cronspec string, when time.Time) (time.Time, error) {
+// allHours is the bitmask of a cron.SpecSchedule that fires every hour
+const allHours = 1<<24 - 1
+
+// scheduleLocation returns the time zone in which the schedule's cronspec is
+// evaluated, or nil if the schedule doesn't specify one.
+func scheduleLocation(spec *snapschedulerv1.SnapshotScheduleSpec) (*time.Location, error) {
+	if spec.TimeZone == nil {
+		return nil, nil
+	}
+	if *spec.TimeZone == "" || *spec.TimeZone == "Local" {
+		return nil, fmt.Errorf("%q is not an IANA time zone name", *spec.TimeZone)
+	}
+	return time.LoadLocation(*spec.TimeZone)
+}
+
+// getNextSnapTime returns the first time after when that matches the cronspec
+// as evaluated in loc, delayed by the jitter. If loc is nil, the location of
+// when is used.
+//
+// Schedules that fire at particular hours follow the wall clock across
+// daylight saving time transitions: a time that is skipped when the clocks
+// spring forward fires at the end of the gap, and a time that is repeated when
+// the clocks fall back fires only on its first occurrence. Schedules that fire
+// every hour follow elapsed time, so they neither pause nor double up.
+func getNextSnapTime(cronspec string, loc *time.Location, jitter time.Duration,
+	when time.Time) (time.Time, error) {
+	if jitter != 0 {
+		// Each matching time is delayed by the jitter
+		next, err := getNextSnapTime(cronspec, loc, 0, when.Add(-jitter))
+		return next.Add(jitter), err
+	}
 	schedule, err := parseCronspec(cronspec)
 	if err != nil {
 		return time.Time{}, err
 	}
+	if loc == nil {
+		loc = when.Location()
+	}
 
-	next := schedule.Next(when)
-	return next, nil
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		// Fixed intervals (@every) don't depend on the time zone
+		return schedule.Next(when), nil
+	}
+	if spec.Hour&allHours == allHours {
+		zoned := *spec
+		zoned.Location = loc
+		return zoned.Next(when), nil
+	}
+
+	// Find the next matching wall clock time, treating the wall clock as UTC so
+	// that it is free of transitions, then map it back to an instant in loc.
+	wallSpec := *spec
+	wallSpec.Location = time.UTC
+	wall := wallClock(when, loc)
+	for {
+		wall = wallSpec.Next(wall)
+		if wall.IsZero() {
+			return time.Time{}, fmt.Errorf("cronspec %q never matches", cronspec)
+		}
+		if next := fromWallClock(wall, loc); next.After(when) {
+			return next.In(when.Location()), nil
+		}
+	}
+}
+
+// wallClock returns the reading of the clock in loc at time t, expressed as a
+// time in UTC
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWallClock returns the first instant at which the clock in loc reads
+// wall. If the clock skips over that reading, the instant at which it resumes
+// is returned instead.
+func fromWallClock(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(),
+		wall.Nanosecond(), loc)
+	start, end := t.ZoneBounds()
+	reading := wallClock(t, loc)
+	switch {
+	case reading.After(wall):
+		// In a gap; t has been pushed past it into the new zone
+		return start
+	case reading.Before(wall):
+		// In a gap; t has been pulled back into the old zone
+		return end
+	case !start.IsZero():
+		// If the clock was set back as this zone began, the same reading may
+		// have occurred earlier in the previous zone.
+		_, offset := t.Zone()
+		_, prevOffset := start.Add(-time.Nanosecond).Zone()
+		earlier := t.Add(time.Duration(offset-prevOffset) * time.Second)
+		if earlier.Before(start) && wallClock(earlier, loc).Equal(wall) {
+			return earlier
+		}
+	}
+	return t
 }
 
 func newSnapForClaim(snapName string, pvc corev1.PersistentVolumeClaim,
@@ -336,7 +841,7 @@ func newSnapForClaim(snapName string, pvc corev1.PersistentVolumeClaim,
 	for k, v := range labels {
 		snapLabels[k] = v
 	}
-	snapLabels[ScheduleKey] = schedule.Name
+	snapLabels[scheduleLabelKey(schedule)] = schedule.Name
 	snapLabels[WhenKey] = scheduleTime.Format(timeYYYYMMDDHHMMSS)
 	snapshot := &snapv1.VolumeSnapshot{
 		ObjectMeta: metav1.ObjectMeta{
@@ -365,3 +870,24 @@ func newSnapForClaim(snapName string, pvc corev1.PersistentVolumeClaim,
 
 	return snapshot
 }
+
+// scheduleLabelKey returns the label used to associate snapshots with the
+// supplied schedule. Schedules that stand in for a ClusterSnapshotSchedule use
+// a separate key so their snapshots can't be confused with those of a
+// same-named SnapshotSchedule in the namespace.
+func scheduleLabelKey(schedule *snapschedulerv1.SnapshotSchedule) string {
+	if scheduleKind(schedule) == snapschedulerv1.ClusterSnapshotScheduleKind {
+		return ClusterScheduleKey
+	}
+	return ScheduleKey
+}
+
+// scheduleKind returns the kind of the schedule. Schedules that stand in for a
+// ClusterSnapshotSchedule carry its kind, while those read from the API may
+// not have their kind set.
+func scheduleKind(schedule *snapschedulerv1.SnapshotSchedule) string {
+	if schedule.Kind == snapschedulerv1.ClusterSnapshotScheduleKind {
+		return snapschedulerv1.ClusterSnapshotScheduleKind
+	}
+	return snapschedulerv1.SnapshotScheduleKind
+}
@@ -24,12 +24,16 @@ import (
 	"testing"
 	"time"
 
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
@@ -42,7 +46,7 @@ const (
 var _ = DescribeTable("Determining the next snapshot time",
 	func(cronspec string, current string, next string, expectErr bool) {
 		ctime, _ := time.Parse(timeFormat, current)
-		got, err := getNextSnapTime(cronspec, ctime)
+		got, err := getNextSnapTime(cronspec, nil, 0, ctime)
 		if expectErr {
 			Expect(err).To(HaveOccurred())
 		} else {
@@ -55,6 +59,99 @@ var _ = DescribeTable("Determining the next snapshot time",
 	Entry("invalid spec", "invalid_spec", "2013-02-01T11:04:05Z", "unused", true),
 )
 
+var _ = DescribeTable("Determining the next snapshot time in a time zone",
+	func(cronspec string, timeZone string, current string, next string) {
+		loc, err := time.LoadLocation(timeZone)
+		Expect(err).NotTo(HaveOccurred())
+		ctime, err := time.Parse(timeFormat, current)
+		Expect(err).NotTo(HaveOccurred())
+		want, err := time.Parse(timeFormat, next)
+		Expect(err).NotTo(HaveOccurred())
+		got, err := getNextSnapTime(cronspec, loc, 0, ctime)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Equal(want)).To(BeTrue(), "got %v, want %v", got.UTC(), want)
+	},
+	Entry("2am in New York (standard time)", "0 2 * * *", "America/New_York",
+		"2024-01-10T12:00:00Z", "2024-01-11T07:00:00Z"),
+	Entry("2am in New York (daylight time)", "0 2 * * *", "America/New_York",
+		"2024-07-10T12:00:00Z", "2024-07-11T06:00:00Z"),
+	Entry("2am in Tokyo", "0 2 * * *", "Asia/Tokyo",
+		"2024-07-10T12:00:00Z", "2024-07-10T17:00:00Z"),
+	Entry("a fractional offset", "0 2 * * *", "Asia/Kolkata",
+		"2024-07-10T12:00:00Z", "2024-07-10T20:30:00Z"),
+	// New York springs forward at 2024-03-10T07:00:00Z (2am EST -> 3am EDT)
+	Entry("a skipped time runs when the clocks resume", "30 2 * * *", "America/New_York",
+		"2024-03-09T12:00:00Z", "2024-03-10T07:00:00Z"),
+	Entry("a skipped time resumes its usual time the next day", "30 2 * * *", "America/New_York",
+		"2024-03-10T07:00:00Z", "2024-03-11T06:30:00Z"),
+	Entry("a time after the gap is unaffected", "30 3 * * *", "America/New_York",
+		"2024-03-09T12:00:00Z", "2024-03-10T07:30:00Z"),
+	Entry("hourly skips only the nonexistent hour", "0 * * * *", "America/New_York",
+		"2024-03-10T06:30:00Z", "2024-03-10T07:00:00Z"),
+	Entry("a skipped midnight runs when the clocks resume", "@daily", "America/Santiago",
+		"2024-09-07T12:00:00Z", "2024-09-08T04:00:00Z"),
+	// New York falls back at 2024-11-03T06:00:00Z (2am EDT -> 1am EST)
+	Entry("a repeated time runs on its first occurrence", "30 1 * * *", "America/New_York",
+		"2024-11-02T12:00:00Z", "2024-11-03T05:30:00Z"),
+	Entry("a repeated time doesn't run on its second occurrence", "30 1 * * *", "America/New_York",
+		"2024-11-03T05:30:00Z", "2024-11-04T06:30:00Z"),
+	Entry("a repeated time doesn't run after a late reconcile", "30 1 * * *", "America/New_York",
+		"2024-11-03T06:10:00Z", "2024-11-04T06:30:00Z"),
+	Entry("hourly runs in both repeated hours", "0 * * * *", "America/New_York",
+		"2024-11-03T05:00:00Z", "2024-11-03T06:00:00Z"),
+	Entry("every 30 minutes continues through the repeated hour", "*/30 * * * *", "America/New_York",
+		"2024-11-03T05:30:00Z", "2024-11-03T06:00:00Z"),
+	Entry("every other hour follows the wall clock", "0 */2 * * *", "America/New_York",
+		"2024-11-03T04:30:00Z", "2024-11-03T07:00:00Z"),
+)
+
+var _ = Describe("Determining a schedule's time zone", func() {
+	It("defaults to none", func() {
+		loc, err := scheduleLocation(&snapschedulerv1.SnapshotScheduleSpec{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loc).To(BeNil())
+	})
+	It("loads IANA time zones", func() {
+		loc, err := scheduleLocation(&snapschedulerv1.SnapshotScheduleSpec{TimeZone: ptr.To("Europe/Berlin")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loc.String()).To(Equal("Europe/Berlin"))
+	})
+	It("rejects invalid names", func() {
+		for _, tz := range []string{"", "Local", "Mars/Olympus_Mons"} {
+			_, err := scheduleLocation(&snapschedulerv1.SnapshotScheduleSpec{TimeZone: ptr.To(tz)})
+			Expect(err).To(HaveOccurred(), "time zone %q", tz)
+		}
+	})
+	It("is used for the next snapshot time", func() {
+		s := &snapschedulerv1.SnapshotSchedule{}
+		s.Spec.Schedule = "0 2 * * *"
+		s.Spec.TimeZone = ptr.To("Europe/Berlin")
+		cTime, _ := time.Parse(timeFormat, "2024-07-01T12:00:00Z")
+		Expect(updateNextSnapTime(s, cTime)).To(Succeed())
+		expected, _ := time.Parse(timeFormat, "2024-07-02T00:00:00Z")
+		Expect(s.Status.NextSnapshotTime.Time.Equal(expected)).To(BeTrue())
+	})
+	It("clears the next snapshot time if invalid", func() {
+		s := &snapschedulerv1.SnapshotSchedule{}
+		s.Spec.Schedule = "0 2 * * *"
+		s.Spec.TimeZone = ptr.To("Nowhere/Special")
+		Expect(updateNextSnapTime(s, time.Now())).NotTo(Succeed())
+		Expect(s.Status.NextSnapshotTime).To(BeNil())
+	})
+})
+
+var _ = DescribeTable("Normalizing a cronspec",
+	func(cronspec string, normalized string) {
+		Expect(NormalizeCronspec(cronspec)).To(Equal(normalized))
+		_, err := parseCronspec(normalized)
+		Expect(err).NotTo(HaveOccurred())
+	},
+	Entry("already normal", "0 * * * *", "0 * * * *"),
+	Entry("extra whitespace", "  0\t*  * * *\n", "0 * * * *"),
+	Entry("mixed case descriptor", " @Daily ", "@daily"),
+	Entry("mixed case interval", "@EVERY 1H", "@every 1h"),
+)
+
 var _ = Describe("newSnapForClaim", func() {
 	It("creates a snapshot object based on a pvc, schedule, snapclass", func() {
 		pvc := corev1.PersistentVolumeClaim{
@@ -293,7 +390,7 @@ var _ = Describe("Listing PVCs by selector", func() {
 				"mylabel": "foo",
 			},
 		}
-		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, mlFoo)
+		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, mlFoo, nil)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(len(pvcList.Items)).To(Equal(1))
 		Expect(pvcList.Items[0].Name).To(Equal("name-foo"))
@@ -310,14 +407,233 @@ var _ = Describe("Listing PVCs by selector", func() {
 				},
 			},
 		}
-		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, meBar)
+		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, meBar, nil)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(len(pvcList.Items)).To(Equal(1))
 		Expect(pvcList.Items[0].Name).To(Equal("name-bar"))
 	})
 	It("returns everything w/ an empty selector", func() {
-		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, &metav1.LabelSelector{})
+		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name, &metav1.LabelSelector{}, nil)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(len(pvcList.Items)).To(Equal(len(objects)))
 	})
 })
+
+var _ = Describe("Listing PVCs by StorageClass", func() {
+	var ns *v1.Namespace
+	var gold, silver *storagev1.StorageClass
+	BeforeEach(func() {
+		ns = &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		Expect(ns.Name).NotTo(BeEmpty())
+
+		gold = &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "gold-",
+				Labels: map[string]string{
+					"tier": "gold-" + ns.Name,
+				},
+			},
+			Provisioner: "example.com/csi",
+		}
+		Expect(k8sClient.Create(context.TODO(), gold)).To(Succeed())
+		silver = &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "silver-",
+			},
+			Provisioner: "example.com/csi",
+		}
+		Expect(k8sClient.Create(context.TODO(), silver)).To(Succeed())
+
+		for pvcName, className := range map[string]string{
+			"gold-pvc":   gold.Name,
+			"silver-pvc": silver.Name,
+			"other-pvc":  "other",
+		} {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pvcName,
+					Namespace: ns.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{
+						corev1.ReadWriteOnce,
+					},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{
+							"storage": resource.MustParse("1Gi"),
+						},
+					},
+					StorageClassName: &className,
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		}
+		Eventually(func() int {
+			pvcList := &corev1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(context.TODO(), pvcList, client.InNamespace(ns.Name))).To(Succeed())
+			return len(pvcList.Items)
+		}, timeout, interval).Should(Equal(3))
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+		Expect(k8sClient.Delete(context.TODO(), gold)).To(Succeed())
+		Expect(k8sClient.Delete(context.TODO(), silver)).To(Succeed())
+	})
+	pvcNames := func(scSelector *snapschedulerv1.StorageClassSelector) []string {
+		pvcList, err := listPVCsMatchingSelector(context.TODO(), logger, k8sClient, ns.Name,
+			&metav1.LabelSelector{}, scSelector)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, pvc := range pvcList.Items {
+			names = append(names, pvc.Name)
+		}
+		return names
+	}
+	It("can find PVCs by StorageClass name", func() {
+		Expect(pvcNames(&snapschedulerv1.StorageClassSelector{
+			Names: []string{silver.Name},
+		})).To(ConsistOf("silver-pvc"))
+	})
+	It("can find PVCs by StorageClass labels", func() {
+		Eventually(func() []string {
+			return pvcNames(&snapschedulerv1.StorageClassSelector{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"tier": "gold-" + ns.Name,
+					},
+				},
+			})
+		}, timeout, interval).Should(ConsistOf("gold-pvc"))
+	})
+	It("selects PVCs matching either the names or the labels", func() {
+		Eventually(func() []string {
+			return pvcNames(&snapschedulerv1.StorageClassSelector{
+				Names: []string{"other"},
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"tier": "gold-" + ns.Name,
+					},
+				},
+			})
+		}, timeout, interval).Should(ConsistOf("gold-pvc", "other-pvc"))
+	})
+	It("doesn't filter by StorageClass when no selector is given", func() {
+		Expect(pvcNames(nil)).To(HaveLen(3))
+	})
+})
+
+var _ = Describe("Determining a PVC's StorageClass", func() {
+	It("uses spec.storageClassName", func() {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(PVCStorageClassName(pvc)).To(BeEmpty())
+		pvc.Spec.StorageClassName = ptr.To("fast")
+		Expect(PVCStorageClassName(pvc)).To(Equal("fast"))
+	})
+	It("prefers the beta annotation", func() {
+		pvc := &corev1.PersistentVolumeClaim{}
+		pvc.Spec.StorageClassName = ptr.To("fast")
+		pvc.Annotations = map[string]string{
+			corev1.BetaStorageClassAnnotation: "slow",
+		}
+		Expect(PVCStorageClassName(pvc)).To(Equal("slow"))
+	})
+})
+
+var _ = Describe("Manually triggering a run", func() {
+	var ns *corev1.Namespace
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data",
+				Namespace: ns.Name,
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{
+					corev1.ReadWriteOnce,
+				},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{
+						"storage": resource.MustParse("1Gi"),
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), pvc)).To(Succeed())
+		bindPVC(pvc)
+		next := metav1.NewTime(time.Now().Add(time.Hour))
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "daily",
+				Namespace:   ns.Name,
+				Annotations: map[string]string{TriggerKey: "before-deploy"},
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 0 * * *",
+				Disabled: true,
+			},
+			Status: snapschedulerv1.SnapshotScheduleStatus{
+				NextSnapshotTime: &next,
+			},
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	reconcile := func() {
+		_, err := doReconcile(context.TODO(), schedule, logger, k8sClient, recorder, false, true, nil, &scheduleTracker{
+			readyUIDs: make(map[types.UID]struct{}),
+			prevPVCs:  make(map[string]struct{}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+	snapshots := func() []snapv1.VolumeSnapshot {
+		snapList, err := snapshotsFromSchedule(context.TODO(), schedule, logger, k8sClient)
+		Expect(err).NotTo(HaveOccurred())
+		return snapList
+	}
+
+	It("ignores schedules without a trigger", func() {
+		delete(schedule.Annotations, TriggerKey)
+		Expect(pendingTrigger(schedule)).To(BeNil())
+		Expect(schedule.Status.LastTrigger).To(BeNil())
+	})
+	It("takes labeled snapshots once per trigger", func() {
+		reconcile()
+		snaps := snapshots()
+		Expect(snaps).To(HaveLen(1))
+		Expect(snaps[0].Labels).To(HaveKeyWithValue(TypeKey, TypeManual))
+		trigger := schedule.Status.LastTrigger
+		Expect(trigger.Token).To(Equal("before-deploy"))
+		Expect(trigger.Completed).To(BeTrue())
+		Expect(snaps[0].Name).To(Equal("data-daily-" + trigger.Time.UTC().Format(timeYYYYMMDDHHMMSS) + "-manual"))
+		Expect(schedule.Status.RecentRuns[0].Trigger).To(Equal("before-deploy"))
+		Expect(schedule.Status.LastSnapshotTime).NotTo(BeNil())
+		// The schedule itself is unaffected
+		Expect(schedule.Status.NextSnapshotTime.Time).To(BeTemporally(">", time.Now()))
+
+		reconcile()
+		Expect(snapshots()).To(HaveLen(1))
+		Expect(schedule.Status.LastTrigger).To(Equal(trigger))
+	})
+	It("starts a new run for a new token", func() {
+		reconcile()
+		schedule.Annotations[TriggerKey] = "after-deploy"
+		Expect(pendingTrigger(schedule)).NotTo(BeNil())
+		Expect(schedule.Status.LastTrigger.Token).To(Equal("after-deploy"))
+		Expect(schedule.Status.LastTrigger.Completed).To(BeFalse())
+		reconcile()
+		Expect(schedule.Status.LastTrigger.Completed).To(BeTrue())
+	})
+})
@@ -0,0 +1,224 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// reservedLabels are applied to snapshots by the controller and may not be
+// supplied via the snapshot template
+var reservedLabels = []string{ScheduleKey, WhenKey, ClusterScheduleKey, TypeKey, RetryKey}
+
+// ValidateSnapshotScheduleSpec checks the portions of a schedule that can not
+// be verified by the CRD's schema. These are the same checks that would
+// otherwise cause the schedule to fail during reconcile.
+func ValidateSnapshotScheduleSpec(spec *snapschedulerv1.SnapshotScheduleSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Schedule == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("schedule"), "a cronspec is required"))
+	} else if _, err := parseCronspec(spec.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("schedule"), spec.Schedule, err.Error()))
+	}
+
+	if spec.TimeZone != nil {
+		if _, err := scheduleLocation(spec); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("timeZone"), *spec.TimeZone, err.Error()))
+		}
+	}
+
+	if spec.StartingDeadlineSeconds != nil && *spec.StartingDeadlineSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("startingDeadlineSeconds"),
+			*spec.StartingDeadlineSeconds, "must be non-negative"))
+	}
+
+	allErrs = append(allErrs, ValidateSnapshotRetentionSpec(&spec.Retention, fldPath.Child("retention"))...)
+
+	selectorOpts := metav1validation.LabelSelectorValidationOptions{}
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(&spec.ClaimSelector, selectorOpts,
+		fldPath.Child("claimSelector"))...)
+	if spec.StorageClassSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(spec.StorageClassSelector.Selector,
+			selectorOpts, fldPath.Child("storageClassSelector", "selector"))...)
+	}
+
+	if spec.GroupSnapshot != nil && spec.StorageClassSelector != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("storageClassSelector"),
+			"may not be used with groupSnapshot; group snapshots select PVCs by label only"))
+	}
+	if spec.GroupSnapshot != nil && spec.ClaimFilter != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("claimFilter"),
+			"may not be used with groupSnapshot; group snapshots select PVCs by label only"))
+	}
+	if spec.GroupSnapshot != nil && spec.SnapshotOnMatch {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("snapshotOnMatch"),
+			"may not be used with groupSnapshot"))
+	}
+
+	if spec.SnapshotTemplate != nil {
+		labelsPath := fldPath.Child("snapshotTemplate", "labels")
+		allErrs = append(allErrs, metav1validation.ValidateLabels(spec.SnapshotTemplate.Labels, labelsPath)...)
+		for _, key := range reservedLabels {
+			if _, found := spec.SnapshotTemplate.Labels[key]; found {
+				allErrs = append(allErrs, field.Forbidden(labelsPath.Key(key),
+					"label is reserved for use by snapscheduler"))
+			}
+		}
+	}
+
+	if claim := spec.ClaimFailure; claim != nil {
+		claimPath := fldPath.Child("claimFailure")
+		if claim.MaxRetries != nil && *claim.MaxRetries < 0 {
+			allErrs = append(allErrs, field.Invalid(claimPath.Child("maxRetries"), *claim.MaxRetries,
+				"must be non-negative"))
+		}
+		if claim.RetryIntervalSeconds != nil && *claim.RetryIntervalSeconds <= 0 {
+			allErrs = append(allErrs, field.Invalid(claimPath.Child("retryIntervalSeconds"),
+				*claim.RetryIntervalSeconds, "must be positive"))
+		}
+	}
+
+	if failed := spec.FailedSnapshots; failed != nil {
+		failedPath := fldPath.Child("failedSnapshots")
+		allErrs = append(allErrs, validateRetentionDuration(failed.StuckAfter, failedPath.Child("stuckAfter"))...)
+		allErrs = append(allErrs, validateRetentionDuration(failed.GracePeriod, failedPath.Child("gracePeriod"))...)
+		if failed.MaxRetries != nil && *failed.MaxRetries < 0 {
+			allErrs = append(allErrs, field.Invalid(failedPath.Child("maxRetries"), *failed.MaxRetries,
+				"must be non-negative"))
+		}
+	}
+
+	if final := spec.FinalSnapshot; final != nil {
+		finalPath := fldPath.Child("finalSnapshot")
+		allErrs = append(allErrs, validateRetentionDuration(final.Timeout, finalPath.Child("timeout"))...)
+		allErrs = append(allErrs, validateRetentionDuration(final.Expires, finalPath.Child("expires"))...)
+	}
+
+	switch spec.DeletionPolicy {
+	case "", snapschedulerv1.DeletionPolicyRetain, snapschedulerv1.DeletionPolicyDelete,
+		snapschedulerv1.DeletionPolicyRetainLatest:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("deletionPolicy"), spec.DeletionPolicy,
+			[]snapschedulerv1.DeletionPolicy{snapschedulerv1.DeletionPolicyRetain,
+				snapschedulerv1.DeletionPolicyDelete, snapschedulerv1.DeletionPolicyRetainLatest}))
+	}
+
+	if spec.Hooks != nil {
+		hooksPath := fldPath.Child("hooks")
+		allErrs = append(allErrs, validateSnapshotHook(spec.Hooks.Pre, hooksPath.Child("pre"))...)
+		allErrs = append(allErrs, validateSnapshotHook(spec.Hooks.Post, hooksPath.Child("post"))...)
+	}
+
+	return allErrs
+}
+
+// validateSnapshotHook checks that the hook specifies a single, runnable action
+func validateSnapshotHook(hook *snapschedulerv1.SnapshotHook, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if hook == nil {
+		return allErrs
+	}
+	switch {
+	case hook.Exec != nil && hook.Job != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("job"), "may not be specified along with exec"))
+	case hook.Exec == nil && hook.Job == nil:
+		allErrs = append(allErrs, field.Required(fldPath, "either exec or job must be specified"))
+	case hook.Exec != nil:
+		execPath := fldPath.Child("exec")
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(&hook.Exec.PodSelector,
+			metav1validation.LabelSelectorValidationOptions{}, execPath.Child("podSelector"))...)
+		if len(hook.Exec.Command) == 0 {
+			allErrs = append(allErrs, field.Required(execPath.Child("command"), "a command is required"))
+		}
+	case hook.Job != nil:
+		if len(hook.Job.Template.Template.Spec.Containers) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("job", "template", "template", "spec", "containers"),
+				"the Job must have at least one container"))
+		}
+	}
+	if hook.TimeoutSeconds != nil && *hook.TimeoutSeconds < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("timeoutSeconds"), *hook.TimeoutSeconds,
+			"must be greater than 0"))
+	}
+	return allErrs
+}
+
+// validateRetentionDuration checks that an optional retention duration is
+// positive
+func validateRetentionDuration(duration string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if duration == "" {
+		return allErrs
+	}
+	lifetime, err := parseRetentionDuration(duration)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, duration, err.Error()))
+	} else if lifetime == 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, duration, "duration must be greater than 0"))
+	}
+	return allErrs
+}
+
+// ValidateSnapshotRetentionSpec checks that the retention durations and counts
+// are usable
+func ValidateSnapshotRetentionSpec(spec *snapschedulerv1.SnapshotRetentionSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateRetentionDuration(spec.Expires, fldPath.Child("expires"))...)
+	allErrs = append(allErrs, validateRetentionDuration(spec.NotReadyTimeout, fldPath.Child("notReadyTimeout"))...)
+	if maxCount := spec.MaxCount; maxCount != nil && *maxCount < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxCount"), *maxCount,
+			"must be greater than 0"))
+	}
+	if orphans := spec.Orphans; orphans != nil {
+		allErrs = append(allErrs, validateOrphanRetention(orphans, fldPath.Child("orphans"))...)
+	}
+	return allErrs
+}
+
+// validateOrphanRetention checks that the orphan policy has the settings it
+// requires
+func validateOrphanRetention(spec *snapschedulerv1.OrphanRetentionSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateRetentionDuration(spec.Expires, fldPath.Child("expires"))...)
+	if maxCount := spec.MaxCount; maxCount != nil && *maxCount < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxCount"), *maxCount,
+			"must be greater than 0"))
+	}
+	switch spec.Policy {
+	case snapschedulerv1.OrphanPolicyRetain:
+	case snapschedulerv1.OrphanPolicyExpire:
+		if spec.Expires == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("expires"),
+				"must be specified with the Expire policy"))
+		}
+	case snapschedulerv1.OrphanPolicyKeepNewest:
+		if spec.MaxCount == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("maxCount"),
+				"must be specified with the KeepNewest policy"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("policy"), spec.Policy,
+			[]snapschedulerv1.OrphanPolicy{snapschedulerv1.OrphanPolicyRetain,
+				snapschedulerv1.OrphanPolicyExpire, snapschedulerv1.OrphanPolicyKeepNewest}))
+	}
+	return allErrs
+}
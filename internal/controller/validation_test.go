@@ -0,0 +1,211 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = DescribeTable("Validating a schedule's spec",
+	func(mutate func(spec *snapschedulerv1.SnapshotScheduleSpec), badField string) {
+		spec := &snapschedulerv1.SnapshotScheduleSpec{
+			Schedule: "0 * * * *",
+		}
+		mutate(spec)
+		errs := ValidateSnapshotScheduleSpec(spec, field.NewPath("spec"))
+		if badField == "" {
+			Expect(errs).To(BeEmpty())
+		} else {
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0].Field).To(Equal(badField))
+		}
+	},
+	Entry("a minimal schedule", func(_ *snapschedulerv1.SnapshotScheduleSpec) {}, ""),
+	Entry("a fully specified schedule", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.TimeZone = ptr.To("America/New_York")
+		spec.Retention.Expires = "168h"
+		spec.Retention.MaxCount = ptr.To[int32](10)
+		spec.ClaimSelector.MatchLabels = map[string]string{"app": "db"}
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{"mylabel": "myvalue"},
+		}
+	}, ""),
+	Entry("a missing cronspec", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Schedule = ""
+	}, "spec.schedule"),
+	Entry("an unparsable cronspec", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Schedule = "0 25 * * *"
+	}, "spec.schedule"),
+	Entry("an unknown time zone", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.TimeZone = ptr.To("America/Nowhere")
+	}, "spec.timeZone"),
+	Entry("a negative starting deadline", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.StartingDeadlineSeconds = ptr.To[int64](-1)
+	}, "spec.startingDeadlineSeconds"),
+	Entry("an unparsable expiration", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Expires = "garbage"
+	}, "spec.retention.expires"),
+	Entry("a negative expiration", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Expires = "-1h"
+	}, "spec.retention.expires"),
+	Entry("a zero expiration", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Expires = "0s"
+	}, "spec.retention.expires"),
+	Entry("an unparsable notReadyTimeout", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.NotReadyTimeout = "soon"
+	}, "spec.retention.notReadyTimeout"),
+	Entry("an orphan Expire policy without an expiration", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{Policy: snapschedulerv1.OrphanPolicyExpire}
+	}, "spec.retention.orphans.expires"),
+	Entry("an orphan KeepNewest policy without a count", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{Policy: snapschedulerv1.OrphanPolicyKeepNewest}
+	}, "spec.retention.orphans.maxCount"),
+	Entry("an unknown orphan policy", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.Orphans = &snapschedulerv1.OrphanRetentionSpec{Policy: "Forget"}
+	}, "spec.retention.orphans.policy"),
+	Entry("a zero maxCount", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Retention.MaxCount = ptr.To[int32](0)
+	}, "spec.retention.maxCount"),
+	Entry("a bad claim selector", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.ClaimSelector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+			Key:      "app",
+			Operator: metav1.LabelSelectorOpIn,
+		}}
+	}, "spec.claimSelector.matchExpressions[0].values"),
+	Entry("a bad StorageClass selector", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.StorageClassSelector = &snapschedulerv1.StorageClassSelector{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"bad key!": "value"},
+			},
+		}
+	}, "spec.storageClassSelector.selector.matchLabels"),
+	Entry("a group snapshot filtered by StorageClass", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.GroupSnapshot = &snapschedulerv1.GroupSnapshotSpec{}
+		spec.StorageClassSelector = &snapschedulerv1.StorageClassSelector{Names: []string{"fast"}}
+	}, "spec.storageClassSelector"),
+	Entry("a group snapshot with a PVC filter", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.GroupSnapshot = &snapschedulerv1.GroupSnapshotSpec{}
+		spec.ClaimFilter = &snapschedulerv1.ClaimFilterSpec{CSIDrivers: []string{"hostpath.csi.k8s.io"}}
+	}, "spec.claimFilter"),
+	Entry("a group snapshot with baseline snapshots", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.GroupSnapshot = &snapschedulerv1.GroupSnapshotSpec{}
+		spec.SnapshotOnMatch = true
+	}, "spec.snapshotOnMatch"),
+	Entry("an invalid template label", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{"mylabel": "not a valid value"},
+		}
+	}, "spec.snapshotTemplate.labels"),
+	Entry("a template label that collides with the schedule label", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{ScheduleKey: "other"},
+		}
+	}, "spec.snapshotTemplate.labels["+ScheduleKey+"]"),
+	Entry("a template label that collides with the time label", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{WhenKey: "202401010000"},
+		}
+	}, "spec.snapshotTemplate.labels["+WhenKey+"]"),
+	Entry("a template label that collides with the type label", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{TypeKey: TypeManual},
+		}
+	}, "spec.snapshotTemplate.labels["+TypeKey+"]"),
+	Entry("a template label that collides with the retry label", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			Labels: map[string]string{RetryKey: "1"},
+		}
+	}, "spec.snapshotTemplate.labels["+RetryKey+"]"),
+	Entry("exec and Job hooks", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{
+				Exec: &snapschedulerv1.ExecHook{Command: []string{"fsfreeze", "-f", "/data"}},
+			},
+			Post: &snapschedulerv1.SnapshotHook{
+				Job: &snapschedulerv1.JobHook{Template: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "thaw", Image: "busybox"}},
+					}},
+				}},
+				TimeoutSeconds: ptr.To[int32](60),
+				OnFailure:      snapschedulerv1.HookFailureProceed,
+			},
+		}
+	}, ""),
+	Entry("a hook with both exec and a Job", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{
+				Exec: &snapschedulerv1.ExecHook{Command: []string{"true"}},
+				Job:  &snapschedulerv1.JobHook{},
+			},
+		}
+	}, "spec.hooks.pre.job"),
+	Entry("a hook with no action", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{Post: &snapschedulerv1.SnapshotHook{}}
+	}, "spec.hooks.post"),
+	Entry("an exec hook without a command", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{Exec: &snapschedulerv1.ExecHook{}},
+		}
+	}, "spec.hooks.pre.exec.command"),
+	Entry("a Job hook without containers", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{Job: &snapschedulerv1.JobHook{}},
+		}
+	}, "spec.hooks.pre.job.template.template.spec.containers"),
+	Entry("a zero hook timeout", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+			Pre: &snapschedulerv1.SnapshotHook{
+				Exec:           &snapschedulerv1.ExecHook{Command: []string{"true"}},
+				TimeoutSeconds: ptr.To[int32](0),
+			},
+		}
+	}, "spec.hooks.pre.timeoutSeconds"),
+	Entry("an unparsable stuckAfter", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{StuckAfter: "1d"}
+	}, "spec.failedSnapshots.stuckAfter"),
+	Entry("a zero failed snapshot grace period", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{GracePeriod: "0m"}
+	}, "spec.failedSnapshots.gracePeriod"),
+	Entry("negative maxRetries", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.FailedSnapshots = &snapschedulerv1.FailedSnapshotSpec{MaxRetries: ptr.To[int32](-1)}
+	}, "spec.failedSnapshots.maxRetries"),
+	Entry("negative claim failure retries", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.ClaimFailure = &snapschedulerv1.ClaimFailureSpec{MaxRetries: ptr.To[int32](-1)}
+	}, "spec.claimFailure.maxRetries"),
+	Entry("a zero claim failure retry interval", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.ClaimFailure = &snapschedulerv1.ClaimFailureSpec{RetryIntervalSeconds: ptr.To[int32](0)}
+	}, "spec.claimFailure.retryIntervalSeconds"),
+	Entry("a zero final snapshot timeout", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.FinalSnapshot = &snapschedulerv1.FinalSnapshotSpec{Timeout: "0s"}
+	}, "spec.finalSnapshot.timeout"),
+	Entry("an invalid final snapshot expiration", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.FinalSnapshot = &snapschedulerv1.FinalSnapshotSpec{Expires: "1 week"}
+	}, "spec.finalSnapshot.expires"),
+	Entry("an unknown deletion policy", func(spec *snapschedulerv1.SnapshotScheduleSpec) {
+		spec.DeletionPolicy = "Orphan"
+	}, "spec.deletionPolicy"),
+)
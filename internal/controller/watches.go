@@ -0,0 +1,113 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+// selectorMatches reports whether the label selector selects the object's
+// labels. Invalid selectors match nothing.
+func selectorMatches(ls *metav1.LabelSelector, obj client.Object) bool {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// schedulesForClaim maps a PVC to the SnapshotSchedules in its namespace
+// whose claimSelector selects it
+func schedulesForClaim(ctx context.Context, c client.Client, obj client.Object) []reconcile.Request {
+	scheduleList := &snapschedulerv1.SnapshotScheduleList{}
+	if err := c.List(ctx, scheduleList, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list schedules for PVC", "PVC", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, schedule := range scheduleList.Items {
+		if selectorMatches(&schedule.Spec.ClaimSelector, obj) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: schedule.Name, Namespace: schedule.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// clusterSchedulesForClaim maps a PVC to the ClusterSnapshotSchedules that
+// select both its namespace and the PVC itself. The requests carry the PVC's
+// namespace so that only that namespace is reconciled.
+func clusterSchedulesForClaim(ctx context.Context, c client.Client, obj client.Object) []reconcile.Request {
+	logger := log.FromContext(ctx)
+	scheduleList := &snapschedulerv1.ClusterSnapshotScheduleList{}
+	if err := c.List(ctx, scheduleList); err != nil {
+		logger.Error(err, "unable to list cluster schedules for PVC", "PVC", obj.GetName())
+		return nil
+	}
+	if len(scheduleList.Items) == 0 {
+		return nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		logger.Error(err, "unable to get namespace of PVC", "PVC", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, cs := range scheduleList.Items {
+		if selectorMatches(&cs.Spec.NamespaceSelector, ns) && selectorMatches(&cs.Spec.ClaimSelector, obj) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: cs.Name, Namespace: obj.GetNamespace()},
+			})
+		}
+	}
+	return requests
+}
+
+// scheduleForSnapshot maps a snapshot to the SnapshotSchedule that created it
+func scheduleForSnapshot(_ context.Context, obj client.Object) []reconcile.Request {
+	name, found := obj.GetLabels()[ScheduleKey]
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()},
+	}}
+}
+
+// clusterScheduleForSnapshot maps a snapshot to the ClusterSnapshotSchedule
+// that created it, within the snapshot's namespace
+func clusterScheduleForSnapshot(_ context.Context, obj client.Object) []reconcile.Request {
+	name, found := obj.GetLabels()[ClusterScheduleKey]
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()},
+	}}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// nolint funlen  // Long test functions ok
+package controller
+
+import (
+	"context"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("Mapping PVCs and snapshots to schedules", func() {
+	var ns *corev1.Namespace
+	var pvc *corev1.PersistentVolumeClaim
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "test-",
+				Labels:       map[string]string{"backup": "yes"},
+			},
+		}
+		Expect(k8sClient.Create(context.TODO(), ns)).To(Succeed())
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data",
+				Namespace: ns.Name,
+				Labels:    map[string]string{"app": "db"},
+			},
+		}
+	})
+	AfterEach(func() {
+		Expect(k8sClient.Delete(context.TODO(), ns)).To(Succeed())
+	})
+	request := func(name, namespace string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	}
+
+	It("maps a PVC to the schedules that select it", func() {
+		for name, selector := range map[string]metav1.LabelSelector{
+			"all":   {},
+			"db":    {MatchLabels: map[string]string{"app": "db"}},
+			"other": {MatchLabels: map[string]string{"app": "web"}},
+		} {
+			schedule := &snapschedulerv1.SnapshotSchedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: snapschedulerv1.SnapshotScheduleSpec{
+					ClaimSelector: selector,
+					Schedule:      "0 * * * *",
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), schedule)).To(Succeed())
+		}
+		Expect(schedulesForClaim(context.TODO(), k8sClient, pvc)).To(ConsistOf(
+			request("all", ns.Name), request("db", ns.Name)))
+	})
+	It("maps a PVC to the cluster schedules that select it", func() {
+		for name, selector := range map[string]metav1.LabelSelector{
+			"backups-" + ns.Name: {MatchLabels: map[string]string{"backup": "yes"}},
+			"none-" + ns.Name:    {MatchLabels: map[string]string{"backup": "no"}},
+		} {
+			cs := &snapschedulerv1.ClusterSnapshotSchedule{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: snapschedulerv1.ClusterSnapshotScheduleSpec{
+					NamespaceSelector: selector,
+					SnapshotScheduleSpec: snapschedulerv1.SnapshotScheduleSpec{
+						Schedule: "0 * * * *",
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.TODO(), cs)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(context.TODO(), cs)).To(Succeed())
+			})
+		}
+		// Other tests' cluster schedules may also select the PVC
+		requests := clusterSchedulesForClaim(context.TODO(), k8sClient, pvc)
+		Expect(requests).To(ContainElement(request("backups-"+ns.Name, ns.Name)))
+		Expect(requests).NotTo(ContainElement(request("none-"+ns.Name, ns.Name)))
+	})
+	It("maps a snapshot to the schedule that created it", func() {
+		snap := &snapv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data-hourly-202403010200",
+				Namespace: ns.Name,
+				Labels:    map[string]string{ScheduleKey: "hourly"},
+			},
+		}
+		Expect(scheduleForSnapshot(context.TODO(), snap)).To(ConsistOf(request("hourly", ns.Name)))
+		Expect(clusterScheduleForSnapshot(context.TODO(), snap)).To(BeEmpty())
+
+		snap.Labels = map[string]string{ClusterScheduleKey: "nightly"}
+		Expect(scheduleForSnapshot(context.TODO(), snap)).To(BeEmpty())
+		Expect(clusterScheduleForSnapshot(context.TODO(), snap)).To(ConsistOf(request("nightly", ns.Name)))
+	})
+})
@@ -0,0 +1,138 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+	"github.com/backube/snapscheduler/internal/controller"
+)
+
+const (
+	// How long a PVC's deletion waits for its final snapshots to get underway
+	defaultFinalSnapshotWait = 20 * time.Second
+	// How often to check on the final snapshots while waiting
+	finalSnapshotPollInterval = time.Second
+)
+
+var persistentvolumeclaimlog = logf.Log.WithName("persistentvolumeclaim-resource")
+
+// SetupFinalSnapshotWebhookWithManager registers the webhook that takes the
+// final snapshots of PVCs in the manager.
+func SetupFinalSnapshotWebhookWithManager(mgr ctrl.Manager, defaultPolicy snapschedulerv1.DeletionPolicy,
+	limiter *controller.SnapshotCreationLimiter) error {
+	// The snapshots must be looked up directly since one created by a previous
+	// attempt may not have reached the cache yet
+	c, err := client.New(mgr.GetConfig(), client.Options{
+		Scheme:     mgr.GetScheme(),
+		Mapper:     mgr.GetRESTMapper(),
+		HTTPClient: mgr.GetHTTPClient(),
+	})
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr, &corev1.PersistentVolumeClaim{}).
+		WithValidator(&FinalSnapshotValidator{
+			Client:                limiter.Client(c),
+			Recorder:              mgr.GetEventRecorder("snapscheduler"),
+			DefaultDeletionPolicy: defaultPolicy,
+			Wait:                  defaultFinalSnapshotWait,
+		}).
+		Complete()
+}
+
+//nolint:lll
+//+kubebuilder:webhook:path=/validate--v1-persistentvolumeclaim,mutating=false,failurePolicy=ignore,sideEffects=NoneOnDryRun,groups="",resources=persistentvolumeclaims,verbs=delete,versions=v1,name=vfinalsnapshot.snapscheduler.backube,admissionReviewVersions=v1,timeoutSeconds=30
+
+// FinalSnapshotValidator takes the final snapshots of the PVCs protected by
+// schedules as their deletion is admitted. It never rejects a deletion.
+type FinalSnapshotValidator struct {
+	Client                client.Client
+	Recorder              events.EventRecorder
+	DefaultDeletionPolicy snapschedulerv1.DeletionPolicy
+	// How long to wait for the snapshots to get underway before admitting
+	// the deletion anyway
+	Wait time.Duration
+}
+
+var _ admission.Validator[*corev1.PersistentVolumeClaim] = &FinalSnapshotValidator{}
+
+// ValidateCreate implements admission.Validator
+func (v *FinalSnapshotValidator) ValidateCreate(_ context.Context,
+	_ *corev1.PersistentVolumeClaim) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements admission.Validator
+func (v *FinalSnapshotValidator) ValidateUpdate(_ context.Context,
+	_, _ *corev1.PersistentVolumeClaim) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateDelete implements admission.Validator
+func (v *FinalSnapshotValidator) ValidateDelete(ctx context.Context,
+	pvc *corev1.PersistentVolumeClaim) (admission.Warnings, error) {
+	if !controllerutil.ContainsFinalizer(pvc, controller.FinalSnapshotFinalizer) || !pvc.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	logger := persistentvolumeclaimlog.WithValues("name", pvc.Name, "namespace", pvc.Namespace)
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		logger.Error(err, "unable to get the admission request")
+		return nil, nil
+	}
+	if req.DryRun != nil && *req.DryRun {
+		return nil, nil
+	}
+	logger.V(1).Info("validate delete")
+
+	// Each attempt works from the current PVC, but names the snapshots for the
+	// time of this request
+	now := time.Now()
+	underway := false
+	_ = wait.PollUntilContextTimeout(ctx, finalSnapshotPollInterval, v.Wait, true,
+		func(ctx context.Context) (bool, error) {
+			current := &corev1.PersistentVolumeClaim{}
+			err := v.Client.Get(ctx, client.ObjectKeyFromObject(pvc), current)
+			if err == nil {
+				underway, err = controller.TakeFinalSnapshots(ctx, current, req.UID, now, logger, v.Client,
+					v.Recorder, v.DefaultDeletionPolicy)
+			}
+			if err != nil {
+				// Retried until the wait is over
+				logger.Error(err, "unable to take final snapshots")
+				return false, nil
+			}
+			return underway, nil
+		})
+	if !underway {
+		return admission.Warnings{"the final snapshots of the PVC may not have been taken"}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	//nolint:revive  // Allow . import
+	. "github.com/onsi/ginkgo/v2"
+	//nolint:revive  // Allow . import
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+	"github.com/backube/snapscheduler/internal/controller"
+)
+
+var _ = Describe("Final snapshot webhook", func() {
+	var validator *FinalSnapshotValidator
+	var pvc *corev1.PersistentVolumeClaim
+	var c client.Client
+	BeforeEach(func() {
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "data",
+				Namespace:  "myns",
+				Finalizers: []string{controller.FinalSnapshotFinalizer},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		}
+	})
+	JustBeforeEach(func() {
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "myns"}},
+			&snapschedulerv1.SnapshotSchedule{
+				ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "myns"},
+				Spec: snapschedulerv1.SnapshotScheduleSpec{
+					Schedule:      "@daily",
+					FinalSnapshot: &snapschedulerv1.FinalSnapshotSpec{},
+				},
+			},
+			pvc,
+		).WithStatusSubresource(pvc).Build()
+		validator = &FinalSnapshotValidator{
+			Client:   c,
+			Recorder: events.NewFakeRecorder(10),
+			Wait:     100 * time.Millisecond,
+		}
+	})
+	deletion := func(uid types.UID) context.Context {
+		return admission.NewContextWithRequest(context.TODO(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{UID: uid},
+		})
+	}
+	finalSnapshots := func() []snapv1.VolumeSnapshot {
+		snapList := &snapv1.VolumeSnapshotList{}
+		Expect(c.List(context.TODO(), snapList, client.InNamespace("myns"))).To(Succeed())
+		return snapList.Items
+	}
+
+	It("snapshots a protected PVC before admitting its deletion", func() {
+		warnings, err := validator.ValidateDelete(deletion("delete-1"), pvc)
+		Expect(err).NotTo(HaveOccurred())
+		// The snapshot controller never protected the PVC
+		Expect(warnings).To(HaveLen(1))
+		snaps := finalSnapshots()
+		Expect(snaps).To(HaveLen(1))
+		Expect(snaps[0].Name).To(HavePrefix("data-daily-final-"))
+		Expect(snaps[0].Spec.Source.PersistentVolumeClaimName).To(Equal(ptr.To("data")))
+		Expect(snaps[0].Annotations).To(HaveKeyWithValue(controller.DeletionRequestKey, "delete-1"))
+	})
+	When("the snapshot controller protects the PVC", func() {
+		BeforeEach(func() {
+			pvc.Finalizers = append(pvc.Finalizers, "snapshot.storage.kubernetes.io/pvc-as-source-protection")
+		})
+		It("admits the deletion without a warning", func() {
+			warnings, err := validator.ValidateDelete(deletion("delete-1"), pvc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+			Expect(finalSnapshots()).To(HaveLen(1))
+		})
+		It("doesn't reuse the snapshot of a deletion that was rejected", func() {
+			_, err := validator.ValidateDelete(deletion("rejected"), pvc)
+			Expect(err).NotTo(HaveOccurred())
+			// Final snapshots are named to the second
+			time.Sleep(time.Second)
+			warnings, err := validator.ValidateDelete(deletion("delete-2"), pvc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+			Expect(finalSnapshots()).To(HaveLen(2))
+		})
+	})
+	It("ignores unprotected PVCs", func() {
+		pvc.Finalizers = nil
+		warnings, err := validator.ValidateDelete(deletion("delete-1"), pvc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(BeEmpty())
+		Expect(finalSnapshots()).To(BeEmpty())
+	})
+	It("doesn't take snapshots for dry runs", func() {
+		ctx := admission.NewContextWithRequest(context.TODO(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{DryRun: ptr.To(true)},
+		})
+		_, err := validator.ValidateDelete(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalSnapshots()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,313 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package v1 contains the admission webhooks for the snapscheduler v1 API
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+	"github.com/backube/snapscheduler/internal/controller"
+)
+
+// log is for logging in this package.
+var snapshotschedulelog = logf.Log.WithName("snapshotschedule-resource")
+
+// SetupSnapshotScheduleWebhookWithManager registers the webhooks for
+// SnapshotSchedule in the manager.
+func SetupSnapshotScheduleWebhookWithManager(mgr ctrl.Manager, defaults ScheduleDefaults) error {
+	return ctrl.NewWebhookManagedBy(mgr, &snapschedulerv1.SnapshotSchedule{}).
+		WithDefaulter(&SnapshotScheduleDefaulter{Client: mgr.GetClient(), Defaults: defaults}).
+		WithValidator(&SnapshotScheduleValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// IsDefaultSnapshotClassAnnotation marks the VolumeSnapshotClass that should
+// be used for a CSI driver when none is specified.
+const IsDefaultSnapshotClassAnnotation = "snapshot.storage.kubernetes.io/is-default-class"
+
+// ScheduleDefaults are the organization-wide defaults applied to newly
+// created SnapshotSchedules.
+type ScheduleDefaults struct {
+	// Retention is applied to schedules that do not specify any form of
+	// retention. An empty value leaves such schedules unbounded.
+	Retention snapschedulerv1.SnapshotRetentionSpec
+	// SelectSnapshotClass enables filling in the snapshotClassName with the
+	// default VolumeSnapshotClass for the CSI driver of the selected PVCs.
+	SelectSnapshotClass bool
+}
+
+// Validate checks that the defaults are themselves usable.
+func (d ScheduleDefaults) Validate() error {
+	return controller.ValidateSnapshotRetentionSpec(&d.Retention, field.NewPath("retention")).ToAggregate()
+}
+
+//nolint:lll
+//+kubebuilder:webhook:path=/mutate-snapscheduler-backube-v1-snapshotschedule,mutating=true,failurePolicy=fail,sideEffects=None,groups=snapscheduler.backube,resources=snapshotschedules,verbs=create,versions=v1,name=msnapshotschedule.snapscheduler.backube,admissionReviewVersions=v1
+
+// SnapshotScheduleDefaulter fills in defaults for newly created
+// SnapshotSchedules.
+type SnapshotScheduleDefaulter struct {
+	Client   client.Client
+	Defaults ScheduleDefaults
+}
+
+var _ admission.Defaulter[*snapschedulerv1.SnapshotSchedule] = &SnapshotScheduleDefaulter{}
+
+// Default implements admission.Defaulter
+func (d *SnapshotScheduleDefaulter) Default(ctx context.Context, schedule *snapschedulerv1.SnapshotSchedule) error {
+	logger := snapshotschedulelog.WithValues("name", schedule.Name, "namespace", schedule.Namespace)
+	logger.V(1).Info("default")
+
+	schedule.Spec.Schedule = controller.NormalizeCronspec(schedule.Spec.Schedule)
+
+	retention := &schedule.Spec.Retention
+	if retention.Expires == "" && retention.MaxCount == nil && retention.Tiers == nil {
+		// The schedule's own notReadyTimeout is kept, if it has one
+		notReadyTimeout := retention.NotReadyTimeout
+		d.Defaults.Retention.DeepCopyInto(retention)
+		if notReadyTimeout != "" {
+			retention.NotReadyTimeout = notReadyTimeout
+		}
+	}
+
+	if d.Defaults.SelectSnapshotClass && snapshotClassName(schedule) == nil {
+		// Choosing a class is best-effort. If one can't be determined, the
+		// snapshots will use the cluster's default for each PVC.
+		className, err := d.defaultSnapshotClass(ctx, logger, schedule)
+		if err != nil {
+			logger.Error(err, "unable to determine default VolumeSnapshotClass")
+		} else if className != "" {
+			if schedule.Spec.SnapshotTemplate == nil {
+				schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{}
+			}
+			schedule.Spec.SnapshotTemplate.SnapshotClassName = ptr.To(className)
+		}
+	}
+	return nil
+}
+
+// defaultSnapshotClass returns the name of the default VolumeSnapshotClass for
+// the CSI driver used by the schedule's PVCs. An empty name is returned if the
+// PVCs don't all share a single driver or if that driver doesn't have exactly
+// one default class.
+func (d *SnapshotScheduleDefaulter) defaultSnapshotClass(ctx context.Context, logger logr.Logger,
+	schedule *snapschedulerv1.SnapshotSchedule) (string, error) {
+	if schedule.Namespace == "" {
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			schedule = schedule.DeepCopy()
+			schedule.Namespace = req.Namespace
+		}
+	}
+	pvcList, err := controller.ListPVCsForSchedule(ctx, logger, d.Client, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	drivers := map[string]struct{}{}
+	for _, pvc := range pvcList.Items {
+		scName := controller.PVCStorageClassName(&pvc)
+		if scName == "" {
+			continue
+		}
+		sc := &storagev1.StorageClass{}
+		if err := d.Client.Get(ctx, types.NamespacedName{Name: scName}, sc); err != nil {
+			return "", client.IgnoreNotFound(err)
+		}
+		drivers[sc.Provisioner] = struct{}{}
+	}
+	if len(drivers) != 1 {
+		logger.V(1).Info("not selecting a VolumeSnapshotClass", "driverCount", len(drivers))
+		return "", nil
+	}
+
+	classList := &snapv1.VolumeSnapshotClassList{}
+	if err := d.Client.List(ctx, classList); err != nil {
+		return "", err
+	}
+	defaultClass := ""
+	for _, class := range classList.Items {
+		if _, found := drivers[class.Driver]; !found ||
+			class.Annotations[IsDefaultSnapshotClassAnnotation] != "true" {
+			continue
+		}
+		if defaultClass != "" {
+			// Multiple defaults is ambiguous
+			return "", nil
+		}
+		defaultClass = class.Name
+	}
+	return defaultClass, nil
+}
+
+//nolint:lll
+//+kubebuilder:webhook:path=/validate-snapscheduler-backube-v1-snapshotschedule,mutating=false,failurePolicy=fail,sideEffects=None,groups=snapscheduler.backube,resources=snapshotschedules,verbs=create;update,versions=v1,name=vsnapshotschedule.snapscheduler.backube,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshotclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// SnapshotScheduleValidator rejects SnapshotSchedules that would fail to
+// reconcile. It also rejects hooks from users who couldn't carry them out
+// themselves, since the controller runs them with its own permissions.
+type SnapshotScheduleValidator struct {
+	Client client.Client
+}
+
+var _ admission.Validator[*snapschedulerv1.SnapshotSchedule] = &SnapshotScheduleValidator{}
+
+// ValidateCreate implements admission.Validator
+func (v *SnapshotScheduleValidator) ValidateCreate(ctx context.Context,
+	schedule *snapschedulerv1.SnapshotSchedule) (admission.Warnings, error) {
+	snapshotschedulelog.V(1).Info("validate create", "name", schedule.Name)
+	return nil, v.validate(ctx, schedule, nil)
+}
+
+// ValidateUpdate implements admission.Validator
+func (v *SnapshotScheduleValidator) ValidateUpdate(ctx context.Context,
+	oldSchedule, newSchedule *snapschedulerv1.SnapshotSchedule) (admission.Warnings, error) {
+	snapshotschedulelog.V(1).Info("validate update", "name", newSchedule.Name)
+	return nil, v.validate(ctx, newSchedule, oldSchedule)
+}
+
+// ValidateDelete implements admission.Validator
+func (v *SnapshotScheduleValidator) ValidateDelete(_ context.Context,
+	_ *snapschedulerv1.SnapshotSchedule) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *SnapshotScheduleValidator) validate(ctx context.Context,
+	schedule, oldSchedule *snapschedulerv1.SnapshotSchedule) error {
+	specPath := field.NewPath("spec")
+	allErrs := controller.ValidateSnapshotScheduleSpec(&schedule.Spec, specPath)
+
+	// Only check the VolumeSnapshotClass when it is being set so that a class
+	// that is later removed doesn't block unrelated updates.
+	className := snapshotClassName(schedule)
+	if className != nil && (oldSchedule == nil || !ptr.Equal(className, snapshotClassName(oldSchedule))) {
+		classPath := specPath.Child("snapshotTemplate", "snapshotClassName")
+		err := v.Client.Get(ctx, types.NamespacedName{Name: *className}, &snapv1.VolumeSnapshotClass{})
+		if kerrors.IsNotFound(err) {
+			allErrs = append(allErrs, field.NotFound(classPath, *className))
+		} else if err != nil {
+			return kerrors.NewInternalError(err)
+		}
+	}
+
+	// Likewise, only the user setting the hooks needs to be allowed to run them
+	hooks := schedule.Spec.Hooks
+	if hooks != nil && (oldSchedule == nil || !equality.Semantic.DeepEqual(hooks, oldSchedule.Spec.Hooks)) {
+		hooksPath := specPath.Child("hooks")
+		phases := []struct {
+			name string
+			hook *snapschedulerv1.SnapshotHook
+		}{{"pre", hooks.Pre}, {"post", hooks.Post}}
+		for _, phase := range phases {
+			if phase.hook == nil {
+				continue
+			}
+			fieldErr, err := v.authorizeHook(ctx, schedule.Namespace, phase.hook, hooksPath.Child(phase.name))
+			if err != nil {
+				return kerrors.NewInternalError(err)
+			}
+			if fieldErr != nil {
+				allErrs = append(allErrs, fieldErr)
+			}
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return kerrors.NewInvalid(snapschedulerv1.GroupVersion.WithKind("SnapshotSchedule").GroupKind(),
+		schedule.Name, allErrs)
+}
+
+// authorizeHook checks that the user making the request may carry out the
+// hook in the namespace: creating pods/exec for exec hooks, or Jobs for Job
+// hooks
+func (v *SnapshotScheduleValidator) authorizeHook(ctx context.Context, namespace string,
+	hook *snapschedulerv1.SnapshotHook, hookPath *field.Path) (*field.Error, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attrs := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "create",
+	}
+	switch {
+	case hook.Exec != nil:
+		hookPath = hookPath.Child("exec")
+		attrs.Resource = "pods"
+		attrs.Subresource = "exec"
+	case hook.Job != nil:
+		hookPath = hookPath.Child("job")
+		attrs.Group = "batch"
+		attrs.Resource = "jobs"
+	default:
+		// Rejected as invalid by ValidateSnapshotScheduleSpec
+		return nil, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(req.UserInfo.Extra))
+	for key, value := range req.UserInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attrs,
+			User:               req.UserInfo.Username,
+			Groups:             req.UserInfo.Groups,
+			UID:                req.UserInfo.UID,
+			Extra:              extra,
+		},
+	}
+	if err = v.Client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if review.Status.Allowed {
+		return nil, nil
+	}
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	return field.Forbidden(hookPath, fmt.Sprintf("user %q may not create %s in namespace %s",
+		req.UserInfo.Username, resource, namespace)), nil
+}
+
+func snapshotClassName(schedule *snapschedulerv1.SnapshotSchedule) *string {
+	if schedule.Spec.SnapshotTemplate == nil {
+		return nil
+	}
+	return schedule.Spec.SnapshotTemplate.SnapshotClassName
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2026 The snapscheduler authors.
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package v1
+
+import (
+	"context"
+	"slices"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	//nolint:revive  // Allow . import
+	. "github.com/onsi/ginkgo/v2"
+	//nolint:revive  // Allow . import
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	snapschedulerv1 "github.com/backube/snapscheduler/api/v1"
+)
+
+var _ = Describe("SnapshotSchedule validating webhook", func() {
+	var validator *SnapshotScheduleValidator
+	var schedule *snapschedulerv1.SnapshotSchedule
+	BeforeEach(func() {
+		snapClass := &snapv1.VolumeSnapshotClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-snapclass"},
+			Driver:     "example.com/csi",
+		}
+		validator = &SnapshotScheduleValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(snapClass).Build(),
+		}
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: "myns",
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				Schedule: "0 * * * *",
+			},
+		}
+	})
+
+	It("accepts a valid schedule", func() {
+		_, err := validator.ValidateCreate(context.TODO(), schedule)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("rejects an invalid schedule", func() {
+		schedule.Spec.Schedule = "every hour"
+		schedule.Spec.Retention.Expires = "0s"
+		_, err := validator.ValidateCreate(context.TODO(), schedule)
+		Expect(kerrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.schedule"))
+		Expect(err.Error()).To(ContainSubstring("spec.retention.expires"))
+	})
+	It("accepts an existing VolumeSnapshotClass", func() {
+		schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			SnapshotClassName: ptr.To("csi-snapclass"),
+		}
+		_, err := validator.ValidateCreate(context.TODO(), schedule)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("rejects a nonexistent VolumeSnapshotClass", func() {
+		schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			SnapshotClassName: ptr.To("missing"),
+		}
+		_, err := validator.ValidateCreate(context.TODO(), schedule)
+		Expect(kerrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.snapshotTemplate.snapshotClassName"))
+	})
+	It("only checks the VolumeSnapshotClass on update if it changed", func() {
+		schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+			SnapshotClassName: ptr.To("missing"),
+		}
+		updated := schedule.DeepCopy()
+		updated.Spec.Disabled = true
+		_, err := validator.ValidateUpdate(context.TODO(), schedule, updated)
+		Expect(err).NotTo(HaveOccurred())
+
+		updated.Spec.SnapshotTemplate.SnapshotClassName = ptr.To("also-missing")
+		_, err = validator.ValidateUpdate(context.TODO(), schedule, updated)
+		Expect(kerrors.IsInvalid(err)).To(BeTrue())
+	})
+	It("allows deletion", func() {
+		schedule.Spec.Schedule = ""
+		_, err := validator.ValidateDelete(context.TODO(), schedule)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("with hooks", func() {
+		// The users and the resources they may create in myns
+		permissions := map[string][]string{
+			"admin":  {"pods/exec", "jobs"},
+			"jobber": {"jobs"},
+		}
+		requestBy := func(username string) context.Context {
+			return admission.NewContextWithRequest(context.TODO(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UserInfo: authenticationv1.UserInfo{Username: username},
+				},
+			})
+		}
+		BeforeEach(func() {
+			validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+					review := obj.(*authorizationv1.SubjectAccessReview)
+					attrs := review.Spec.ResourceAttributes
+					resource := attrs.Resource
+					if attrs.Subresource != "" {
+						resource += "/" + attrs.Subresource
+					}
+					review.Status.Allowed = attrs.Namespace == "myns" && attrs.Verb == "create" &&
+						slices.Contains(permissions[review.Spec.User], resource)
+					return nil
+				},
+			}).Build()
+			schedule.Spec.Hooks = &snapschedulerv1.SnapshotHooksSpec{
+				Pre: &snapschedulerv1.SnapshotHook{
+					Exec: &snapschedulerv1.ExecHook{
+						PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+						Command:     []string{"freeze"},
+					},
+				},
+				Post: &snapschedulerv1.SnapshotHook{
+					Job: &snapschedulerv1.JobHook{Template: batchv1.JobSpec{
+						Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+							Containers:    []corev1.Container{{Name: "thaw", Image: "busybox"}},
+							RestartPolicy: corev1.RestartPolicyNever,
+						}},
+					}},
+				},
+			}
+		})
+		It("accepts hooks that the user may carry out", func() {
+			_, err := validator.ValidateCreate(requestBy("admin"), schedule)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("rejects hooks that the user may not carry out", func() {
+			_, err := validator.ValidateCreate(requestBy("jobber"), schedule)
+			Expect(kerrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.hooks.pre.exec"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.hooks.post"))
+
+			_, err = validator.ValidateCreate(requestBy("nobody"), schedule)
+			Expect(kerrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.hooks.post.job"))
+		})
+		It("only checks the hooks on update if they changed", func() {
+			updated := schedule.DeepCopy()
+			updated.Spec.Disabled = true
+			_, err := validator.ValidateUpdate(requestBy("jobber"), schedule, updated)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated.Spec.Hooks.Pre.Exec.Command = []string{"sync"}
+			_, err = validator.ValidateUpdate(requestBy("jobber"), schedule, updated)
+			Expect(kerrors.IsInvalid(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("SnapshotSchedule defaulting webhook", func() {
+	var defaulter *SnapshotScheduleDefaulter
+	var schedule *snapschedulerv1.SnapshotSchedule
+	var objects []client.Object
+	newClass := func(name string, driver string, isDefault bool) *snapv1.VolumeSnapshotClass {
+		class := &snapv1.VolumeSnapshotClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Driver:     driver,
+		}
+		if isDefault {
+			class.Annotations = map[string]string{IsDefaultSnapshotClassAnnotation: "true"}
+		}
+		return class
+	}
+	newPVC := func(name string, scName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "myns",
+				Labels:    map[string]string{"app": "db"},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To(scName),
+			},
+		}
+	}
+	BeforeEach(func() {
+		objects = []client.Object{
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "fast.csi.example.com"},
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}, Provisioner: "slow.csi.example.com"},
+			newClass("fast-default", "fast.csi.example.com", true),
+			newClass("fast-other", "fast.csi.example.com", false),
+			newClass("slow-default", "slow.csi.example.com", true),
+			newPVC("fast1", "fast"),
+			newPVC("fast2", "fast"),
+		}
+		defaulter = &SnapshotScheduleDefaulter{
+			Defaults: ScheduleDefaults{SelectSnapshotClass: true},
+		}
+		schedule = &snapschedulerv1.SnapshotSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hourly",
+				Namespace: "myns",
+			},
+			Spec: snapschedulerv1.SnapshotScheduleSpec{
+				ClaimSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "db"},
+				},
+				Schedule: " @Hourly",
+			},
+		}
+	})
+	JustBeforeEach(func() {
+		defaulter.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		Expect(defaulter.Default(context.TODO(), schedule)).To(Succeed())
+	})
+
+	It("normalizes the cronspec", func() {
+		Expect(schedule.Spec.Schedule).To(Equal("@hourly"))
+	})
+	It("selects the default class for the PVCs' driver", func() {
+		Expect(schedule.Spec.SnapshotTemplate).NotTo(BeNil())
+		Expect(schedule.Spec.SnapshotTemplate.SnapshotClassName).To(Equal(ptr.To("fast-default")))
+	})
+	When("a class is already specified", func() {
+		BeforeEach(func() {
+			schedule.Spec.SnapshotTemplate = &snapschedulerv1.SnapshotTemplateSpec{
+				SnapshotClassName: ptr.To("fast-other"),
+			}
+		})
+		It("is left alone", func() {
+			Expect(schedule.Spec.SnapshotTemplate.SnapshotClassName).To(Equal(ptr.To("fast-other")))
+		})
+	})
+	When("the PVCs use different drivers", func() {
+		BeforeEach(func() {
+			objects = append(objects, newPVC("slow1", "slow"))
+		})
+		It("doesn't select a class", func() {
+			Expect(schedule.Spec.SnapshotTemplate).To(BeNil())
+		})
+	})
+	When("the driver has multiple default classes", func() {
+		BeforeEach(func() {
+			objects = append(objects, newClass("fast-default2", "fast.csi.example.com", true))
+		})
+		It("doesn't select a class", func() {
+			Expect(schedule.Spec.SnapshotTemplate).To(BeNil())
+		})
+	})
+	When("class selection is disabled", func() {
+		BeforeEach(func() {
+			defaulter.Defaults.SelectSnapshotClass = false
+		})
+		It("doesn't select a class", func() {
+			Expect(schedule.Spec.SnapshotTemplate).To(BeNil())
+		})
+	})
+	When("a default retention is configured", func() {
+		BeforeEach(func() {
+			defaulter.Defaults.Retention = snapschedulerv1.SnapshotRetentionSpec{
+				Expires:  "168h",
+				MaxCount: ptr.To[int32](10),
+			}
+		})
+		It("is applied to schedules without retention", func() {
+			Expect(schedule.Spec.Retention.Expires).To(Equal("168h"))
+			Expect(schedule.Spec.Retention.MaxCount).To(Equal(ptr.To[int32](10)))
+		})
+		When("the schedule has its own retention", func() {
+			BeforeEach(func() {
+				schedule.Spec.Retention.MaxCount = ptr.To[int32](3)
+			})
+			It("is not applied", func() {
+				Expect(schedule.Spec.Retention.Expires).To(BeEmpty())
+				Expect(schedule.Spec.Retention.MaxCount).To(Equal(ptr.To[int32](3)))
+			})
+		})
+		When("the schedule only has a notReadyTimeout", func() {
+			BeforeEach(func() {
+				schedule.Spec.Retention.NotReadyTimeout = "2h"
+			})
+			It("is applied, keeping the timeout", func() {
+				Expect(schedule.Spec.Retention.MaxCount).To(Equal(ptr.To[int32](10)))
+				Expect(schedule.Spec.Retention.NotReadyTimeout).To(Equal("2h"))
+			})
+		})
+	})
+})
+
+var _ = Describe("Validating schedule defaults", func() {
+	It("accepts empty defaults", func() {
+		Expect(ScheduleDefaults{}.Validate()).To(Succeed())
+	})
+	It("rejects a bad retention", func() {
+		defaults := ScheduleDefaults{
+			Retention: snapschedulerv1.SnapshotRetentionSpec{Expires: "forever"},
+		}
+		Expect(defaults.Validate()).NotTo(Succeed())
+	})
+})